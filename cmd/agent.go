@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/distributed"
+	"github.com/symonk/vessel/internal/runner"
+)
+
+const (
+	// agent flag long names
+	controllerFlag = "controller"
+	nameFlag       = "name"
+)
+
+var (
+	controllerURL string
+	agentName     string
+	agentQuiet    bool
+)
+
+// agentCmd runs a share of a load test on behalf of a controller.
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run a share of a load test coordinated by a controller",
+	Long: `Agent registers with a controller (started with vessel controller), waits for
+its share of the load test and runs it, streaming results back as it goes.
+
+The load test is configured entirely by the controller, which the agent
+authenticates with using the controller's --token.  The run is stopped
+should the agent lose contact with the controller.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var out io.Writer = os.Stdout
+		if agentQuiet {
+			out = io.Discard
+		}
+		if agentName == "" {
+			agentName, _ = os.Hostname()
+		}
+
+		ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		agent := distributed.NewAgent(controllerURL, sharedToken,
+			distributed.WithName(agentName),
+			distributed.WithRunnerOptions(runner.WithSummary(out)),
+		)
+		_, err := agent.Run(ctx)
+		return err
+	},
+}
+
+func init() {
+	agentCmd.Flags().StringVar(&controllerURL, controllerFlag, "http://localhost:7777", "Base URL of the controller to register with")
+	agentCmd.Flags().StringVar(&sharedToken, tokenFlag, "", "Token shared with the controller")
+	agentCmd.Flags().StringVar(&agentName, nameFlag, "", "Name to register with, defaults to the hostname")
+	agentCmd.Flags().BoolVarP(&agentQuiet, quietFlag, "q", false, "Suppresses output")
+	_ = agentCmd.MarkFlagRequired(tokenFlag)
+	rootCmd.AddCommand(agentCmd)
+}
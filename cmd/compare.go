@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/compare"
+)
+
+const (
+	// compare flag long names
+	toleranceFlag = "tolerance"
+	alphaFlag     = "alpha"
+)
+
+var (
+	tolerances map[string]string
+	alpha      float64
+)
+
+// compareCmd compares two results persisted with --output and flags
+// regressions between them.
+var compareCmd = &cobra.Command{
+	Use:   "compare baseline.json current.json",
+	Short: "Compare two saved runs and flag regressions",
+	Long: `Compare loads two results saved with --output, aligns their metrics and
+reports the absolute and percentage differences between them.
+
+Tolerances are the permitted worsening of a metric in percent and may be
+keyed by the exact metric name or by the metric kind (rps, error_rate, failure_rate,
+mean, p50, p90, p95, p99, max, status.<code>).  Status codes are compared as
+a share of the requests, their tolerances like those of error_rate and
+failure_rate are in absolute percentage points.  The 'default' tolerance applies to every other
+metric (other than status codes).  A metric worsening from zero, such as a
+status code first seen in the current run, always regresses.
+
+The command exits non zero when any metric regresses beyond its tolerance.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		baseline, err := collector.LoadResult(args[0])
+		if err != nil {
+			return err
+		}
+		current, err := collector.LoadResult(args[1])
+		if err != nil {
+			return err
+		}
+		parsed := compare.Tolerances{compare.Default: 10, compare.ErrorRate: 1, compare.FailureRate: 1}
+		for metric, value := range tolerances {
+			t, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("bad tolerance for %s: %v", metric, err)
+			}
+			parsed[metric] = t
+		}
+		report, err := compare.Compare(baseline, current, parsed, alpha)
+		if err != nil {
+			return err
+		}
+		if err := report.Write(os.Stdout); err != nil {
+			return err
+		}
+		if report.Regressed() {
+			cmd.SilenceUsage = true
+			return errors.New("performance regression detected")
+		}
+		return nil
+	},
+}
+
+func init() {
+	compareCmd.Flags().StringToStringVar(&tolerances, toleranceFlag, make(map[string]string), "Permitted worsening per metric in percent, metric=value (appendable, default=10,error_rate=1,failure_rate=1)")
+	compareCmd.Flags().Float64Var(&alpha, alphaFlag, 0, "Only flag latency regressions that are statistically significant at this level (e.g 0.05)")
+	rootCmd.AddCommand(compareCmd)
+}
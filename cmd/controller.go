@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/check"
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/distributed"
+	"github.com/symonk/vessel/internal/report"
+	"github.com/symonk/vessel/internal/runner"
+	"github.com/symonk/vessel/internal/threshold"
+)
+
+const (
+	// controller flag long names
+	agentsFlag = "agents"
+	listenFlag = "listen"
+	leadFlag   = "lead"
+	tokenFlag  = "token"
+)
+
+var (
+	agents      int
+	listen      string
+	lead        time.Duration
+	sharedToken string
+)
+
+// controllerCmd coordinates a single load test across many agents, merging
+// their results into one.
+var controllerCmd = &cobra.Command{
+	Use:   "controller URL",
+	Short: "Coordinate a load test across many agents",
+	Long: `Controller waits for the given number of agents (started with vessel agent)
+to register, shares the load test out between them and starts them together.
+
+The concurrency, number of requests, arrival rate and rate limit are divided
+between the agents, every other option is applied to each.  Options reading
+local files (--feeder, --bearer-file, client certificates, empirical
+arrivals and schema checks) are rejected, the agents would not have them.  Agents stream their per
+second latency histograms back throughout the run which are merged, along
+with their final results, into a single result.
+
+Agents must present the --token given to the controller, a random token is
+generated and printed when none is given.  The plan handed to agents holds
+every option, credentials included, and is sent unencrypted so the
+controller listens on localhost by default.  Use --listen to accept agents
+from a trusted network.  The run fails if an agent stops sending heartbeats.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg.Endpoint = args[0]
+		// A bad configuration fails here once rather than on every agent.
+		if err := distributed.ValidateSplit(cfg, agents); err != nil {
+			return err
+		}
+		if err := runner.Validate(cfg); err != nil {
+			return err
+		}
+		if _, err := check.ParseAll(cfg.Checks); err != nil {
+			return err
+		}
+		thresholds, err := threshold.ParseAll(cfg.Thresholds)
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+		if cfg.QuietSet {
+			out = io.Discard
+		}
+		controller := distributed.NewController(cfg, agents, sharedToken,
+			distributed.WithLead(lead),
+			distributed.WithIntervalFunc(func(i collector.Interval) {
+				fmt.Fprintf(out, "%8s  requests=%d errors=%d rps=%.2f p50=%s p99=%s\n",
+					i.Offset, i.Requests, i.Errors, i.RPS, millis(i.P50), millis(i.P99))
+			}),
+		)
+		listener, err := net.Listen("tcp", listen)
+		if err != nil {
+			return fmt.Errorf("unable to listen: %w", err)
+		}
+		server := &http.Server{Handler: controller, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Fprintln(os.Stderr, "controller stopped:", err)
+			}
+		}()
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(ctx)
+		}()
+		fmt.Fprintf(out, "Waiting for %d agents on %s\n", agents, listener.Addr())
+		if sharedToken == "" {
+			fmt.Fprintf(out, "Start agents with --%s %s\n", tokenFlag, controller.Token())
+		}
+
+		ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		go func() {
+			select {
+			case <-controller.Ready():
+				fmt.Fprintf(out, "All agents registered, starting in %s\n", lead)
+			case <-ctx.Done():
+			}
+		}()
+		result, err := controller.Wait(ctx)
+		if err != nil {
+			return err
+		}
+		if err := collector.WriteResult(out, result); err != nil {
+			return err
+		}
+
+		if output != "" {
+			if err := result.WriteFile(output); err != nil {
+				return err
+			}
+		}
+		if reportPath != "" {
+			if err := report.WriteFile(reportPath, result); err != nil {
+				return err
+			}
+		}
+		return evaluateThresholds(cmd, thresholds, result)
+	},
+}
+
+func init() {
+	addConfigFlags(controllerCmd)
+	controllerCmd.Flags().IntVar(&agents, agentsFlag, 1, "Number of agents to wait for before starting")
+	controllerCmd.Flags().StringVar(&listen, listenFlag, "localhost:7777", "Address to listen on for agents, only listen beyond localhost on a trusted network")
+	controllerCmd.Flags().StringVar(&sharedToken, tokenFlag, "", "Token agents must present, a random token is generated when not given")
+	controllerCmd.Flags().DurationVar(&lead, leadFlag, 2*time.Second, "Delay between the final agent registering and the run starting")
+	controllerCmd.Flags().StringVarP(&output, outputFlag, "o", "", "Write the merged results as JSON to the given file (usable with vessel compare)")
+	controllerCmd.Flags().StringVar(&reportPath, reportFlag, "", "Write a self contained HTML report with charts to the given file")
+	rootCmd.AddCommand(controllerCmd)
+}
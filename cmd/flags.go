@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/config"
+)
+
+// addConfigFlags registers the flags describing a load test, bound to the
+// shared cfg, on the command.  These are shared by every command which
+// runs a load test.
+func addConfigFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.BoolVar(&cfg.Debug, debugFlag, false, "Enabled enhanced debugging information (WARNING: noisy!)")
+	flags.BoolVarP(&cfg.QuietSet, quietFlag, "q", false, "Suppresses output")
+	flags.IntVarP(&cfg.MaxRPS, maxRPSFlag, "r", 0, "Rate limit requests per second")
+	flags.Float64Var(&cfg.Rate, rateFlag, 0, "Dispatch requests at a fixed arrival rate per second, regardless of how quickly they complete (0 means as fast as possible).  Arrivals finding every worker busy are sent late or dropped, raise -c to avoid them")
+	flags.StringVar(&cfg.Arrival, arrivalFlag, "constant", "Gaps between arrivals at --rate: constant, poisson, uniform or empirical:file (one gap per line, scaled to --rate if set)")
+	flags.StringVar(&cfg.Shape, shapeFlag, "", "Vary the arrival rate over the run: spike:base=100,peak=10x,at=30s,for=10s step:start=100,step=50,every=30s,max=500 or sine:min=100,max=1000,period=2m")
+	flags.Uint64Var(&cfg.Seed, seedFlag, 0, "Seed the random arrivals and think times for a reproducible schedule (0 picks one at random)")
+	flags.IntVarP(&cfg.Concurrency, concurrencyFlag, "c", 10, "Number of concurrent workers dispatching requests")
+	flags.DurationVarP(&cfg.Duration, durationFlag, "d", 0, "Duration to send requests for (must be parsable by time.ParseDuration)")
+	flags.StringVarP(&cfg.Method, methodFlag, "m", "GET", "HTTP Verb to perform")
+	flags.DurationVarP(&cfg.Timeout, timeoutFlag, "t", 0, "Per Request timeout before terminating the request (must be parsable by time.ParseDuration)")
+	flags.BoolVar(&cfg.HTTP2, http2Flag, false, "Enable HTTP/2 support")
+	flags.StringVar(&cfg.Host, hostHeaderFlag, "", "Set a custom HOST header")
+	flags.StringVarP(&cfg.UserAgent, userAgentFlag, "u", "", "Set a custom user agent header, this is always suffixed with the tools user agent")
+	flags.StringVarP(&cfg.BasicAuth, basicAuthFlag, "b", "", "Colon separated user:pass for basic auth header")
+	flags.StringSliceVarP(&cfg.Headers, headersFlag, "H", make([]string, 0), "Colon separated header:value for arbitrary HTTP headers (appendable)")
+	flags.StringVar(&cfg.Body, bodyFlag, "", "Send the body with every request")
+	flags.StringVar(&cfg.WSCorrelate, wsCorrelateFlag, "", "Set the field of JSON object WebSocket messages to a unique id, the reply is the message received holding the same id")
+	flags.BoolVar(&cfg.WSNoReply, wsNoReplyFlag, false, "Send WebSocket messages without waiting for a reply")
+	flags.StringVar(&cfg.Stream, streamFlag, "", "Measure streamed responses event by event rather than reading them whole: sse (server-sent events) or chunks (each read as it arrives)")
+	flags.DurationVar(&cfg.StreamTimeout, streamTimeoutFlag, 0, "Stop reading a streamed response once it has lasted the duration, counted as timed out rather than an error")
+	flags.Int64Var(&cfg.StreamMaxEvents, streamMaxFlag, 0, "Stop reading a streamed response after the number of events, counted as truncated rather than an error (0 means no limit)")
+	flags.StringArrayVar(&cfg.Resolve, resolveFlag, nil, "Connect to the address rather than resolving the host and port, host:port:address (appendable)")
+	flags.Int64VarP(&cfg.Amount, numberFlag, "n", 50, "The total number of requests, cannot be used with -d")
+	flags.Var(&warmupValue{cfg: cfg}, warmupFlag, "Generate load for a duration (e.g 10s) or number of requests (e.g 500) before measuring, excluded from the results")
+	flags.BoolVarP(&cfg.FollowRedirects, followFlag, "f", true, "Automatically follow redirects")
+	flags.BoolVarP(&cfg.Insecure, insecureFlag, "i", false, "Do not verify server certificate and host name")
+	flags.IntVar(&cfg.MaxConnections, maxConnectionsFlag, 1024, "Maximum connections (per host) the client will create/reuse")
+	flags.BoolVar(&cfg.Sessions, sessionsFlag, false, "Give every worker its own cookie jar, acting as a distinct virtual user with its own session")
+	flags.BoolVar(&cfg.UserPool, userPoolFlag, false, "Give every worker its own connection pool rather than sharing one")
+	flags.StringVar(&cfg.Feeder, feederFlag, "", "CSV file (with a header row) giving each worker a row of data, substituted for ${column} in the URL, headers and body")
+	flags.StringVar(&cfg.ThinkTime, thinkFlag, "", "Pause each worker between iterations, excluded from latency: 2s, uniform:1s-3s, normal:2s,500ms or exponential:2s")
+	flags.DurationVar(&cfg.Pacing, pacingFlag, 0, "Begin each worker's iterations at most once per interval regardless of response time")
+	// TODO: Document cache, need to implement it too.
+	flags.BoolVar(&cfg.Cache, cacheFlag, false, "Cache DNS lookups to minimise time spent in DNS parts of each request")
+	flags.StringVar(&cfg.Certificate, certFlag, "", "Public certificate for identification for mutual TLS")
+	flags.StringVarP(&cfg.PrivateKey, keyFlag, "k", "", "Private key for mutual TLS")
+	flags.BoolVar(&cfg.Traceparent, traceparentFlag, false, "Propagate a W3C traceparent header on each request")
+	flags.StringVar(&cfg.OTLPEndpoint, otlpEndpointFlag, "", "Export sampled client spans via OTLP/HTTP to the given traces endpoint (e.g http://localhost:4318/v1/traces), implies --traceparent")
+	flags.Float64Var(&cfg.TraceSampleRate, traceSampleFlag, 1, "Fraction of requests (0.0 - 1.0) sampled for tracing")
+	flags.StringVar(&cfg.CorrelationID, correlationIDFlag, "", "Set a unique correlation id in the given header on every request (e.g X-Request-ID)")
+	flags.StringVar(&cfg.BearerFile, bearerFileFlag, "", "Authorize requests with a bearer token read from the given file, re-read when it changes")
+	flags.StringVar(&cfg.HMAC, hmacFlag, "", "Colon separated header:secret to sign every request with HMAC-SHA256")
+	flags.StringArrayVar(&cfg.Checks, checkFlag, nil, "Validate every response, failures are counted separately from errors: status=200,2xx contains=text regex=pattern jsonpath=$.a.b==value header=Name max-size=bytes schema=file.json (appendable)")
+	flags.StringArrayVar(&cfg.Thresholds, thresholdFlag, nil, "Exit non zero unless the run satisfies the expression e.g 'p99<200ms && errors<1% && checks<0.5%' (appendable)")
+
+	// Specify required flags
+	cmd.MarkFlagsMutuallyExclusive(durationFlag, numberFlag)
+	cmd.MarkFlagsMutuallyExclusive(rateFlag, shapeFlag)
+
+	// Ensure if provided either of cert/key, that both are provided.
+	cmd.MarkFlagsRequiredTogether(certFlag, keyFlag)
+}
+
+// addOutputFlags registers the flags controlling what is output by a
+// command which runs a single load test through run.
+func addOutputFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.BoolVarP(&showCfg, showCfgFlag, "s", false, "Print cfg to stdout on startup")
+	flags.StringVarP(&output, outputFlag, "o", "", "Write the results as JSON to the given file (usable with vessel compare)")
+	flags.StringVar(&prometheus, prometheusFlag, "", "Expose live Prometheus metrics on /metrics at the given address (e.g :9100)")
+	flags.StringVar(&reportPath, reportFlag, "", "Write a self contained HTML report with charts to the given file")
+	flags.StringVar(&timeseries, timeseriesFlag, "", "Stream per second throughput and latency as CSV to the given file during the run")
+	flags.StringVar(&rawLog, rawLogFlag, "", "Write every request as a JSON line (timings, bytes, errors) to the given file")
+	flags.Float64Var(&rawSample, rawLogSampleFlag, 1, "Fraction of successful requests (0.0 - 1.0) written to the raw log, errors are always written")
+	flags.StringVar(&hdrLog, hdrLogFlag, "", "Write per second latency and phase histograms as an HdrHistogram interval log to the given file (see vessel report)")
+}
+
+// warmupValue is the value of the warm-up flag, which is either a duration
+// or a number of requests.
+type warmupValue struct {
+	cfg *config.Config
+}
+
+// String implements pflag.Value.
+func (w *warmupValue) String() string {
+	if w.cfg == nil {
+		return ""
+	}
+	if w.cfg.WarmupRequests > 0 {
+		return strconv.FormatInt(w.cfg.WarmupRequests, 10)
+	}
+	if w.cfg.Warmup > 0 {
+		return w.cfg.Warmup.String()
+	}
+	return ""
+}
+
+// Set implements pflag.Value.
+func (w *warmupValue) Set(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		w.cfg.Warmup, w.cfg.WarmupRequests = 0, n
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return fmt.Errorf("warm-up must be a duration or a number of requests, got %q", s)
+	}
+	w.cfg.Warmup, w.cfg.WarmupRequests = d, 0
+	return nil
+}
+
+// Type implements pflag.Value.
+func (w *warmupValue) Type() string {
+	return "duration|requests"
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/symonk/vessel/internal/curl"
+)
+
+const (
+	// from-curl flag long names
+	curlFileFlag = "curl"
+	runCurlFlag  = "run"
+)
+
+var (
+	curlFile string
+	runCurl  bool
+)
+
+// fromCurlCmd converts a curl command line into a vessel invocation.
+var fromCurlCmd = &cobra.Command{
+	Use:   "from-curl [COMMAND]",
+	Short: "Convert a curl command line into a load test",
+	Long: `From-curl parses a curl command line, such as one copied from a browser's
+developer tools, and prints the equivalent vessel invocation or with --run
+executes it.  The command is given as a single argument or read from a file
+with --curl (- reads stdin), quotes and line continuations are honoured.
+
+The method, url, headers, data, user, cookies, user agent, referer, TLS,
+--resolve, --location, --max-time and HTTP version options of curl are
+converted, options which only affect curl's output are ignored.  Any other
+flags given describe the load and are carried into the invocation.`,
+	Example: `  vessel from-curl "curl -X POST https://api.yourwebsite.com/orders -H 'Content-Type: application/json' -d '{\"id\":1}'"
+  vessel from-curl --curl request.sh -c 50 -d 1m --run
+  pbpaste | vessel from-curl --curl - --rate 100 -d 30s --run`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var command string
+		switch {
+		case len(args) == 1 && curlFile != "":
+			return fmt.Errorf("provide either a command or --%s, not both", curlFileFlag)
+		case len(args) == 1:
+			command = args[0]
+		case curlFile == "-":
+			b, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			command = string(b)
+		case curlFile != "":
+			b, err := os.ReadFile(curlFile)
+			if err != nil {
+				return err
+			}
+			command = string(b)
+		default:
+			return fmt.Errorf("provide a curl command or --%s", curlFileFlag)
+		}
+		follow := cfg.FollowRedirects
+		if err := curl.Parse(command, cfg); err != nil {
+			return err
+		}
+		if cmd.Flags().Changed(followFlag) {
+			cfg.FollowRedirects = follow
+		}
+		if !runCurl {
+			fmt.Fprintln(cmd.OutOrStdout(), shellJoin(vesselArgs(cmd)))
+			return nil
+		}
+		return run(cmd)
+	},
+}
+
+// vesselArgs returns the arguments of the vessel invocation equivalent to
+// the parsed curl command and the load flags provided.
+func vesselArgs(cmd *cobra.Command) []string {
+	args := []string{"vessel"}
+	flag := func(name, value string) {
+		args = append(args, "--"+name, value)
+	}
+	if cfg.Method != "GET" {
+		flag(methodFlag, cfg.Method)
+	}
+	for _, h := range cfg.Headers {
+		flag(headersFlag, csvQuote(h))
+	}
+	if cfg.Body != "" {
+		flag(bodyFlag, cfg.Body)
+	}
+	if cfg.BasicAuth != "" {
+		flag(basicAuthFlag, cfg.BasicAuth)
+	}
+	if cfg.UserAgent != "" {
+		flag(userAgentFlag, cfg.UserAgent)
+	}
+	if cfg.Insecure {
+		args = append(args, "--"+insecureFlag)
+	}
+	if cfg.Certificate != "" {
+		flag(certFlag, cfg.Certificate)
+	}
+	if cfg.PrivateKey != "" {
+		flag(keyFlag, cfg.PrivateKey)
+	}
+	for _, r := range cfg.Resolve {
+		flag(resolveFlag, r)
+	}
+	if !cfg.FollowRedirects {
+		args = append(args, "--"+followFlag+"=false")
+	}
+	if cfg.Timeout > 0 {
+		flag(timeoutFlag, cfg.Timeout.String())
+	}
+	if cfg.HTTP2 {
+		args = append(args, "--"+http2Flag)
+	}
+
+	// Carry the remaining flags given, which describe the load rather than
+	// the request.
+	request := map[string]bool{
+		methodFlag: true, headersFlag: true, bodyFlag: true, basicAuthFlag: true, userAgentFlag: true,
+		insecureFlag: true, certFlag: true, keyFlag: true, resolveFlag: true, followFlag: true,
+		timeoutFlag: true, http2Flag: true, curlFileFlag: true, runCurlFlag: true,
+	}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if request[f.Name] {
+			return
+		}
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range s.GetSlice() {
+				flag(f.Name, v)
+			}
+			return
+		}
+		if f.Value.Type() == "bool" {
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+			return
+		}
+		flag(f.Name, f.Value.String())
+	})
+	return append(args, cfg.Endpoint)
+}
+
+// csvQuote quotes a value of a string slice flag which would otherwise be
+// split on its commas.
+func csvQuote(s string) string {
+	if !strings.ContainsAny(s, `,"`) {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// shellJoin joins the arguments into a command line, single quoting those
+// which a shell would otherwise interpret.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = arg
+		if arg == "" || strings.ContainsFunc(arg, func(r rune) bool {
+			return !strings.ContainsRune("-_=/.:,@%+", r) && !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+		}) {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+func init() {
+	addConfigFlags(fromCurlCmd)
+	addOutputFlags(fromCurlCmd)
+	fromCurlCmd.Flags().StringVar(&curlFile, curlFileFlag, "", "Read the curl command from the file, - reads stdin")
+	fromCurlCmd.Flags().BoolVar(&runCurl, runCurlFlag, false, "Run the load test rather than printing the equivalent vessel invocation")
+	rootCmd.AddCommand(fromCurlCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/runner"
+)
+
+const (
+	// grpc flag long names
+	descriptorSetFlag = "descriptor-set"
+)
+
+var grpcDescriptorSet string
+
+// grpcCmd load tests a gRPC method.
+var grpcCmd = &cobra.Command{
+	Use:   "grpc URL METHOD",
+	Short: "Load test a unary or streaming gRPC method",
+	Long: `Grpc calls METHOD, given as package.Service/Method, of the server at URL.  An
+http:// URL speaks HTTP/2 without TLS (h2c), an https:// URL HTTP/2 over TLS.
+
+The method is described by the server's reflection service unless a
+descriptor set, written by protoc with --descriptor_set_out and
+--include_imports, is given with --descriptor-set.
+
+The request message is given in its JSON form with --body, an empty body
+sends an empty message.  Methods streaming requests are sent a JSON array
+of messages in turn, responses streamed back are read in full before the
+call is complete.  Placeholders of a --feeder are replaced in the body
+before it is encoded.
+
+Results are keyed by the gRPC status of each call rather than its HTTP
+status, use --check grpc-status=OK to count calls failing with any other
+status as failed checks.`,
+	Example: `  vessel grpc http://localhost:50051 helloworld.Greeter/SayHello --body '{"name": "vessel"}' -n 10000
+  vessel grpc https://api.example.com chat.Chat/Send --descriptor-set chat.protoset --body '[{"text": "a"}, {"text": "b"}]'
+  vessel grpc http://localhost:50051 users.Users/Get --body '{"id": "${id}"}' --feeder users.csv --check grpc-status=OK --threshold 'checks<1%'`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg.Endpoint = args[0]
+		encoder, err := runner.GRPC(cmd.Context(), cfg, args[1], grpcDescriptorSet)
+		if err != nil {
+			return err
+		}
+		return run(cmd, runner.WithRequestHooks(encoder))
+	},
+}
+
+func init() {
+	addConfigFlags(grpcCmd)
+	addOutputFlags(grpcCmd)
+	grpcCmd.Flags().StringVar(&grpcDescriptorSet, descriptorSetFlag, "", "Describe the method with a FileDescriptorSet (protoc --descriptor_set_out --include_imports) rather than server reflection")
+	rootCmd.AddCommand(grpcCmd)
+}
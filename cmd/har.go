@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"math"
+
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/coordinator"
+	"github.com/symonk/vessel/internal/distribution"
+	"github.com/symonk/vessel/internal/har"
+	"github.com/symonk/vessel/internal/runner"
+	"github.com/symonk/vessel/internal/scenario"
+)
+
+const (
+	// har flag long names
+	mixFlag          = "mix"
+	domainFlag       = "domain"
+	stripCookiesFlag = "strip-cookies"
+	weightFlag       = "weight"
+)
+
+var (
+	harMix          bool
+	harDomains      []string
+	harStripCookies bool
+	harWeights      []string
+)
+
+// harCmd runs the requests captured in a HAR file as a load test.
+var harCmd = &cobra.Command{
+	Use:   "har FILE",
+	Short: "Run the requests captured in a HAR file",
+	Long: `Har imports the requests (method, url, headers and body) captured in an HTTP
+Archive, such as one exported by a browser's developer tools, and sends them
+in the order they were captured.  With --mix requests are instead picked at
+random, in proportion to any --weight given.
+
+Every captured request is sent once unless -n or -d are provided, in which
+case they repeat as necessary.  Each request is reported as its own
+endpoint in the results.`,
+	Example: `  vessel har checkout.har -c 20 -d 1m --domain yourwebsite.com --strip-cookies --sessions
+  vessel har browse.har --mix --weight /api/search=5 --weight /static/=0 -n 10000`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var options []har.Option
+		if len(harDomains) > 0 {
+			options = append(options, har.WithDomains(harDomains...))
+		}
+		if harStripCookies {
+			options = append(options, har.WithoutCookies())
+		}
+		entries, err := har.ReadFile(args[0], options...)
+		if err != nil {
+			return err
+		}
+		if err := har.Weigh(entries, harWeights); err != nil {
+			return err
+		}
+		cfg.Endpoint = entries[0].URL
+		if cfg.Duration == 0 && !cmd.Flags().Changed(numberFlag) {
+			cfg.Amount = int64(len(entries))
+		}
+
+		var source coordinator.Source = scenario.NewSequence(entries)
+		if harMix {
+			if source, err = scenario.NewMix(entries, distribution.NewRand(cfg.Seed, math.MaxUint64-1)); err != nil {
+				return err
+			}
+		}
+		return run(cmd, runner.WithCoordinatorOptions(coordinator.WithSource(source)))
+	},
+}
+
+func init() {
+	addConfigFlags(harCmd)
+	addOutputFlags(harCmd)
+	harCmd.Flags().BoolVar(&harMix, mixFlag, false, "Pick requests at random in proportion to their weight rather than in sequence")
+	harCmd.Flags().StringSliceVar(&harDomains, domainFlag, nil, "Only send requests to the domains (or their subdomains), all are sent by default (appendable)")
+	harCmd.Flags().BoolVar(&harStripCookies, stripCookiesFlag, false, "Strip the cookies captured with each request")
+	harCmd.Flags().StringArrayVar(&harWeights, weightFlag, nil, "Weigh requests whose url contains the pattern with pattern=weight, defaults to 1 (appendable)")
+	rootCmd.AddCommand(harCmd)
+}
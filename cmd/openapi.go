@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/coordinator"
+	"github.com/symonk/vessel/internal/distribution"
+	"github.com/symonk/vessel/internal/openapi"
+	"github.com/symonk/vessel/internal/runner"
+	"github.com/symonk/vessel/internal/scenario"
+)
+
+const (
+	// openapi flag long names
+	operationFlag = "operation"
+	validateFlag  = "validate"
+	listFlag      = "list"
+)
+
+var (
+	apiOperations []string
+	apiWeights    []string
+	apiValidate   bool
+	apiList       bool
+)
+
+// openapiCmd runs a weighted mix of the operations of an OpenAPI
+// specification.
+var openapiCmd = &cobra.Command{
+	Use:   "openapi SPEC URL",
+	Short: "Run a weighted mix of the operations of an OpenAPI 3 specification",
+	Long: `OpenAPI synthesises a request for every operation of an OpenAPI 3 specification
+(in JSON or YAML) from its examples and schemas, filling in path parameters, required
+query and header parameters and JSON request bodies.  The requests are sent
+to URL, the server the specification describes, as a mix weighted by any
+--weight given.
+
+Every response is validated against the schema documented for its status
+code, responses which fail are reported under the "openapi" check.  Each
+operation is reported as its own endpoint in the results.
+
+Operations which modify data are included unless --operation selects
+only some, use --list to review the synthesised requests first.`,
+	Example: `  vessel openapi petstore.json http://localhost:8080/v1 --list
+  vessel openapi petstore.json http://localhost:8080/v1 -d 1m --weight listPets=10 --weight showPetById=3
+  vessel openapi petstore.json http://localhost:8080/v1 -n 5000 --operation listPets --operation showPetById`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := openapi.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		cfg.Endpoint = args[1]
+		operations, err := spec.Operations(cfg.Endpoint)
+		if err != nil {
+			return err
+		}
+		if operations, err = openapi.Select(operations, apiOperations); err != nil {
+			return err
+		}
+		if err := openapi.Weigh(operations, apiWeights); err != nil {
+			return err
+		}
+		if apiList {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "OPERATION\tWEIGHT\tREQUEST\tBODY")
+			for _, op := range operations {
+				fmt.Fprintf(w, "%s\t%g\t%s\t%s\n", op.ID, op.Request.Weight, op.Request.Name(), op.Request.Body)
+			}
+			return w.Flush()
+		}
+
+		requests := make([]scenario.Request, len(operations))
+		for i, op := range operations {
+			requests[i] = op.Request
+		}
+		mix, err := scenario.NewMix(requests, distribution.NewRand(cfg.Seed, math.MaxUint64-1))
+		if err != nil {
+			return err
+		}
+		options := []runner.Option{runner.WithCoordinatorOptions(coordinator.WithSource(mix))}
+		if apiValidate {
+			options = append(options, runner.WithCoordinatorOptions(coordinator.WithChecks(spec.NewValidator(operations))))
+		}
+		return run(cmd, options...)
+	},
+}
+
+func init() {
+	addConfigFlags(openapiCmd)
+	addOutputFlags(openapiCmd)
+	openapiCmd.Flags().StringArrayVar(&apiOperations, operationFlag, nil, "Only send the operation with the operationId (or \"METHOD /path\"), all are sent by default (appendable)")
+	openapiCmd.Flags().StringArrayVar(&apiWeights, weightFlag, nil, "Weigh an operation relative to the others with operation=weight, defaults to 1 (appendable)")
+	openapiCmd.Flags().BoolVar(&apiValidate, validateFlag, true, "Validate every response against the schema documented for its status code")
+	openapiCmd.Flags().BoolVar(&apiList, listFlag, false, "List the operations and their synthesised requests without sending them")
+	rootCmd.AddCommand(openapiCmd)
+}
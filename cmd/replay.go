@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/coordinator"
+	"github.com/symonk/vessel/internal/replay"
+	"github.com/symonk/vessel/internal/runner"
+)
+
+const (
+	// replay flag long names
+	speedFlag = "speed"
+)
+
+var replaySpeed float64
+
+// replayCmd replays the requests recorded in an access log.
+var replayCmd = &cobra.Command{
+	Use:   "replay LOG URL",
+	Short: "Replay the requests of an access log against a target",
+	Long: `Replay sends the requests recorded in an access log to the scheme and host of
+URL, carrying any headers and options given as usual.  Lines may be in the
+NGINX/Apache common or combined log format, or JSON objects holding the
+method, path (or uri/url) and timestamp (or time/ts) of each request.
+
+Requests are sent at their original offset from the first, divided by
+--speed, or as fast as possible with --speed 0.  Every request of the log is
+replayed once unless -n or -d are provided, in which case the log repeats
+as necessary.`,
+	Example: `  vessel replay access.log https://staging.yourwebsite.com
+  vessel replay access.log https://staging.yourwebsite.com --speed 10 -c 100
+  vessel replay requests.jsonl http://localhost:8080 --speed 0 -d 5m`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := replay.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		cfg.Endpoint = args[1]
+		if cfg.Duration == 0 && !cmd.Flags().Changed(numberFlag) {
+			cfg.Amount = int64(len(entries))
+		}
+		source := replay.NewSource(entries, replaySpeed)
+		return run(cmd, runner.WithCoordinatorOptions(coordinator.WithSource(source)))
+	},
+}
+
+func init() {
+	addConfigFlags(replayCmd)
+	addOutputFlags(replayCmd)
+	replayCmd.Flags().Float64Var(&replaySpeed, speedFlag, 1, "Multiplier of the original pace of the requests, 0 sends them as fast as possible")
+	rootCmd.AddCommand(replayCmd)
+}
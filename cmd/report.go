@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/hdrlog"
+)
+
+const (
+	// report flag long names
+	mergeFlag       = "merge"
+	percentilesFlag = "percentiles"
+)
+
+var (
+	mergePath   string
+	percentiles []float64
+)
+
+// reportCmd reads HdrHistogram interval logs written with --hdr-log (or by
+// any other HdrHistogram implementation) and recomputes the percentiles
+// across all of them.
+var reportCmd = &cobra.Command{
+	Use:   "report run.hlog [more.hlog...]",
+	Short: "Recompute percentiles from HdrHistogram interval logs",
+	Long: `Report reads one or more HdrHistogram interval logs, such as those written
+with --hdr-log, merges the histograms of every log losslessly and prints the
+percentiles of the latency and of each request phase.
+
+Logs from separate runs or machines can be combined into a single interval
+log with --merge.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logs := make([]*hdrlog.Log, 0, len(args))
+		for _, path := range args {
+			log, err := hdrlog.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			logs = append(logs, log)
+		}
+		if mergePath != "" {
+			f, err := os.Create(mergePath)
+			if err != nil {
+				return err
+			}
+			if err := hdrlog.WriteMerged(f, logs...); err != nil {
+				f.Close()
+				return fmt.Errorf("unable to write merged log: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+
+		merged := hdrlog.Merge(logs...)
+		tags := make([]string, 0, len(merged))
+		for tag := range merged {
+			tags = append(tags, tag)
+		}
+		// Order the known tags as they are written, any others after them.
+		slices.SortFunc(tags, func(a, b string) int {
+			ia, ib := slices.Index(hdrlog.Tags, a), slices.Index(hdrlog.Tags, b)
+			if ia == -1 {
+				ia = len(hdrlog.Tags)
+			}
+			if ib == -1 {
+				ib = len(hdrlog.Tags)
+			}
+			return cmp.Or(cmp.Compare(ia, ib), strings.Compare(a, b))
+		})
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprint(w, "histogram\tcount\tmin\tmean")
+		for _, p := range percentiles {
+			fmt.Fprintf(w, "\tp%s", strconv.FormatFloat(p, 'f', -1, 64))
+		}
+		fmt.Fprintln(w, "\tmax")
+		for _, tag := range tags {
+			h := merged[tag]
+			name := tag
+			if name == hdrlog.Latency {
+				name = "latency"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%.2fms", name, h.TotalCount(), millis(h.Min()), h.Mean()/1000)
+			for _, p := range percentiles {
+				fmt.Fprintf(w, "\t%s", millis(h.ValueAtQuantile(p)))
+			}
+			fmt.Fprintf(w, "\t%s\n", millis(h.Max()))
+		}
+		return w.Flush()
+	},
+}
+
+// millis formats a microsecond value as milliseconds.
+func millis(us int64) string {
+	return strconv.FormatFloat(float64(us)/1000, 'f', 2, 64) + "ms"
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&mergePath, mergeFlag, "", "Write the intervals of every log into a single interval log at the given path")
+	reportCmd.Flags().Float64SliceVar(&percentiles, percentilesFlag, []float64{50, 90, 95, 99, 99.9, 99.99}, "Percentiles to report")
+	rootCmd.AddCommand(reportCmd)
+}
@@ -5,34 +5,43 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/check"
 	"github.com/symonk/vessel/internal/collector"
 	"github.com/symonk/vessel/internal/config"
 	"github.com/symonk/vessel/internal/coordinator"
-	"github.com/symonk/vessel/internal/stats"
-	"github.com/symonk/vessel/internal/validation"
+	"github.com/symonk/vessel/internal/hdrlog"
+	"github.com/symonk/vessel/internal/metrics"
+	"github.com/symonk/vessel/internal/report"
+	"github.com/symonk/vessel/internal/runner"
+	"github.com/symonk/vessel/internal/telemetry"
+	"github.com/symonk/vessel/internal/threshold"
+	"github.com/symonk/vessel/internal/version"
 )
 
 // TODO: Wire in cobra auto completion
 // TODO: Consider iterations of config, allow ramp up/down to be iterated n times?
-// TODO: Super end game of 'distributed load' capabilities, maybe something like a swarm/master node that delegates?
-
-const (
-	// Version holds the current version of the binary.
-	// It is overridden at build time using -ldflags.
-	Version = "v0.0.1"
-)
 
 const (
 	// flag long names
 	versionFlag        = "version"
 	quietFlag          = "quiet"
 	maxRPSFlag         = "max-rps"
+	rateFlag           = "rate"
+	warmupFlag         = "warmup"
+	sessionsFlag       = "sessions"
+	userPoolFlag       = "user-pool"
+	feederFlag         = "feeder"
+	thinkFlag          = "think"
+	pacingFlag         = "pacing"
+	arrivalFlag        = "arrival"
+	seedFlag           = "seed"
+	shapeFlag          = "shape"
 	concurrencyFlag    = "concurrency"
 	durationFlag       = "duration"
 	methodFlag         = "method"
@@ -42,6 +51,13 @@ const (
 	userAgentFlag      = "agent"
 	basicAuthFlag      = "basic"
 	headersFlag        = "headers"
+	bodyFlag           = "body"
+	resolveFlag        = "resolve"
+	wsCorrelateFlag    = "ws-correlate"
+	wsNoReplyFlag      = "ws-no-reply"
+	streamFlag         = "stream"
+	streamTimeoutFlag  = "stream-timeout"
+	streamMaxFlag      = "stream-max-events"
 	numberFlag         = "number"
 	followFlag         = "follow"
 	showCfgFlag        = "show"
@@ -51,126 +67,181 @@ const (
 	keyFlag            = "key"
 	cacheFlag          = "cache"
 	debugFlag          = "debug"
-)
-
-const (
-	// HTTP Headers
-	userAgentHeader = "User-Agent"
+	outputFlag         = "output"
+	reportFlag         = "report"
+	prometheusFlag     = "prometheus"
+	traceparentFlag    = "traceparent"
+	otlpEndpointFlag   = "otlp-endpoint"
+	traceSampleFlag    = "trace-sample"
+	timeseriesFlag     = "timeseries"
+	correlationIDFlag  = "correlation-id"
+	bearerFileFlag     = "bearer-file"
+	hmacFlag           = "hmac"
+	checkFlag          = "check"
+	thresholdFlag      = "threshold"
+	rawLogFlag         = "raw-log"
+	rawLogSampleFlag   = "raw-log-sample"
+	hdrLogFlag         = "hdr-log"
 )
 
 var (
-	cfg     *config.Config
-	showCfg bool
+	// cfg is shared by every command running a load test, it is allocated
+	// upfront as the flags of each command bind to it in their init.
+	cfg        = &config.Config{}
+	showCfg    bool
+	output     string
+	reportPath string
+	prometheus string
+	timeseries string
+	rawLog     string
+	rawSample  float64
+	hdrLog     string
 )
 
-func init() {
-	cfg = &config.Config{}
-}
-
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:     "vessel",
 	Short:   "HTTP Benchmarking utility",
-	Version: Version,
+	Version: version.Version,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg.Endpoint = args[0]
-		if showCfg {
-			fmt.Println(cfg)
-		}
+		return run(cmd)
+	},
+}
 
-		if cfg.Amount == 0 && cfg.Duration == 0 {
-			return errors.New("-n or -d must not be zero when supplied")
-		}
+// run executes the load test described by cfg, honouring the output flags,
+// and evaluates its thresholds.  The extra options are applied to the run.
+func run(cmd *cobra.Command, extra ...runner.Option) error {
+	if showCfg {
+		fmt.Println(cfg)
+	}
 
-		// Do not allow spawning more workers than the number of requests
-		// to send for inefficiencies.
-		if cfg.Amount > 0 && cfg.Amount < int64(cfg.Concurrency) {
-			cfg.Concurrency = int(cfg.Amount)
-		}
+	// handle -q to suppress output if required.
+	var out io.Writer = os.Stdout
+	if cfg.QuietSet {
+		out = io.Discard
+	}
+	options := append([]runner.Option{runner.WithSummary(out)}, extra...)
 
-		// build the single req req to clone later.
-		// TODO: should not be the responsibility of a 'coordinator'.
-		req, err := coordinator.GenerateTemplateRequest(cfg)
-		if err != nil {
-			return fmt.Errorf("unable to create request: %v", err)
-		}
+	// Parse thresholds upfront, there is no point running a load test
+	// which cannot be evaluated.
+	thresholds, err := threshold.ParseAll(cfg.Thresholds)
+	if err != nil {
+		return err
+	}
+
+	// Likewise validate the configuration before creating any of the
+	// outputs, an invalid run must not truncate the files of a prior one.
+	if err := runner.Validate(cfg); err != nil {
+		return err
+	}
+	if _, err := check.ParseAll(cfg.Checks); err != nil {
+		return err
+	}
 
-		// Ensure the endpoint is actual a valid URL
-		// TODO: Do we want to enforce host/scheme specifics?
-		_, err = url.ParseRequestURI(cfg.Endpoint)
+	// Expose live metrics for scraping throughout the run if requested.
+	if prometheus != "" {
+		exporter := metrics.NewExporter(version.Version)
+		server, err := metrics.Serve(prometheus, exporter)
 		if err != nil {
-			return fmt.Errorf("bad endpoint provided: %v", err)
+			return fmt.Errorf("unable to serve prometheus metrics: %w", err)
 		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(ctx)
+		}()
+		options = append(options, runner.WithSinks(exporter))
+	}
 
-		// handle -q to suppress output if required.
-		var out io.Writer = os.Stdout
-		if cfg.QuietSet {
-			out = io.Discard
-		}
+	// Persist the machine readable results if requested, these can
+	// later be used by the compare command.
+	if output != "" {
+		options = append(options, runner.WithSinks(collector.NewJSONSink(output)))
+	}
 
-		// Append user provided HTTP headers if provided
-		// -H can be provided multiple times.
-		// Do this early so we can enforce the special case headers later.
-		if cmd.Flags().Changed(headersFlag) {
-			headers := validation.ParseHTTPHeaders(cfg.Headers).Clone()
-			req.Header = headers
-		}
+	// Render the self contained HTML report if requested.
+	if reportPath != "" {
+		options = append(options, runner.WithSinks(report.NewSink(reportPath)))
+	}
 
-		// Disallow negative MaxRPS.
-		if cmd.Flags().Changed(maxRPSFlag) {
-			cfg.MaxRPS = max(0, cfg.MaxRPS)
+	// Stream the per interval time series as the run progresses.
+	if timeseries != "" {
+		sink, err := collector.NewTimeSeriesSink(timeseries)
+		if err != nil {
+			return fmt.Errorf("unable to create time series: %w", err)
 		}
+		options = append(options, runner.WithSinks(sink))
+	}
 
-		// Disallow negative concurrency.
-		if cmd.Flags().Changed(concurrencyFlag) {
-			cfg.Concurrency = max(0, cfg.Concurrency)
+	// Log every (sampled) request for offline analysis.
+	if rawLog != "" {
+		sink, err := collector.NewRawLogSink(rawLog, rawSample)
+		if err != nil {
+			return fmt.Errorf("unable to create raw log: %w", err)
 		}
+		options = append(options, runner.WithSinks(sink))
+	}
 
-		// Handle basic auth if provided by the user
-		if cmd.Flags().Changed(basicAuthFlag) {
-			basicAuthUser, basicAuthPw, err := validation.ParseBasicAuth(cfg.BasicAuth)
-			if err != nil {
-				return err
-			}
-			req.SetBasicAuth(basicAuthUser, basicAuthPw)
+	// Export the latency and phase histograms as an HdrHistogram
+	// interval log, readable with vessel report.
+	if hdrLog != "" {
+		sink, err := hdrlog.NewSink(hdrLog, time.Now(), time.Second)
+		if err != nil {
+			return fmt.Errorf("unable to create histogram log: %w", err)
 		}
+		options = append(options, runner.WithSinks(sink))
+	}
 
-		// Handle custom host header if provided by the user
-		// Host header has special treatment and is not a traditional header
-		if cmd.Flags().Changed(hostHeaderFlag) {
-			req.Host = cfg.Host
+	// Propagate trace context and export client spans if requested.
+	if cfg.Traceparent || cfg.OTLPEndpoint != "" {
+		var exporter *telemetry.Exporter
+		if cfg.OTLPEndpoint != "" {
+			exporter = telemetry.NewExporter(cfg.OTLPEndpoint, version.Version)
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				if err := exporter.Shutdown(ctx); err != nil {
+					fmt.Fprintln(os.Stderr, "unable to export traces:", err)
+				}
+			}()
 		}
+		options = append(options, runner.WithCoordinatorOptions(coordinator.WithTracer(telemetry.NewTracer(exporter, cfg.TraceSampleRate))))
+	}
 
-		// Handle a custom user agent if provided by the user
-		// the tool user agent is always appended for server tracability.
-		uA := "vessel/" + Version
-		if cmd.Flags().Changed(userAgentFlag) {
-			cfg.UserAgent += fmt.Sprintf("%s ", uA)
-		}
-		req.Header.Set(userAgentHeader, cfg.UserAgent)
-
-		resultsChan := make(chan *stats.Stats, cfg.Concurrency)
-		collector := collector.New(resultsChan, out, cfg)
-
-		// Enable signal handling to abort when requested (gracefully)
-		// finish in flight requests and summarise work that was completed
-		// prior.
-		parent := cmd.Context()
-		ctx, cancel := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
-		defer cancel()
-
-		coordinator := coordinator.New(
-			ctx,
-			resultsChan,
-			cfg,
-			collector,
-			req,
-		)
-		coordinator.Wait()
-		close(resultsChan)
-		collector.Summarise()
+	// Enable signal handling to abort when requested (gracefully)
+	// finish in flight requests and summarise work that was completed
+	// prior.
+	parent := cmd.Context()
+	ctx, cancel := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	result, err := runner.Run(ctx, cfg, options...)
+	if err != nil {
+		return err
+	}
+	return evaluateThresholds(cmd, thresholds, result)
+}
+
+// evaluateThresholds reports any breached thresholds to stderr, returning
+// an error to exit non zero if any were.
+func evaluateThresholds(cmd *cobra.Command, thresholds threshold.Expression, result *collector.Result) error {
+	if len(thresholds) == 0 {
 		return nil
-	},
+	}
+	violations, err := thresholds.Evaluate(result)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		fmt.Fprintln(os.Stderr, "Thresholds breached:")
+		for _, v := range violations {
+			fmt.Fprintln(os.Stderr, "  ✗", v)
+		}
+		cmd.SilenceUsage = true
+		return errors.New("thresholds breached")
+	}
+	return nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -181,40 +252,15 @@ func ExecuteContext(ctx context.Context) error {
 }
 
 func init() {
-	rootCmd.Flags().BoolVar(&cfg.Debug, debugFlag, false, "Enabled enhanced debugging information (WARNING: noisy!)")
-	rootCmd.Flags().BoolVarP(&cfg.QuietSet, quietFlag, "q", false, "Suppresses output")
-	rootCmd.Flags().IntVarP(&cfg.MaxRPS, maxRPSFlag, "r", 0, "Rate limit requests per second")
-	rootCmd.Flags().IntVarP(&cfg.Concurrency, concurrencyFlag, "c", 10, "Number of concurrent workers dispatching requests")
-	rootCmd.Flags().DurationVarP(&cfg.Duration, durationFlag, "d", 0, "Duration to send requests for (must be parsable by time.ParseDuration)")
-	rootCmd.Flags().StringVarP(&cfg.Method, methodFlag, "m", "GET", "HTTP Verb to perform")
-	rootCmd.Flags().DurationVarP(&cfg.Timeout, timeoutFlag, "t", 0, "Per Request timeout before terminating the request (must be parsable by time.ParseDuration)")
-	rootCmd.Flags().BoolVar(&cfg.HTTP2, http2Flag, false, "Enable HTTP/2 support")
-	rootCmd.Flags().StringVar(&cfg.Host, hostHeaderFlag, "", "Set a custom HOST header")
-	rootCmd.Flags().StringVarP(&cfg.UserAgent, userAgentFlag, "u", "", "Set a custom user agent header, this is always suffixed with the tools user agent")
-	rootCmd.Flags().StringVarP(&cfg.BasicAuth, basicAuthFlag, "b", "", "Colon separated user:pass for basic auth header")
-	rootCmd.Flags().StringSliceVarP(&cfg.Headers, headersFlag, "H", make([]string, 0), "Colon separated header:value for arbitrary HTTP headers (appendable)")
-	rootCmd.Flags().Int64VarP(&cfg.Amount, numberFlag, "n", 50, "The total number of requests, cannot be used with -d")
-	rootCmd.Flags().BoolVarP(&cfg.FollowRedirects, followFlag, "f", true, "Automatically follow redirects")
-	rootCmd.Flags().BoolVarP(&showCfg, showCfgFlag, "s", false, "Print cfg to stdout on startup")
-	rootCmd.Flags().BoolVarP(&cfg.Insecure, insecureFlag, "i", false, "Do not verify server certificate and host name")
-	rootCmd.Flags().IntVar(&cfg.MaxConnections, maxConnectionsFlag, 1024, "Maximum connections (per host) the client will create/reuse")
-	// TODO: Document cache, need to implement it too.
-	rootCmd.Flags().BoolVar(&cfg.Cache, cacheFlag, false, "Cache DNS lookups to minimise time spent in DNS parts of each request")
-	rootCmd.Flags().StringVar(&cfg.Certificate, certFlag, "", "Public certificate for identification for mutual TLS")
-	rootCmd.Flags().StringVarP(&cfg.PrivateKey, keyFlag, "k", "", "Private key for mutual TLS")
+	addConfigFlags(rootCmd)
+	addOutputFlags(rootCmd)
 	// TODO: Consider --ca to specify a custom root CA bundle instead of skipping validation
 	// TODO: Consider --cert-password if supporting encrypted private keys
 
-	// Specify required flags
-	rootCmd.MarkFlagsMutuallyExclusive(durationFlag, numberFlag)
-
-	// Ensure if provided either of cert/key, that both are provided.
-	rootCmd.MarkFlagsRequiredTogether(certFlag, keyFlag)
-
 	// Only allow a single non flag argument, which is the url/endpoint.
 	rootCmd.Args = cobra.ExactArgs(1)
 
 	// Apply the current working version of vessel into the config
-	cfg.Version = Version
+	cfg.Version = version.Version
 
 }
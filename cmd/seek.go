@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/sweep"
+	"github.com/symonk/vessel/internal/threshold"
+)
+
+const (
+	// seek flag long names
+	minFlag       = "min"
+	maxFlag       = "max"
+	precisionFlag = "precision"
+	confirmFlag   = "confirm"
+)
+
+var (
+	seekBy        string
+	seekMin       int
+	seekMax       int
+	seekPrecision int
+	confirmations int
+)
+
+// seekCmd searches for the highest rate the target sustains within an SLO.
+var seekCmd = &cobra.Command{
+	Use:   "seek URL",
+	Short: "Find the maximum sustainable rate under an SLO",
+	Long: `Seek binary searches the arrival rate in requests per second (or the concurrency
+with --by concurrency) between --min and --max for the highest value which meets
+the SLO given with --threshold.  Each trial runs for -d (10s unless -d or -n are
+provided) and, when seeking a rate, must also achieve at least 95% of the
+requested rate.
+
+The search concludes once the range is within --precision, the highest passing
+value is then repeated --confirm times and must pass every time, otherwise the
+search continues beneath it.`,
+	Example: `  vessel seek https://yourwebsite.com --threshold 'p99<200ms && errors<1%' --max 2000 -c 200 -d 30s`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg.Endpoint = args[0]
+		if cfg.Duration == 0 && !cmd.Flags().Changed(numberFlag) {
+			cfg.Duration = defaultStepDuration
+		}
+		slo, err := threshold.ParseAll(cfg.Thresholds)
+		if err != nil {
+			return err
+		}
+		if len(slo) == 0 {
+			return errors.New("an SLO must be provided with --threshold")
+		}
+
+		var out io.Writer = os.Stdout
+		if cfg.QuietSet {
+			out = io.Discard
+		}
+		sweeper, err := sweep.New(cfg, seekBy,
+			sweep.WithSLO(slo),
+			sweep.WithConfirmations(confirmations),
+			sweep.WithStepFunc(func(p sweep.Point) {
+				fmt.Fprintf(out, "%s=%d: %d requests, %.2f/second, p99=%s\n", seekBy, p.Value, p.Requests, p.RPS, millis(p.P99))
+			}),
+		)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		result, err := sweeper.Seek(ctx, seekMin, seekMax, seekPrecision)
+		if result == nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		if werr := sweep.WriteSeek(out, result); werr != nil {
+			return werr
+		}
+		if err == nil && result.Value == 0 {
+			cmd.SilenceUsage = true
+			err = fmt.Errorf("no %s met the SLO", seekBy)
+		}
+		return err
+	},
+}
+
+func init() {
+	addConfigFlags(seekCmd)
+	seekCmd.Flags().StringVar(&seekBy, byFlag, sweep.Rate, "What to seek, either rate or concurrency")
+	seekCmd.Flags().IntVar(&seekMin, minFlag, 1, "Lower bound of the search, which must meet the SLO")
+	seekCmd.Flags().IntVar(&seekMax, maxFlag, 0, "Upper bound of the search")
+	seekCmd.Flags().IntVar(&seekPrecision, precisionFlag, 10, "Stop searching once the range is narrower than this")
+	seekCmd.Flags().IntVar(&confirmations, confirmFlag, 1, "Number of times the result is repeated to confirm it is sustainable")
+	_ = seekCmd.MarkFlagRequired(maxFlag)
+	rootCmd.AddCommand(seekCmd)
+}
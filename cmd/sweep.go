@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/symonk/vessel/internal/sweep"
+	"github.com/symonk/vessel/internal/threshold"
+)
+
+const (
+	// sweep flag long names
+	byFlag      = "by"
+	stepsFlag   = "steps"
+	fromFlag    = "from"
+	toFlag      = "to"
+	stepFlag    = "step"
+	minGainFlag = "min-gain"
+	csvFlag     = "csv"
+
+	// defaultStepDuration is how long each step runs for when neither -d
+	// nor -n are provided.
+	defaultStepDuration = 10 * time.Second
+)
+
+var (
+	sweepBy    string
+	sweepSteps []int
+	sweepFrom  int
+	sweepTo    int
+	sweepStep  int
+	minGain    float64
+	sweepCSV   string
+)
+
+// sweepCmd repeats the load test across a range of concurrency or arrival
+// rates to find where the target saturates.
+var sweepCmd = &cobra.Command{
+	Use:   "sweep URL",
+	Short: "Step concurrency or rate across a range to find the throughput knee",
+	Long: `Sweep runs the load test once per step, setting the concurrency (or the arrival
+rate with --by rate) to the value of the step.  Each step runs for -d (10s
+unless -d or -n are provided).
+
+The throughput and latency percentiles of every step are tabulated and the
+saturation point identified: the last step before throughput increased by
+less than --min-gain percent, or before any --threshold (the SLO) was breached.`,
+	Example: `  vessel sweep https://yourwebsite.com --steps 10,20,50,100,200 -d 30s --threshold 'p99<200ms'
+  vessel sweep https://yourwebsite.com --by rate --from 100 --to 1000 --step 100 -c 200 --csv curve.csv`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg.Endpoint = args[0]
+		if cfg.Duration == 0 && !cmd.Flags().Changed(numberFlag) {
+			cfg.Duration = defaultStepDuration
+		}
+		steps := sweepSteps
+		if len(steps) == 0 {
+			if !cmd.Flags().Changed(fromFlag) || !cmd.Flags().Changed(toFlag) {
+				return errors.New("either --steps or --from and --to must be provided")
+			}
+			var err error
+			if steps, err = sweep.Range(sweepFrom, sweepTo, sweepStep); err != nil {
+				return err
+			}
+		}
+		slo, err := threshold.ParseAll(cfg.Thresholds)
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+		if cfg.QuietSet {
+			out = io.Discard
+		}
+		sweeper, err := sweep.New(cfg, sweepBy,
+			sweep.WithSLO(slo),
+			sweep.WithMinGain(minGain/100),
+			sweep.WithStepFunc(func(p sweep.Point) {
+				fmt.Fprintf(out, "%s=%d: %d requests, %.2f/second, p99=%s\n", sweepBy, p.Value, p.Requests, p.RPS, millis(p.P99))
+			}),
+		)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		result, err := sweeper.Run(ctx, steps)
+		if result == nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		if werr := sweep.WriteTable(out, result); werr != nil {
+			return werr
+		}
+		if sweepCSV != "" {
+			if werr := sweep.WriteCSV(sweepCSV, result); werr != nil {
+				return fmt.Errorf("unable to write csv: %w", werr)
+			}
+		}
+		return err
+	},
+}
+
+func init() {
+	addConfigFlags(sweepCmd)
+	sweepCmd.Flags().StringVar(&sweepBy, byFlag, sweep.Concurrency, "What to step, either concurrency or rate")
+	sweepCmd.Flags().IntSliceVar(&sweepSteps, stepsFlag, nil, "Values of each step (e.g 10,20,50,100)")
+	sweepCmd.Flags().IntVar(&sweepFrom, fromFlag, 0, "First value of a linear range of steps")
+	sweepCmd.Flags().IntVar(&sweepTo, toFlag, 0, "Last value (inclusive) of a linear range of steps")
+	sweepCmd.Flags().IntVar(&sweepStep, stepFlag, 10, "Increment between the steps of a linear range")
+	sweepCmd.Flags().Float64Var(&minGain, minGainFlag, 5, "Minimum percentage increase in throughput for a step to be considered an improvement")
+	sweepCmd.Flags().StringVar(&sweepCSV, csvFlag, "", "Write the latency and throughput curve as CSV to the given file")
+	sweepCmd.MarkFlagsMutuallyExclusive(stepsFlag, fromFlag)
+	sweepCmd.MarkFlagsMutuallyExclusive(stepsFlag, toFlag)
+	rootCmd.AddCommand(sweepCmd)
+}
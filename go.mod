@@ -5,7 +5,10 @@ go 1.24.3
 require (
 	github.com/HdrHistogram/hdrhistogram-go v1.1.2
 	github.com/spf13/cobra v1.9.1
+	github.com/spf13/pflag v1.0.6
 	github.com/stretchr/testify v1.10.0
+	google.golang.org/protobuf v1.36.11
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -14,7 +17,5 @@ require (
 	github.com/google/pprof v0.0.0-20241023014458-598669927662 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
 	github.com/symonk/profiler v0.2.4 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,313 @@
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/symonk/vessel/internal/grpc"
+)
+
+// Check validates a response once its body has been read in full.  A
+// response failing a check is not a transport error, it is accounted for
+// separately so that degraded responses (such as a 200 with an error
+// payload) are not mistaken for successes.
+type Check interface {
+	// Name identifies the check in summaries, failures are grouped by it.
+	Name() string
+	// Check returns an error describing why the response failed the check.
+	Check(response *http.Response, body []byte) error
+}
+
+// Parse builds a check from its command line form of kind=argument:
+//
+//	status=200,201,3xx   the status code is one of the given codes or classes
+//	grpc-status=OK,5     the gRPC status is one of the given codes or names
+//	contains=text        the body contains the text
+//	regex=pattern        the body matches the regular expression
+//	jsonpath=$.a[0].b==v the JSON body holds v (a JSON literal or string) at the path
+//	header=Name          the response carries the header
+//	max-size=1024        the body is at most the given number of bytes
+//	schema=file.json     the JSON body validates against the JSON Schema in the file
+func Parse(spec string) (Check, error) {
+	kind, arg, ok := strings.Cut(spec, "=")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("check %q must be provided as kind=argument", spec)
+	}
+	switch kind {
+	case "status":
+		return Status(strings.Split(arg, ",")...)
+	case "grpc-status":
+		return GRPCStatus(strings.Split(arg, ",")...)
+	case "contains":
+		return Contains(arg), nil
+	case "regex":
+		return Regex(arg)
+	case "jsonpath":
+		path, want, ok := strings.Cut(arg, "==")
+		if !ok {
+			return nil, fmt.Errorf("jsonpath check %q must be provided as path==value", arg)
+		}
+		var v any
+		if err := json.Unmarshal([]byte(want), &v); err != nil {
+			// Not a JSON literal, treat it as a bare string.
+			v = want
+		}
+		return JSONPath(path, v)
+	case "header":
+		return Header(arg), nil
+	case "max-size":
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("max-size check %q must be a number of bytes", arg)
+		}
+		return MaxSize(n), nil
+	case "schema":
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read schema: %w", err)
+		}
+		schema, err := CompileSchema(b)
+		if err != nil {
+			return nil, err
+		}
+		return Schema(arg, schema), nil
+	default:
+		return nil, fmt.Errorf("unknown check %q", kind)
+	}
+}
+
+// ParseAll builds the checks from their command line forms.
+func ParseAll(specs []string) ([]Check, error) {
+	checks := make([]Check, 0, len(specs))
+	for _, spec := range specs {
+		c, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// named is a check implemented by a function.
+type named struct {
+	name string
+	fn   func(response *http.Response, body []byte) error
+}
+
+// Name implements Check.
+func (n named) Name() string { return n.name }
+
+// Check implements Check.
+func (n named) Check(response *http.Response, body []byte) error { return n.fn(response, body) }
+
+// Status checks the status code is one of codes, which may also be given
+// as a class such as 2xx.
+func Status(codes ...string) (Check, error) {
+	allowed := make(map[int]bool)
+	classes := make(map[int]bool)
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if len(code) == 3 && strings.HasSuffix(strings.ToLower(code), "xx") && code[0] >= '1' && code[0] <= '5' {
+			classes[int(code[0]-'0')] = true
+			continue
+		}
+		n, err := strconv.Atoi(code)
+		if err != nil || n < 100 || n > 599 {
+			return nil, fmt.Errorf("invalid status code %q", code)
+		}
+		allowed[n] = true
+	}
+	return named{name: "status=" + strings.Join(codes, ","), fn: func(response *http.Response, _ []byte) error {
+		if allowed[response.StatusCode] || classes[response.StatusCode/100] {
+			return nil
+		}
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}}, nil
+}
+
+// GRPCStatus checks the status of a gRPC call is one of codes, given by
+// name such as NOT_FOUND or by number.
+func GRPCStatus(codes ...string) (Check, error) {
+	allowed := make(map[int]bool)
+	for _, code := range codes {
+		n, err := grpc.ParseCode(code)
+		if err != nil {
+			return nil, err
+		}
+		allowed[n] = true
+	}
+	return named{name: "grpc-status=" + strings.Join(codes, ","), fn: func(response *http.Response, _ []byte) error {
+		code, message := grpc.Status(response)
+		if allowed[code] {
+			return nil
+		}
+		return fmt.Errorf("unexpected grpc status %s: %s", grpc.CodeName(code), message)
+	}}, nil
+}
+
+// Contains checks the body contains text.
+func Contains(text string) Check {
+	return named{name: "contains=" + text, fn: func(_ *http.Response, body []byte) error {
+		if bytes.Contains(body, []byte(text)) {
+			return nil
+		}
+		return fmt.Errorf("body does not contain %q", text)
+	}}
+}
+
+// Regex checks the body matches the regular expression.
+func Regex(pattern string) (Check, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex check: %w", err)
+	}
+	return named{name: "regex=" + pattern, fn: func(_ *http.Response, body []byte) error {
+		if re.Match(body) {
+			return nil
+		}
+		return fmt.Errorf("body does not match %q", pattern)
+	}}, nil
+}
+
+// JSONPath checks the JSON body holds want at path.  Numbers are compared
+// as float64, as decoded by encoding/json.
+func JSONPath(path string, want any) (Check, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := want.(int); ok {
+		want = float64(n)
+	}
+	name := fmt.Sprintf("jsonpath=%s==%v", path, want)
+	return named{name: name, fn: func(_ *http.Response, body []byte) error {
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return fmt.Errorf("body is not JSON: %w", err)
+		}
+		got, err := lookup(doc, steps)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("%s is %v, expected %v", path, got, want)
+		}
+		return nil
+	}}, nil
+}
+
+// Header checks the response carries the header.
+func Header(name string) Check {
+	return named{name: "header=" + name, fn: func(response *http.Response, _ []byte) error {
+		if _, ok := response.Header[http.CanonicalHeaderKey(name)]; ok {
+			return nil
+		}
+		return fmt.Errorf("missing header %s", name)
+	}}
+}
+
+// MaxSize checks the body is at most n bytes.
+func MaxSize(n int64) Check {
+	return named{name: "max-size=" + strconv.FormatInt(n, 10), fn: func(_ *http.Response, body []byte) error {
+		if int64(len(body)) <= n {
+			return nil
+		}
+		return fmt.Errorf("body of %d bytes exceeds %d", len(body), n)
+	}}
+}
+
+// Schema checks the JSON body validates against the schema.
+func Schema(name string, schema *JSONSchema) Check {
+	return named{name: "schema=" + name, fn: func(_ *http.Response, body []byte) error {
+		return validateJSON(schema, body)
+	}}
+}
+
+// validateJSON decodes body and validates it against the schema.
+func validateJSON(schema *JSONSchema, body []byte) error {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("body is not JSON: %w", err)
+	}
+	return schema.Validate(doc)
+}
+
+// step is a single step of a JSONPath, either a key or an index.
+type step struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// parsePath parses the simple dotted JSONPath subset of $.a.b[0]["c d"].
+func parsePath(path string) ([]step, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("jsonpath %q must begin with $", path)
+	}
+	var steps []step
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("jsonpath %q has an empty key", path)
+			}
+			steps = append(steps, step{key: rest[:end], isKey: true})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("jsonpath %q has an unterminated [", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if unquoted, err := strconv.Unquote(inner); err == nil {
+				steps = append(steps, step{key: unquoted, isKey: true})
+				continue
+			}
+			i, err := strconv.Atoi(inner)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("jsonpath %q has an invalid index %q", path, inner)
+			}
+			steps = append(steps, step{index: i})
+		default:
+			return nil, fmt.Errorf("jsonpath %q is invalid at %q", path, rest)
+		}
+	}
+	return steps, nil
+}
+
+// lookup walks the steps through the decoded JSON document.
+func lookup(doc any, steps []step) (any, error) {
+	for _, s := range steps {
+		if s.isKey {
+			object, ok := doc.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("expected an object for key %q", s.key)
+			}
+			if doc, ok = object[s.key]; !ok {
+				return nil, fmt.Errorf("missing key %q", s.key)
+			}
+			continue
+		}
+		array, ok := doc.([]any)
+		if !ok || s.index >= len(array) {
+			return nil, fmt.Errorf("missing index %d", s.index)
+		}
+		doc = array[s.index]
+	}
+	return doc, nil
+}
@@ -0,0 +1,69 @@
+package check
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecks(t *testing.T) {
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"X-Trace": []string{"abc"}},
+		Trailer:    http.Header{"Grpc-Status": []string{"0"}},
+	}
+	body := []byte(`{"status": "degraded", "items": [{"id": 1}, {"id": 2}], "a b": true}`)
+	tests := map[string]struct {
+		spec string
+		pass bool
+	}{
+		"status_match":        {spec: "status=201,200", pass: true},
+		"status_class":        {spec: "status=2xx", pass: true},
+		"status_mismatch":     {spec: "status=204", pass: false},
+		"grpc_status_name":    {spec: "grpc-status=ok", pass: true},
+		"grpc_status_number":  {spec: "grpc-status=5,0", pass: true},
+		"grpc_status_missing": {spec: "grpc-status=NOT_FOUND", pass: false},
+		"contains":            {spec: "contains=degraded", pass: true},
+		"contains_missing":    {spec: "contains=healthy", pass: false},
+		"regex":               {spec: `regex="id":\s*2`, pass: true},
+		"regex_mismatch":      {spec: `regex=^\[`, pass: false},
+		"jsonpath_string":     {spec: "jsonpath=$.status==ok", pass: false},
+		"jsonpath_quoted":     {spec: `jsonpath=$.status=="degraded"`, pass: true},
+		"jsonpath_number":     {spec: "jsonpath=$.items[1].id==2", pass: true},
+		"jsonpath_quoted_key": {spec: `jsonpath=$["a b"]==true`, pass: true},
+		"jsonpath_missing":    {spec: "jsonpath=$.items[5].id==2", pass: false},
+		"header":              {spec: "header=x-trace", pass: true},
+		"header_missing":      {spec: "header=X-Other", pass: false},
+		"max_size":            {spec: "max-size=1024", pass: true},
+		"max_size_exceeded":   {spec: "max-size=10", pass: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := Parse(tc.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tc.pass, c.Check(response, body) == nil)
+		})
+	}
+}
+
+func TestParseRejectsBadChecks(t *testing.T) {
+	tests := map[string]string{
+		"missing_argument": "status",
+		"unknown_kind":     "latency=10",
+		"bad_status":       "status=abc",
+		"bad_grpc_status":  "grpc-status=NOPE",
+		"bad_regex":        "regex=[",
+		"bad_jsonpath":     "jsonpath=status==ok",
+		"missing_value":    "jsonpath=$.status",
+		"bad_size":         "max-size=-1",
+		"missing_schema":   "schema=does-not-exist.json",
+	}
+	for name, spec := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(spec)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONSchema validates decoded JSON documents against a JSON Schema.
+//
+// The commonly used subset of the specification is supported: type, enum,
+// const, properties, required, additionalProperties, items, the numeric,
+// string and array bounds, pattern, allOf, anyOf, oneOf, not, the OpenAPI
+// nullable keyword and local $ref pointers.  Formats are not asserted.
+type JSONSchema struct {
+	root     any
+	node     any
+	patterns map[string]pattern // Compiled once, shared by every Sub schema.
+}
+
+// pattern is a compiled pattern keyword, or why it could not be compiled.
+type pattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// CompileSchema parses a JSON Schema document.
+func CompileSchema(b []byte) (*JSONSchema, error) {
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("unable to parse schema: %w", err)
+	}
+	return NewSchema(doc, doc), nil
+}
+
+// NewSchema returns the schema described by node, with local $ref pointers
+// resolved against root.  This allows validating against a schema embedded
+// within a larger document, such as an OpenAPI specification.
+//
+// The patterns of the document are compiled upfront, a pattern which does
+// not compile fails the values validated against it.
+func NewSchema(root, node any) *JSONSchema {
+	s := &JSONSchema{root: root, node: node, patterns: make(map[string]pattern)}
+	s.compile(root)
+	s.compile(node)
+	return s
+}
+
+// Sub returns the schema described by node, part of the same document,
+// sharing the compiled patterns.
+func (s *JSONSchema) Sub(node any) *JSONSchema {
+	return &JSONSchema{root: s.root, node: node, patterns: s.patterns}
+}
+
+// compile compiles the pattern keywords found beneath the node.
+func (s *JSONSchema) compile(node any) {
+	switch node := node.(type) {
+	case map[string]any:
+		for keyword, value := range node {
+			if p, ok := value.(string); ok && keyword == "pattern" {
+				if _, seen := s.patterns[p]; !seen {
+					re, err := regexp.Compile(p)
+					s.patterns[p] = pattern{re: re, err: err}
+				}
+				continue
+			}
+			s.compile(value)
+		}
+	case []any:
+		for _, value := range node {
+			s.compile(value)
+		}
+	}
+}
+
+// Validate validates the decoded JSON value.
+func (s *JSONSchema) Validate(v any) error {
+	return s.validate(s.node, v, "$", 0)
+}
+
+// maxDepth guards against cyclic $ref pointers.
+const maxDepth = 64
+
+// validate validates v against the schema node at the given path.
+func (s *JSONSchema) validate(node, v any, path string, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("%s: schema nested too deeply", path)
+	}
+	schema, ok := node.(map[string]any)
+	if !ok {
+		// true/false schemas.
+		if b, isBool := node.(bool); isBool && !b {
+			return fmt.Errorf("%s: not allowed", path)
+		}
+		return nil
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := s.resolve(ref)
+		if err != nil {
+			return err
+		}
+		return s.validate(resolved, v, path, depth+1)
+	}
+	if v == nil && schema["nullable"] == true {
+		return nil
+	}
+	if t, ok := schema["type"]; ok && !matchesType(t, v) {
+		return fmt.Errorf("%s: expected %v, got %s", path, t, typeOf(v))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !contains(enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, enum)
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, v) {
+		return fmt.Errorf("%s: expected %v, got %v", path, c, v)
+	}
+
+	switch value := v.(type) {
+	case map[string]any:
+		if err := s.validateObject(schema, value, path, depth); err != nil {
+			return err
+		}
+	case []any:
+		if err := s.validateArray(schema, value, path, depth); err != nil {
+			return err
+		}
+	case string:
+		if err := s.validateString(schema, value, path); err != nil {
+			return err
+		}
+	case float64:
+		if err := validateNumber(schema, value, path); err != nil {
+			return err
+		}
+	}
+	return s.validateCombinators(schema, v, path, depth)
+}
+
+// validateObject applies the object keywords.
+func (s *JSONSchema) validateObject(schema, value map[string]any, path string, depth int) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := value[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for name, property := range value {
+		if sub, ok := properties[name]; ok {
+			if err := s.validate(sub, property, path+"."+name, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if additional, ok := schema["additionalProperties"]; ok {
+			if err := s.validate(additional, property, path+"."+name, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateArray applies the array keywords.
+func (s *JSONSchema) validateArray(schema map[string]any, value []any, path string, depth int) error {
+	if n, ok := number(schema, "minItems"); ok && float64(len(value)) < n {
+		return fmt.Errorf("%s: expected at least %v items, got %d", path, n, len(value))
+	}
+	if n, ok := number(schema, "maxItems"); ok && float64(len(value)) > n {
+		return fmt.Errorf("%s: expected at most %v items, got %d", path, n, len(value))
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			if err := s.validate(items, item, fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateString applies the string keywords.
+func (s *JSONSchema) validateString(schema map[string]any, value, path string) error {
+	length := float64(utf8.RuneCountInString(value))
+	if n, ok := number(schema, "minLength"); ok && length < n {
+		return fmt.Errorf("%s: expected at least %v characters", path, n)
+	}
+	if n, ok := number(schema, "maxLength"); ok && length > n {
+		return fmt.Errorf("%s: expected at most %v characters", path, n)
+	}
+	if p, ok := schema["pattern"].(string); ok {
+		compiled, ok := s.patterns[p]
+		if !ok {
+			// A node from outside the document compiled upfront.
+			compiled.re, compiled.err = regexp.Compile(p)
+		}
+		if compiled.err != nil {
+			return fmt.Errorf("%s: invalid pattern %q: %w", path, p, compiled.err)
+		}
+		if !compiled.re.MatchString(value) {
+			return fmt.Errorf("%s: %q does not match %q", path, value, p)
+		}
+	}
+	return nil
+}
+
+// validateNumber applies the numeric keywords.
+func validateNumber(schema map[string]any, value float64, path string) error {
+	if n, ok := number(schema, "minimum"); ok {
+		// OpenAPI 3.0 expresses exclusivity as a boolean modifier.
+		if schema["exclusiveMinimum"] == true && value <= n || value < n {
+			return fmt.Errorf("%s: %v is below the minimum %v", path, value, n)
+		}
+	}
+	if n, ok := number(schema, "maximum"); ok {
+		if schema["exclusiveMaximum"] == true && value >= n || value > n {
+			return fmt.Errorf("%s: %v is above the maximum %v", path, value, n)
+		}
+	}
+	if n, ok := number(schema, "exclusiveMinimum"); ok && value <= n {
+		return fmt.Errorf("%s: %v must be above %v", path, value, n)
+	}
+	if n, ok := number(schema, "exclusiveMaximum"); ok && value >= n {
+		return fmt.Errorf("%s: %v must be below %v", path, value, n)
+	}
+	if n, ok := number(schema, "multipleOf"); ok && n != 0 {
+		if q := value / n; math.Abs(q-math.Round(q)) > 1e-9 {
+			return fmt.Errorf("%s: %v is not a multiple of %v", path, value, n)
+		}
+	}
+	return nil
+}
+
+// validateCombinators applies allOf, anyOf, oneOf and not.
+func (s *JSONSchema) validateCombinators(schema map[string]any, v any, path string, depth int) error {
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			if err := s.validate(sub, v, path, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		if s.matching(anyOf, v, path, depth) == 0 {
+			return fmt.Errorf("%s: does not match any of the schemas", path)
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		if n := s.matching(oneOf, v, path, depth); n != 1 {
+			return fmt.Errorf("%s: matches %d schemas, expected exactly one", path, n)
+		}
+	}
+	if not, ok := schema["not"]; ok && s.validate(not, v, path, depth+1) == nil {
+		return fmt.Errorf("%s: must not match the schema", path)
+	}
+	return nil
+}
+
+// matching returns the number of schemas v validates against.
+func (s *JSONSchema) matching(schemas []any, v any, path string, depth int) int {
+	n := 0
+	for _, sub := range schemas {
+		if s.validate(sub, v, path, depth+1) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// resolve resolves a local JSON pointer such as #/components/schemas/Pet.
+func (s *JSONSchema) resolve(ref string) (any, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q, only local references are supported", ref)
+	}
+	node := s.root
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		if node, ok = object[token]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return node, nil
+}
+
+// matchesType reports whether v is of the given type, or one of the types.
+func matchesType(t, v any) bool {
+	switch t := t.(type) {
+	case string:
+		got := typeOf(v)
+		return got == t || t == "number" && got == "integer"
+	case []any:
+		for _, candidate := range t {
+			if matchesType(candidate, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// typeOf returns the JSON Schema type of the decoded JSON value.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+// number returns the numeric keyword from the schema if present.
+func number(schema map[string]any, keyword string) (float64, bool) {
+	n, ok := schema[keyword].(float64)
+	return n, ok
+}
+
+// contains reports whether v is deeply equal to any of the values.
+func contains(values []any, v any) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
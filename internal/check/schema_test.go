@@ -0,0 +1,75 @@
+package check
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const petSchema = `{
+	"$defs": {"tag": {"type": "string", "minLength": 1}},
+	"type": "object",
+	"required": ["id", "name"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "pattern": "^[a-z]+$"},
+		"status": {"enum": ["available", "sold"]},
+		"tags": {"type": "array", "maxItems": 2, "items": {"$ref": "#/$defs/tag"}},
+		"owner": {"type": "string", "nullable": true}
+	}
+}`
+
+func TestSchemaValidation(t *testing.T) {
+	schema, err := CompileSchema([]byte(petSchema))
+	require.NoError(t, err)
+	tests := map[string]struct {
+		doc  string
+		pass bool
+	}{
+		"valid":                 {doc: `{"id": 1, "name": "rex", "status": "sold", "tags": ["a"], "owner": null}`, pass: true},
+		"missing_required":      {doc: `{"id": 1}`, pass: false},
+		"wrong_type":            {doc: `{"id": "1", "name": "rex"}`, pass: false},
+		"not_integer":           {doc: `{"id": 1.5, "name": "rex"}`, pass: false},
+		"below_minimum":         {doc: `{"id": 0, "name": "rex"}`, pass: false},
+		"pattern":               {doc: `{"id": 1, "name": "Rex"}`, pass: false},
+		"enum":                  {doc: `{"id": 1, "name": "rex", "status": "lost"}`, pass: false},
+		"too_many_items":        {doc: `{"id": 1, "name": "rex", "tags": ["a", "b", "c"]}`, pass: false},
+		"ref":                   {doc: `{"id": 1, "name": "rex", "tags": [""]}`, pass: false},
+		"additional_properties": {doc: `{"id": 1, "name": "rex", "age": 3}`, pass: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var doc any
+			require.NoError(t, json.Unmarshal([]byte(tc.doc), &doc))
+			assert.Equal(t, tc.pass, schema.Validate(doc) == nil)
+		})
+	}
+}
+
+func TestSchemaCombinators(t *testing.T) {
+	schema, err := CompileSchema([]byte(`{"oneOf": [{"type": "string"}, {"type": "integer"}], "not": {"const": 3}}`))
+	require.NoError(t, err)
+	assert.NoError(t, schema.Validate("a"))
+	assert.NoError(t, schema.Validate(float64(2)))
+	assert.Error(t, schema.Validate(float64(3)))
+	assert.Error(t, schema.Validate(true))
+}
+
+func TestSchemaCompilesPatternsUpfront(t *testing.T) {
+	schema, err := CompileSchema([]byte(`{"properties": {"a": {"pattern": "^a+$"}, "b": {"items": {"pattern": "(?=b)"}}}}`))
+	require.NoError(t, err)
+	require.Contains(t, schema.patterns, "^a+$")
+	assert.NotNil(t, schema.patterns["^a+$"].re)
+
+	// Patterns which do not compile fail the values validated against them.
+	assert.NoError(t, schema.Validate(map[string]any{"a": "aa"}))
+	assert.Error(t, schema.Validate(map[string]any{"a": "b"}))
+	assert.ErrorContains(t, schema.Validate(map[string]any{"b": []any{"b"}}), "invalid pattern")
+
+	// A node from outside the document still has its pattern applied.
+	sub := schema.Sub(map[string]any{"pattern": "^a+$"})
+	assert.Error(t, sub.Validate("b"))
+}
@@ -7,11 +7,15 @@ import (
 	"io"
 	"math"
 	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/grpc"
 	"github.com/symonk/vessel/internal/stats"
+	"github.com/symonk/vessel/internal/websocket"
 )
 
 // Summariser is the interface for something which can display summary
@@ -20,8 +24,30 @@ type Summariser interface {
 	Summarise()
 }
 
+// ResultCollector collects the results of a run, from which a summary
+// and the final Result can be produced.
 type ResultCollector interface {
 	Summariser
+	Finish() (*Result, error)
+}
+
+// Option is a functional option for the EventCollector.
+type Option func(*EventCollector)
+
+// WithSink registers a sink to consume the stream of results alongside
+// the EventCollector.
+func WithSink(s Sink) Option {
+	return func(e *EventCollector) {
+		e.sinks = append(e.sinks, s)
+	}
+}
+
+// WithInterval sets the width of the intervals results are bucketed into,
+// defaults to one second.
+func WithInterval(width time.Duration) Option {
+	return func(e *EventCollector) {
+		e.intervalWidth = width
+	}
 }
 
 // EventCollector collects execution data during the lifecycle of
@@ -55,20 +81,103 @@ type EventCollector struct {
 	waitingConnect       time.Duration
 	newConnections       int64
 	waitingGetConn       time.Duration
+	errored              int64
+	failed               int64
+	late                 int64
+	dropped              int64
+	checkFailures        map[string]int64
+	endpoints            map[string]*endpointStats
+	intervals            *IntervalRecorder
+	timeline             []Interval
+	intervalWidth        time.Duration
+	sinks                []Sink
+	fanout               *fanout
 	resultsCh            chan *stats.Stats
+	finished             time.Time
+	done                 chan struct{}
+	warmup               *warmupStats
+	measuring            bool
+	websocket            *websocketStats
+	stream               *streamStats
+}
+
+// warmupStats accounts for the requests of the warm-up, which are reported
+// separately from the measured phase.
+type warmupStats struct {
+	requests int64
+	errored  int64
+	latency  *hdrhistogram.Histogram
 }
 
-func New(ingress chan *stats.Stats, writer io.Writer, cfg *config.Config) *EventCollector {
+// endpointStats tracks the latency and error information for a single
+// endpoint targeted throughout the run.
+type endpointStats struct {
+	latency *hdrhistogram.Histogram
+	errored int64
+	failed  int64
+}
+
+// OtherEndpoints is the endpoint under which the requests to endpoints
+// beyond the first maxEndpoints are counted together.
+const OtherEndpoints = "other"
+
+// maxEndpoints is the number of distinct endpoints tracked individually, a
+// histogram apiece, such as when replaying traffic to many paths.
+const maxEndpoints = 100
+
+// endpointFor returns the stats of the endpoint, creating them on first
+// sight, or those of OtherEndpoints once maxEndpoints are tracked.
+func endpointFor(endpoints map[string]*endpointStats, name string) *endpointStats {
+	if s, ok := endpoints[name]; ok {
+		return s
+	}
+	// OtherEndpoints is not itself counted towards the limit.
+	tracked := len(endpoints)
+	if _, ok := endpoints[OtherEndpoints]; ok {
+		tracked--
+	}
+	if tracked >= maxEndpoints {
+		name = OtherEndpoints
+		if s, ok := endpoints[name]; ok {
+			return s
+		}
+	}
+	s := &endpointStats{latency: newLatencyHistogram()}
+	endpoints[name] = s
+	return s
+}
+
+func New(ingress chan *stats.Stats, writer io.Writer, cfg *config.Config, options ...Option) *EventCollector {
+	now := time.Now()
 	e := &EventCollector{
 		counter:              NewStatusCodeCounter(),
 		cfg:                  cfg,
 		writer:               writer,
-		collectionRegistered: time.Now(),
-		latency:              *hdrhistogram.New(1, 60000, 3),
+		collectionRegistered: now,
+		latency:              *newLatencyHistogram(),
 		rawErrors:            nil,
 		errGrouper:           NewErrGrouper(),
+		checkFailures:        make(map[string]int64),
+		endpoints:            make(map[string]*endpointStats),
+		intervalWidth:        time.Second,
 		resultsCh:            ingress,
+		done:                 make(chan struct{}),
+		measuring:            cfg.Warmup == 0 && cfg.WarmupRequests == 0,
+	}
+	if websocket.IsWebSocket(cfg.Endpoint) {
+		e.websocket = newWebSocketStats()
+	}
+	if cfg.GRPC {
+		e.counter.names = grpc.CodeName
+	}
+	if cfg.Stream != "" {
+		e.stream = newStreamStats()
+	}
+	for _, opt := range options {
+		opt(e)
 	}
+	e.intervals = NewIntervalRecorder(now, e.intervalWidth)
+	e.fanout = newFanout(e.sinks)
 	go e.listen()
 	return e
 }
@@ -76,38 +185,159 @@ func New(ingress chan *stats.Stats, writer io.Writer, cfg *config.Config) *Event
 // listen waits for stats from the worker pool before incremental internal
 // values in preparation for summary generation later.
 //
-// For now this is a single listener, but eventually the channel can be fanned
-// out for reads and merged back into a single result chan for efficiency.
+// Every result (and every interval as it closes) is also fanned out to the
+// registered sinks.  Intervals are closed on a ticker so that sinks are kept
+// up to date even when results are sparse.
 func (e *EventCollector) listen() {
-	for stat := range e.resultsCh {
-		err := stat.Err
-		if err != nil {
-			e.rawErrors = errors.Join(e.rawErrors, err)
-			e.errGrouper.Record(err)
+	defer func() {
+		e.finished = time.Now()
+		e.timeline = e.intervals.Flush(e.finished)
+		e.emitIntervals()
+		e.fanout.drain()
+		close(e.done)
+	}()
+	ticker := time.NewTicker(e.intervalWidth)
+	defer ticker.Stop()
+	for {
+		select {
+		case stat, ok := <-e.resultsCh:
+			if !ok {
+				return
+			}
+			e.record(stat)
+		case now := <-ticker.C:
+			if e.measuring {
+				e.intervals.Advance(now)
+			}
+		}
+		e.emitIntervals()
+	}
+}
+
+// emitIntervals fans out any intervals closed since the last call.
+func (e *EventCollector) emitIntervals() {
+	for _, i := range e.intervals.Closed() {
+		e.fanout.interval(i)
+	}
+}
+
+// record accounts for a single result.
+func (e *EventCollector) record(stat *stats.Stats) {
+	if stat.Dropped > 0 {
+		e.dropped += stat.Dropped
+		return
+	}
+	if stat.Warmup {
+		e.recordWarmup(stat)
+		return
+	}
+	if !e.measuring {
+		// The measured phase begins with its first request, intervals and
+		// throughput are relative to it.
+		e.measuring = true
+		e.collectionRegistered = stat.Began
+		e.intervals = NewIntervalRecorder(stat.Began, e.intervalWidth)
+	}
+	e.seen += 1
+	if stat.Delay > lateAfter {
+		e.late++
+	}
+	e.intervals.Record(stat)
+	e.fanout.result(stat)
+	endpoint := endpointFor(e.endpoints, stat.Endpoint)
+	if e.websocket != nil {
+		e.websocket.record(stat)
+	}
+	if e.stream != nil {
+		e.stream.record(stat)
+	}
+	if err := stat.Err; err != nil {
+		e.rawErrors = errors.Join(e.rawErrors, err)
+		e.errGrouper.Record(err)
+		e.errored++
+		endpoint.errored++
+		return
+	}
+	e.waitingDns += stat.TimeOnDns
+	e.waitingTls += stat.TimeOnTls
+	e.waitingConnect += stat.TimeOnConnect
+	e.waitingGetConn += stat.TimeOnConn
+
+	// We have a semi-successful response (in that sense that no error was returned)
+	// Capture the histogram data for the latency of the response.
+	e.latency.RecordValue(stat.Latency.Microseconds())
+	endpoint.latency.RecordValue(stat.Latency.Microseconds())
+	e.counter.Increment(stat.StatusCode)
+
+	// A response failing checks is accounted for separately from errors,
+	// the request itself succeeded but the response was not as expected.
+	if len(stat.FailedChecks) > 0 {
+		e.failed++
+		endpoint.failed++
+		for _, name := range stat.FailedChecks {
+			e.checkFailures[name]++
 		}
-		e.waitingDns += stat.TimeOnDns
-		e.waitingTls += stat.TimeOnTls
-		e.waitingConnect += stat.TimeOnConnect
-		e.waitingGetConn += stat.TimeOnConn
+	}
 
-		// We have a semi-successful response (in that sense that no error was returned)
-		// Capture the histogram data for the latency of the response.
-		e.latency.RecordValue(stat.Latency.Milliseconds())
-		e.counter.Increment(stat.StatusCode)
+	// Track the byte size of the initial request aswell as content type of
+	// the response from the server.  The collector is not responsible for
+	// reading the response, this should be handled elsewhere to ensure safety
+	// of reading responses and avoiding attempting multiple reads etc.
+	e.bytesReceived += stat.BytesReceived
+	e.bytesSent += stat.BytesSent
 
-		// Track the byte size of the initial request aswell as content type of
-		// the response from the server.  The collector is not responsible for
-		// reading the response, this should be handled elsewhere to ensure safety
-		// of reading responses and avoiding attempting multiple reads etc.
-		e.bytesReceived += stat.BytesReceived
-		e.bytesSent += stat.BytesSent
+	// Keep track of keep-alives etc, useful for detecting if there is an issue
+	// with your server, or our client.
+	if stat.ReusedConn == stats.NotReused {
+		e.newConnections++
+	}
+}
+
+// recordWarmup accounts for a single result of the warm-up, these are not
+// passed on to the sinks.
+func (e *EventCollector) recordWarmup(stat *stats.Stats) {
+	if e.warmup == nil {
+		e.warmup = &warmupStats{latency: newLatencyHistogram()}
+	}
+	e.warmup.requests++
+	if stat.Err != nil {
+		e.warmup.errored++
+		return
+	}
+	_ = e.warmup.latency.RecordValue(stat.Latency.Microseconds())
+}
 
-		// Keep track of keep-alives etc, useful for detecting if there is an issue
-		// with your server, or our client.
-		e.newConnections += stat.ReusedConn
-		e.seen += 1
+// warmupSummary describes the requests of the warm-up, or nothing if there
+// was none.
+func (e *EventCollector) warmupSummary() string {
+	if e.warmup == nil {
+		return ""
 	}
+	return fmt.Sprintf("%d requests excluded, %d errored, p50=%.2fms, p99=%.2fms",
+		e.warmup.requests, e.warmup.errored,
+		toMillis(e.warmup.latency.ValueAtQuantile(50)), toMillis(e.warmup.latency.ValueAtQuantile(99)))
+}
+
+// Wait blocks until all results have been consumed from the ingress
+// channel.  The ingress channel must be closed by the caller.
+func (e *EventCollector) Wait() {
+	<-e.done
+}
 
+// elapsed returns the wall time from the collector being registered
+// until all results were consumed.
+func (e *EventCollector) elapsed() time.Duration {
+	return e.finished.Sub(e.collectionRegistered)
+}
+
+// lateAfter is how long after it was due a scheduled arrival may be sent
+// before it is counted as late.
+const lateAfter = time.Millisecond
+
+// newLatencyHistogram returns a histogram suitable for recording latencies
+// in microseconds, upto a maximum of one minute.
+func newLatencyHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, 60_000_000, 3)
 }
 
 // Summarise calculates the final summary prior to exiting.
@@ -121,6 +351,7 @@ func (e *EventCollector) listen() {
 // TODO: Wire in latency breakdowns from httptrace for:
 // TODO: DNS resolution, TCP connection time, TLS handshake time, Time to first byte, total response time.
 func (e *EventCollector) Summarise() {
+	e.Wait()
 	// TODO: Be smarter here, capture terminal width and size appropriately.
 	const tmpl = `
  _   _                    _ 
@@ -141,6 +372,21 @@ Sent		{{.BytesSent}} ({{.SPS}})
 Throughput	{{.BytesTotal}} ({{.TPS}})
 Latency:	{{.Latency}}
 Errored:	{{.Errors}}
+{{- if .Warmup}}
+Warm-up:	{{.Warmup}}
+{{- end}}
+{{- if .Checks}}
+Checks:		{{.Checks}}
+{{- end}}
+{{- if .Arrivals}}
+Arrivals:	{{.Arrivals}}
+{{- end}}
+{{- if .WebSocket}}
+WebSocket:	{{.WebSocket}}
+{{- end}}
+{{- if .Stream}}
+Stream:		{{.Stream}}
+{{- end}}
 Conns:		{{.OpenedConnections}}
 Waiting:	{{.Waiting}}
 
@@ -150,15 +396,15 @@ Waiting:	{{.Waiting}}
 	// TODO: Priority focus on decoupling and improving collection and summarisation.
 	// TODO: This logic is a hack and a mess right now.
 
-	wall := time.Since(e.collectionRegistered)
+	wall := e.elapsed()
 	elapsedSeconds := wall.Seconds()
-	latency := fmt.Sprintf("max=%dms, avg=%fms, p50=%dms, p90=%dms, p95=%dms, p99=%dms",
-		e.latency.Max(),
-		e.latency.Mean(),
-		e.latency.ValueAtQuantile(50),
-		e.latency.ValueAtQuantile(90),
-		e.latency.ValueAtQuantile(95),
-		e.latency.ValueAtQuantile(99),
+	latency := fmt.Sprintf("max=%.2fms, avg=%.2fms, p50=%.2fms, p90=%.2fms, p95=%.2fms, p99=%.2fms",
+		toMillis(e.latency.Max()),
+		e.latency.Mean()/1000,
+		toMillis(e.latency.ValueAtQuantile(50)),
+		toMillis(e.latency.ValueAtQuantile(90)),
+		toMillis(e.latency.ValueAtQuantile(95)),
+		toMillis(e.latency.ValueAtQuantile(99)),
 	)
 
 	// calculate the total number of requests processed by the total seconds of execution
@@ -169,9 +415,10 @@ Waiting:	{{.Waiting}}
 	// calculate the total bytes received and sent, aswell as the rate in
 	// which transfer was happening per second.
 	// TODO: Prio - this is completely broken!
-	receivedSecond := (e.bytesReceived / int64(elapsedSeconds))
+	wholeSeconds := max(1, int64(elapsedSeconds))
+	receivedSecond := (e.bytesReceived / wholeSeconds)
 	receivedMb := e.bytesReceived / 1_000_000
-	sentSecond := (e.bytesSent / int64(elapsedSeconds))
+	sentSecond := (e.bytesSent / wholeSeconds)
 	sentMb := e.bytesSent / 1_000_000
 	bytesTotal := receivedMb + sentMb
 	totalSecond := bytesTotal / 1_000_000
@@ -197,11 +444,10 @@ Waiting:	{{.Waiting}}
 	)
 
 	s := &Summary{
-		Host:      e.cfg.Endpoint,
-		Duration:  e.cfg.Duration.String(),
-		Count:     e.latency.TotalCount(),
-		PerSecond: float64(seenPerSecond),
-		// TODO: Less than millisecond precision support.
+		Host:              e.cfg.Endpoint,
+		Duration:          e.cfg.Duration.String(),
+		Count:             e.seen,
+		PerSecond:         float64(seenPerSecond),
 		Latency:           latency,
 		BytesReceived:     fmt.Sprintf("%dMB", receivedMb),
 		BytesSent:         fmt.Sprintf("%dMB", sentMb),
@@ -210,6 +456,11 @@ Waiting:	{{.Waiting}}
 		TPS:               fmt.Sprintf("%dMB/s", totalSecond),
 		RawErrors:         e.rawErrors,
 		Errors:            e.errGrouper.String(),
+		Checks:            e.checksSummary(),
+		Arrivals:          arrivalsSummary(e.late, e.dropped, e.seen),
+		Warmup:            e.warmupSummary(),
+		WebSocket:         e.websocketSummary(wall),
+		Stream:            e.streamSummary(wall),
 		RealTime:          wall,
 		Results:           e.counter,
 		Workers:           e.cfg.Concurrency,
@@ -231,3 +482,57 @@ Waiting:	{{.Waiting}}
 		fmt.Println("unable to show summary", outErr)
 	}
 }
+
+// checksSummary describes the responses which failed checks, or nothing
+// if no checks failed.
+func (e *EventCollector) checksSummary() string {
+	if e.failed == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(e.checkFailures))
+	for name := range e.checkFailures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	groups := make([]string, 0, len(names))
+	for _, name := range names {
+		groups = append(groups, fmt.Sprintf("%s(%d)", name, e.checkFailures[name]))
+	}
+	return fmt.Sprintf("Failed: %d (%.2f%%): %s", e.failed, ratio(e.failed, e.seen)*100, strings.Join(groups, ", "))
+}
+
+// arrivalsSummary describes the scheduled arrivals which were sent late or
+// dropped, or nothing if all were sent on time.
+func arrivalsSummary(late, dropped, requests int64) string {
+	if late == 0 && dropped == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Late: %d (%.2f%%), Dropped: %d (every worker was busy, raise -c)",
+		late, ratio(late, requests)*100, dropped)
+}
+
+// websocketSummary describes the connections of a WebSocket run, or
+// nothing otherwise.
+func (e *EventCollector) websocketSummary(elapsed time.Duration) string {
+	if e.websocket == nil {
+		return ""
+	}
+	r, err := e.websocket.result(elapsed)
+	if err != nil {
+		return err.Error()
+	}
+	return r.String()
+}
+
+// streamSummary describes the streamed responses of a run, or nothing if
+// responses were not streamed.
+func (e *EventCollector) streamSummary(elapsed time.Duration) string {
+	if e.stream == nil {
+		return ""
+	}
+	r, err := e.stream.result(elapsed)
+	if err != nil {
+		return err.Error()
+	}
+	return r.String()
+}
@@ -58,28 +58,32 @@ func (e *ErrorGrouper) Record(err error) {
 	if e == nil || err == nil {
 		return
 	}
+	e.store[Classify(err)].Add(1)
+}
+
+// Classify categorises an error into one of the known error types.
+func Classify(err error) ErrorType {
 	switch {
 	case errors.Is(err, context.Canceled):
 		// The process was likely interrupted by a sigterm.  Requests in flight
 		// or potentially ones that have not yet been sent will quickly fill
 		// up this bucket.  This is NOT a case where the server was slow to respond,
 		// that difference is important in reporting.
-		e.store[Cancelled].Add(1)
+		return Cancelled
 	case errors.Is(err, context.DeadlineExceeded):
 		// The time specified for a particular request likely was exceeded.  The
 		// server is more than likely failing to respond within the clients expectations.
-		e.store[Timeout].Add(1)
+		return Timeout
 
 	case strings.Contains(err.Error(), "connection refused"), strings.Contains(err.Error(), "connection reset by peer"):
 		// connection refused: TCP connection failures, nothing listening.
 		// connection reset by peer: TCP connect success, server crashed/closed/rejected/RST.
-		e.store[Connection].Add(1)
+		return Connection
 	// TODO: Reading required on the *net.OpError and various other potential concrete error
 	// types exposed by Go's stdlib.
 	default:
-		e.store[Unknown].Add(1)
+		return Unknown
 	}
-
 }
 
 // String implements fmt.Stringer and provides a useful summary of the
@@ -93,3 +97,12 @@ func (e *ErrorGrouper) String() string {
 	all := timedOut + cancelled + unknown + connection
 	return fmt.Sprintf("Total: %d: Timeout(%d), Cancelled(%d), Connection(%d), Unknown(%d)", all, timedOut, cancelled, connection, unknown)
 }
+
+// Counts returns the number of errors recorded for each error type.
+func (e *ErrorGrouper) Counts() map[ErrorType]int64 {
+	counts := make(map[ErrorType]int64, len(e.store))
+	for k, v := range e.store {
+		counts[k] = v.Load()
+	}
+	return counts
+}
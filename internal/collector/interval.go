@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"slices"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+// Interval summarises the requests which completed within a fixed window
+// of the run.  Latencies are in microseconds.
+type Interval struct {
+	Offset   time.Duration `json:"offset"`
+	Requests int64         `json:"requests"`
+	Errors   int64         `json:"errors"`
+	RPS      float64       `json:"rps"`
+	Mean     float64       `json:"mean"`
+	P50      int64         `json:"p50"`
+	P90      int64         `json:"p90"`
+	P99      int64         `json:"p99"`
+	Max      int64         `json:"max"`
+	// Latency is the histogram of the interval, it is only set on the
+	// intervals delivered to sinks which must not modify it.
+	Latency *hdrhistogram.Histogram `json:"-"`
+}
+
+// IntervalRecorder buckets results into consecutive fixed width intervals
+// relative to the start of the run.  Only the current interval retains a
+// histogram, previous intervals are kept as summaries to keep memory usage
+// flat for long running tests.
+//
+// IntervalRecorder is not safe for concurrent use.
+type IntervalRecorder struct {
+	start     time.Time
+	width     time.Duration
+	current   int
+	requests  int64
+	errors    int64
+	latency   *hdrhistogram.Histogram
+	intervals []Interval
+	// closed holds the histograms of the intervals closed since the
+	// previous call to Closed.
+	closed  []*hdrhistogram.Histogram
+	emitted int
+}
+
+// NewIntervalRecorder instantiates a new IntervalRecorder and returns a
+// ptr to it.
+func NewIntervalRecorder(start time.Time, width time.Duration) *IntervalRecorder {
+	return &IntervalRecorder{
+		start:   start,
+		width:   width,
+		latency: newIntervalHistogram(),
+	}
+}
+
+// newIntervalHistogram returns a histogram for the latencies of a single
+// interval.
+func newIntervalHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, 60_000_000, 2)
+}
+
+// Record accounts for a single result in the interval it completed in.
+// Results are expected to arrive (roughly) in order, a straggler from an
+// already closed interval is accounted for in the current interval.
+func (i *IntervalRecorder) Record(stat *stats.Stats) {
+	completed := time.Now()
+	if !stat.Began.IsZero() {
+		completed = stat.Began.Add(stat.Latency)
+	}
+	i.Advance(completed)
+	i.requests++
+	if stat.Err != nil {
+		i.errors++
+		return
+	}
+	_ = i.latency.RecordValue(stat.Latency.Microseconds())
+}
+
+// Flush closes the current (likely partial) interval at the end of the
+// run and returns all intervals recorded.
+func (i *IntervalRecorder) Flush(end time.Time) []Interval {
+	i.Advance(end)
+	if i.requests > 0 {
+		partial := end.Sub(i.start) - time.Duration(i.current)*i.width
+		i.close(max(partial, time.Millisecond))
+	}
+	return i.intervals
+}
+
+// Closed returns the intervals closed since the previous call, along with
+// their latency histograms.
+func (i *IntervalRecorder) Closed() []Interval {
+	closed := slices.Clone(i.intervals[i.emitted:])
+	for n := range closed {
+		closed[n].Latency = i.closed[n]
+	}
+	i.emitted = len(i.intervals)
+	i.closed = nil
+	return closed
+}
+
+// Advance closes intervals until the one containing the given time is
+// current.
+func (i *IntervalRecorder) Advance(at time.Time) {
+	index := int(at.Sub(i.start) / i.width)
+	for i.current < index {
+		i.close(i.width)
+	}
+}
+
+// close summarises the current interval, which lasted for the given
+// duration, and moves onto the next.
+func (i *IntervalRecorder) close(lasted time.Duration) {
+	i.intervals = append(i.intervals, Interval{
+		Offset:   time.Duration(i.current) * i.width,
+		Requests: i.requests,
+		Errors:   i.errors,
+		RPS:      float64(i.requests) / lasted.Seconds(),
+		Mean:     i.latency.Mean(),
+		P50:      i.latency.ValueAtQuantile(50),
+		P90:      i.latency.ValueAtQuantile(90),
+		P99:      i.latency.ValueAtQuantile(99),
+		Max:      i.latency.Max(),
+	})
+	i.current++
+	i.requests, i.errors = 0, 0
+	// The histogram is handed out by Closed, the next interval needs its own.
+	i.closed = append(i.closed, i.latency)
+	i.latency = newIntervalHistogram()
+}
@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+func TestIntervalsAreBucketedBySecond(t *testing.T) {
+	start := time.Now()
+	r := NewIntervalRecorder(start, time.Second)
+	r.Record(&stats.Stats{Began: start, Latency: 10 * time.Millisecond})
+	r.Record(&stats.Stats{Began: start.Add(100 * time.Millisecond), Latency: 20 * time.Millisecond})
+	// nothing completes in the second interval.
+	r.Record(&stats.Stats{Began: start.Add(2 * time.Second), Latency: 30 * time.Millisecond, Err: errors.New("boom")})
+	intervals := r.Flush(start.Add(2500 * time.Millisecond))
+
+	assert.Len(t, intervals, 3)
+	assert.Equal(t, int64(2), intervals[0].Requests)
+	assert.Equal(t, 2.0, intervals[0].RPS)
+	assert.InDelta(t, 20_000, intervals[0].P99, 100)
+	assert.Equal(t, int64(0), intervals[1].Requests)
+	assert.Equal(t, int64(1), intervals[2].Requests)
+	assert.Equal(t, int64(1), intervals[2].Errors)
+	assert.Equal(t, 2*time.Second, intervals[2].Offset)
+	assert.Equal(t, 2.0, intervals[2].RPS)
+}
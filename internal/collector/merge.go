@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/symonk/vessel/internal/grpc"
+)
+
+// MergeResults merges the results of runs which took place concurrently,
+// for example on separate machines, into a single result.  Counters are
+// summed and latency histograms merged losslessly, rates are recomputed
+// over the overall wall time.
+//
+// Intervals are not merged as they cannot be merged losslessly from their
+// summaries, the merged result has none.
+func MergeResults(results ...*Result) (*Result, error) {
+	if len(results) == 0 {
+		return nil, errors.New("no results to merge")
+	}
+	first := results[0]
+	merged := &Result{
+		Version:       first.Version,
+		Config:        first.Config,
+		Endpoint:      first.Endpoint,
+		Started:       first.Started,
+		StatusCodes:   make(map[int]int64),
+		ErrorGroups:   make(map[string]int64),
+		CheckFailures: make(map[string]int64),
+		Endpoints:     make(map[string]*EndpointResult),
+	}
+	var (
+		finished  time.Time
+		websocket *websocketStats
+		stream    *streamStats
+	)
+	latency := newLatencyHistogram()
+	endpoints := make(map[string]*endpointStats)
+	for _, r := range results {
+		if r.Started.Before(merged.Started) {
+			merged.Started = r.Started
+		}
+		if end := r.Started.Add(r.Elapsed); end.After(finished) {
+			finished = end
+		}
+		merged.Requests += r.Requests
+		merged.Errors += r.Errors
+		merged.Failed += r.Failed
+		merged.Late += r.Late
+		merged.Dropped += r.Dropped
+		sum(merged.StatusCodes, r.StatusCodes)
+		sum(merged.ErrorGroups, r.ErrorGroups)
+		sum(merged.CheckFailures, r.CheckFailures)
+		h, err := r.Latency.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode latency: %w", err)
+		}
+		latency.Merge(h)
+		if ws := r.WebSocket; ws != nil {
+			if websocket == nil {
+				websocket = newWebSocketStats()
+			}
+			h, err := ws.Handshake.Decode()
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode handshake latency: %w", err)
+			}
+			websocket.handshake.Merge(h)
+			websocket.connections += ws.Connections
+			websocket.received += ws.Received
+			sum(websocket.closeCodes, ws.CloseCodes)
+		}
+		if r.Stream != nil {
+			if stream == nil {
+				stream = newStreamStats()
+			}
+			if err := stream.merge(r.Stream); err != nil {
+				return nil, err
+			}
+		}
+
+		for name, e := range r.Endpoints {
+			s := endpointFor(endpoints, name)
+			h, err := e.Latency.Decode()
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode latency of %s: %w", name, err)
+			}
+			s.latency.Merge(h)
+			s.errored += e.Errors
+			s.failed += e.Failed
+		}
+	}
+
+	merged.Elapsed = finished.Sub(merged.Started)
+	merged.RPS = perSecond(merged.Requests, merged.Elapsed)
+	merged.ErrorRate = ratio(merged.Errors, merged.Requests)
+	merged.FailureRate = ratio(merged.Failed, merged.Requests)
+	var err error
+	if merged.Latency, err = NewLatencyResult(latency); err != nil {
+		return nil, err
+	}
+	if websocket != nil {
+		if merged.WebSocket, err = websocket.result(merged.Elapsed); err != nil {
+			return nil, err
+		}
+	}
+	if stream != nil {
+		if merged.Stream, err = stream.result(merged.Elapsed); err != nil {
+			return nil, err
+		}
+	}
+	for name, s := range endpoints {
+		l, err := NewLatencyResult(s.latency)
+		if err != nil {
+			return nil, err
+		}
+		requests := s.latency.TotalCount() + s.errored
+		merged.Endpoints[name] = &EndpointResult{
+			Requests: requests,
+			Errors:   s.errored,
+			Failed:   s.failed,
+			RPS:      perSecond(requests, merged.Elapsed),
+			Latency:  l,
+		}
+	}
+	return merged, nil
+}
+
+// sum adds the counts of src into dst.
+func sum[K comparable](dst, src map[K]int64) {
+	for k, v := range src {
+		dst[k] += v
+	}
+}
+
+// WriteResult writes a concise human readable summary of the result, used
+// where the full summary of the EventCollector is unavailable such as for
+// merged results.
+func WriteResult(w io.Writer, r *Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Target:\t%s\n", r.Endpoint)
+	fmt.Fprintf(tw, "WallTime:\t%s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "Requests:\t%d (%.2f/second)\n", r.Requests, r.RPS)
+	fmt.Fprintf(tw, "Latency:\tmax=%.2fms, avg=%.2fms, p50=%.2fms, p90=%.2fms, p95=%.2fms, p99=%.2fms\n",
+		toMillis(r.Latency.Max), r.Latency.Mean/1000, toMillis(r.Latency.P50), toMillis(r.Latency.P90), toMillis(r.Latency.P95), toMillis(r.Latency.P99))
+	fmt.Fprintf(tw, "Errored:\t%d (%.2f%%) %s\n", r.Errors, r.ErrorRate*100, counts(r.ErrorGroups))
+	if r.Failed > 0 {
+		fmt.Fprintf(tw, "Checks:\tFailed: %d (%.2f%%) %s\n", r.Failed, r.FailureRate*100, counts(r.CheckFailures))
+	}
+	if arrivals := arrivalsSummary(r.Late, r.Dropped, r.Requests); arrivals != "" {
+		fmt.Fprintf(tw, "Arrivals:\t%s\n", arrivals)
+	}
+	codes := slices.Sorted(maps.Keys(r.StatusCodes))
+	status := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if r.Config != nil && r.Config.GRPC {
+			status = append(status, fmt.Sprintf("[%s]: %d", grpc.CodeName(code), r.StatusCodes[code]))
+			continue
+		}
+		status = append(status, fmt.Sprintf("[%d]: %d", code, r.StatusCodes[code]))
+	}
+	fmt.Fprintf(tw, "Status:\t%s\n", strings.Join(status, ", "))
+	if r.WebSocket != nil {
+		fmt.Fprintf(tw, "WebSocket:\t%s\n", r.WebSocket)
+	}
+	if r.Stream != nil {
+		fmt.Fprintf(tw, "Stream:\t%s\n", r.Stream)
+	}
+	return tw.Flush()
+}
+
+// counts formats the non zero counts as name(count) ordered by name.
+func counts(m map[string]int64) string {
+	var parts []string
+	for _, name := range slices.Sorted(maps.Keys(m)) {
+		if m[name] > 0 {
+			parts = append(parts, name+"("+strconv.FormatInt(m[name], 10)+")")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+// run collects the latencies, in milliseconds, into a result.
+func run(t *testing.T, latencies []int, errored int) *Result {
+	t.Helper()
+	ingress := make(chan *stats.Stats)
+	c := New(ingress, io.Discard, &config.Config{Endpoint: "http://localhost"})
+	for _, l := range latencies {
+		ingress <- &stats.Stats{Began: time.Now(), StatusCode: 200, Latency: time.Duration(l) * time.Millisecond}
+	}
+	for range errored {
+		ingress <- &stats.Stats{Began: time.Now(), Err: errors.New("dial tcp: connection refused")}
+	}
+	close(ingress)
+	result, err := c.Finish()
+	require.NoError(t, err)
+	return result
+}
+
+func TestMergeResults(t *testing.T) {
+	a := run(t, []int{10, 20, 30}, 1)
+	b := run(t, []int{40, 50}, 0)
+
+	merged, err := MergeResults(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), merged.Requests)
+	assert.Equal(t, int64(1), merged.Errors)
+	assert.Equal(t, int64(5), merged.StatusCodes[200])
+	assert.InDelta(t, 1.0/6, merged.ErrorRate, 0.001)
+	assert.InDelta(t, 10_000, merged.Latency.Min, 100)
+	assert.InDelta(t, 50_000, merged.Latency.Max, 100)
+	assert.InDelta(t, 30_000, merged.Latency.P50, 100)
+	assert.Nil(t, merged.Intervals)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteResult(&buf, merged))
+	assert.Contains(t, buf.String(), "Requests:  6")
+}
+
+func TestMergeResultsRequiresResults(t *testing.T) {
+	_, err := MergeResults()
+	assert.Error(t, err)
+}
+
+func TestMergeWebSocketResults(t *testing.T) {
+	connect := func(handshake time.Duration, received int64, closeCode int) *Result {
+		ingress := make(chan *stats.Stats)
+		c := New(ingress, io.Discard, &config.Config{Endpoint: "ws://localhost"})
+		ingress <- &stats.Stats{Began: time.Now(), StatusCode: 101, Handshake: handshake, Received: received}
+		ingress <- &stats.Stats{Began: time.Now(), CloseCode: closeCode, Err: errors.New("closed")}
+		close(ingress)
+		result, err := c.Finish()
+		require.NoError(t, err)
+		return result
+	}
+	a, b := connect(10*time.Millisecond, 2, 1011), connect(30*time.Millisecond, 3, 1001)
+	require.NotNil(t, a.WebSocket)
+	assert.Equal(t, int64(1), a.WebSocket.Connections)
+	assert.Equal(t, map[int]int64{1011: 1}, a.WebSocket.CloseCodes)
+
+	merged, err := MergeResults(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), merged.WebSocket.Connections)
+	assert.Equal(t, int64(5), merged.WebSocket.Received)
+	assert.Equal(t, map[int]int64{1001: 1, 1011: 1}, merged.WebSocket.CloseCodes)
+	assert.InDelta(t, 30_000, merged.WebSocket.Handshake.Max, 100)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteResult(&buf, merged))
+	assert.Contains(t, buf.String(), "closed by server: 1001(1), 1011(1)")
+
+	assert.Nil(t, run(t, []int{10}, 0).WebSocket)
+}
+
+func TestWriteResultNamesGRPCStatusCodes(t *testing.T) {
+	ingress := make(chan *stats.Stats)
+	c := New(ingress, io.Discard, &config.Config{Endpoint: "http://localhost/echo.Echo/Say", GRPC: true})
+	ingress <- &stats.Stats{Began: time.Now(), StatusCode: 0}
+	ingress <- &stats.Stats{Began: time.Now(), StatusCode: 5}
+	close(ingress)
+	result, err := c.Finish()
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int64{0: 1, 5: 1}, result.StatusCodes)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteResult(&buf, result))
+	assert.Contains(t, buf.String(), "[OK]: 1, [NOT_FOUND]: 1")
+}
+
+func TestMergeStreamResults(t *testing.T) {
+	stream := func(events int64, gap time.Duration, truncated bool) *Result {
+		ingress := make(chan *stats.Stats)
+		c := New(ingress, io.Discard, &config.Config{Endpoint: "http://localhost/sse", Stream: config.StreamSSE})
+		gaps := hdrhistogram.New(1, 60_000_000, 2)
+		require.NoError(t, gaps.RecordValues(gap.Microseconds(), events-1))
+		ingress <- &stats.Stats{Began: time.Now(), StatusCode: 200, Latency: time.Second, Events: events, FirstEvent: 50 * time.Millisecond, EventGaps: gaps, StreamTruncated: truncated}
+		ingress <- &stats.Stats{Began: time.Now(), Err: errors.New("dial tcp: connection refused")}
+		close(ingress)
+		result, err := c.Finish()
+		require.NoError(t, err)
+		return result
+	}
+	a, b := stream(3, 10*time.Millisecond, false), stream(5, 30*time.Millisecond, true)
+	require.NotNil(t, a.Stream)
+	assert.Equal(t, int64(1), a.Stream.Streams)
+	assert.Equal(t, int64(3), a.Stream.Events)
+
+	merged, err := MergeResults(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), merged.Stream.Streams)
+	assert.Equal(t, int64(8), merged.Stream.Events)
+	assert.Equal(t, int64(1), merged.Stream.Truncated)
+	assert.InDelta(t, 30_000, merged.Stream.Gap.Max, 100)
+	assert.InDelta(t, 1_000_000, merged.Stream.Duration.Max, 1000)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteResult(&buf, merged))
+	assert.Contains(t, buf.String(), "Streams: 2, events 8")
+
+	assert.Nil(t, run(t, []int{10}, 0).Stream)
+}
+
+func TestEndpointsBeyondTheCapAreCountedAsOther(t *testing.T) {
+	collect := func(first, last int) *Result {
+		ingress := make(chan *stats.Stats)
+		c := New(ingress, io.Discard, &config.Config{Endpoint: "http://localhost"})
+		for i := first; i < last; i++ {
+			ingress <- &stats.Stats{Began: time.Now(), StatusCode: 200, Latency: time.Millisecond, Endpoint: fmt.Sprintf("GET http://localhost/%d", i)}
+		}
+		close(ingress)
+		result, err := c.Finish()
+		require.NoError(t, err)
+		return result
+	}
+	a := collect(0, maxEndpoints+50)
+	assert.Len(t, a.Endpoints, maxEndpoints+1)
+	assert.Contains(t, a.Endpoints, "GET http://localhost/0")
+	assert.Equal(t, int64(50), a.Endpoints[OtherEndpoints].Requests)
+
+	merged, err := MergeResults(a, collect(maxEndpoints, maxEndpoints+80))
+	require.NoError(t, err)
+	assert.Len(t, merged.Endpoints, maxEndpoints+1)
+	assert.Equal(t, int64(maxEndpoints+130), merged.Requests)
+	assert.Equal(t, int64(130), merged.Endpoints[OtherEndpoints].Requests)
+}
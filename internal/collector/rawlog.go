@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/symonk/vessel/internal/stats"
+)
+
+// rawLogBuffer is the size of the write buffer of the raw log, large
+// enough that writes rarely reach the disk.
+const rawLogBuffer = 256 << 10
+
+// RawRecord is a single request as written to the raw log, one JSON object
+// per line.  Durations are in microseconds.
+type RawRecord struct {
+	Timestamp     time.Time `json:"ts"`
+	Worker        int       `json:"worker"`
+	Target        string    `json:"target"`
+	Status        int       `json:"status,omitempty"`
+	Latency       int64     `json:"latency_us"`
+	DNS           int64     `json:"dns_us"`
+	Connect       int64     `json:"connect_us"`
+	TLS           int64     `json:"tls_us"`
+	GetConn       int64     `json:"get_conn_us"`
+	FirstByte     int64     `json:"ttfb_us"`
+	BytesSent     int64     `json:"bytes_sent"`
+	BytesReceived int64     `json:"bytes_received"`
+	Reused        bool      `json:"reused"`
+	ErrorClass    string    `json:"error_class,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	FailedChecks  []string  `json:"failed_checks,omitempty"`
+}
+
+// NewRawRecord converts the stats of a single request into a RawRecord.
+func NewRawRecord(stat *stats.Stats) RawRecord {
+	r := RawRecord{
+		Timestamp:     stat.Began,
+		Worker:        stat.Worker,
+		Target:        stat.Endpoint,
+		Status:        stat.StatusCode,
+		Latency:       stat.Latency.Microseconds(),
+		DNS:           stat.TimeOnDns.Microseconds(),
+		Connect:       stat.TimeOnConnect.Microseconds(),
+		TLS:           stat.TimeOnTls.Microseconds(),
+		GetConn:       stat.TimeOnConn.Microseconds(),
+		FirstByte:     stat.TimeToFirstByte.Microseconds(),
+		BytesSent:     stat.BytesSent,
+		BytesReceived: stat.BytesReceived,
+		Reused:        stat.ReusedConn == stats.WasReused,
+		FailedChecks:  stat.FailedChecks,
+	}
+	if stat.Err != nil {
+		r.ErrorClass = Classify(stat.Err)
+		r.Error = stat.Err.Error()
+	}
+	return r
+}
+
+// RawLogSink writes every request as a RawRecord in JSON Lines format.
+// Writes are buffered and happen on the sink's own goroutine so they do
+// not slow down the collector.
+//
+// A sample rate below one writes only that fraction of successful
+// requests, errored requests and those failing checks are always written.
+type RawLogSink struct {
+	NopSink
+	f      *os.File
+	buf    *bufio.Writer
+	enc    *json.Encoder
+	sample float64
+	err    error
+}
+
+// NewRawLogSink creates (or truncates) the file at path and returns a ptr
+// to the RawLogSink writing to it.
+func NewRawLogSink(path string, sample float64) (*RawLogSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriterSize(f, rawLogBuffer)
+	return &RawLogSink{f: f, buf: buf, enc: json.NewEncoder(buf), sample: sample}, nil
+}
+
+// OnResult implements Sink.
+func (r *RawLogSink) OnResult(stat *stats.Stats) {
+	if r.err != nil {
+		return
+	}
+	sampled := r.sample >= 1 || rand.Float64() < r.sample
+	if !sampled && stat.Err == nil && len(stat.FailedChecks) == 0 {
+		return
+	}
+	r.err = r.enc.Encode(NewRawRecord(stat))
+}
+
+// OnFinish implements Sink, flushing and closing the file.
+func (r *RawLogSink) OnFinish(*Result) error {
+	if r.err == nil {
+		r.err = r.buf.Flush()
+	}
+	if err := r.f.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+func readRawLog(t *testing.T, path string) []RawRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var records []RawRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r RawRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestRawLogWritesEveryRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "raw.jsonl")
+	sink, err := NewRawLogSink(path, 1)
+	require.NoError(t, err)
+	began := time.Now()
+	sink.OnResult(&stats.Stats{
+		Worker:          2,
+		Endpoint:        "GET http://localhost/",
+		Began:           began,
+		Latency:         1500 * time.Microsecond,
+		StatusCode:      200,
+		TimeOnDns:       100 * time.Microsecond,
+		TimeToFirstByte: time.Millisecond,
+		BytesReceived:   42,
+		ReusedConn:      stats.WasReused,
+	})
+	sink.OnResult(&stats.Stats{Endpoint: "GET http://localhost/", Began: began, Err: fmt.Errorf("dial: %w", context.DeadlineExceeded)})
+	require.NoError(t, sink.OnFinish(nil))
+
+	records := readRawLog(t, path)
+	require.Len(t, records, 2)
+	assert.Equal(t, 2, records[0].Worker)
+	assert.Equal(t, int64(1500), records[0].Latency)
+	assert.Equal(t, int64(100), records[0].DNS)
+	assert.Equal(t, int64(1000), records[0].FirstByte)
+	assert.Equal(t, int64(42), records[0].BytesReceived)
+	assert.True(t, records[0].Reused)
+	assert.True(t, began.Equal(records[0].Timestamp))
+	assert.Equal(t, Timeout, records[1].ErrorClass)
+	assert.Contains(t, records[1].Error, "deadline exceeded")
+}
+
+func TestRawLogSamplingKeepsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "raw.jsonl")
+	sink, err := NewRawLogSink(path, 0)
+	require.NoError(t, err)
+	for range 100 {
+		sink.OnResult(&stats.Stats{StatusCode: 200})
+	}
+	sink.OnResult(&stats.Stats{StatusCode: 200, FailedChecks: []string{"status=201"}})
+	sink.OnResult(&stats.Stats{Err: context.Canceled})
+	require.NoError(t, sink.OnFinish(nil))
+
+	records := readRawLog(t, path)
+	require.Len(t, records, 2)
+	assert.Equal(t, []string{"status=201"}, records[0].FailedChecks)
+	assert.Equal(t, Cancelled, records[1].ErrorClass)
+}
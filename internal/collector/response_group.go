@@ -10,6 +10,8 @@ import (
 type StatusCodeCounter struct {
 	mu sync.Mutex
 	m  map[int]int
+	// names labels the codes, such as those of gRPC, if set.
+	names func(int) string
 }
 
 func NewStatusCodeCounter() *StatusCodeCounter {
@@ -35,7 +37,11 @@ func (s *StatusCodeCounter) String() string {
 	defer s.mu.Unlock()
 	str := "Response Codes Breakdown\n"
 	for k, v := range s.m {
-		str += fmt.Sprintf("\t[%d]: %d", k, v)
+		if s.names != nil {
+			str += fmt.Sprintf("\t[%s]: %d", s.names(k), v)
+		} else {
+			str += fmt.Sprintf("\t[%d]: %d", k, v)
+		}
 		str += "\n"
 	}
 	return str
@@ -52,3 +58,15 @@ func (s *StatusCodeCounter) Count() int {
 	}
 	return t
 }
+
+// Counts returns a copy of the number of responses recorded for each
+// status code.
+func (s *StatusCodeCounter) Counts() map[int]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[int]int64, len(s.m))
+	for k, v := range s.m {
+		counts[k] = int64(v)
+	}
+	return counts
+}
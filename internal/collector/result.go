@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/config"
+)
+
+// Result is the machine readable outcome of a run.  It is persisted
+// to disk with --output and can be loaded again later, for example
+// to compare two runs against each other.
+//
+// All latencies are in microseconds.
+type Result struct {
+	Version   string         `json:"version"`
+	Config    *config.Config `json:"config"`
+	Endpoint  string         `json:"endpoint"`
+	Started   time.Time      `json:"started"`
+	Elapsed   time.Duration  `json:"elapsed"`
+	Requests  int64          `json:"requests"`
+	Errors    int64          `json:"errors"`
+	RPS       float64        `json:"rps"`
+	ErrorRate float64        `json:"error_rate"`
+	// Failed is the number of responses which failed at least one check,
+	// these are not counted as errors.
+	Failed        int64            `json:"failed"`
+	FailureRate   float64          `json:"failure_rate"`
+	CheckFailures map[string]int64 `json:"check_failures"`
+	// Late is the number of scheduled arrivals sent late having waited for
+	// a free worker, their latency includes the wait.  Dropped is the
+	// number not sent at all as every worker was busy.
+	Late        int64                      `json:"late"`
+	Dropped     int64                      `json:"dropped"`
+	Latency     LatencyResult              `json:"latency"`
+	StatusCodes map[int]int64              `json:"status_codes"`
+	ErrorGroups map[string]int64           `json:"error_groups"`
+	Endpoints   map[string]*EndpointResult `json:"endpoints"`
+	Intervals   []Interval                 `json:"intervals"`
+	// Warmup describes the requests of the warm-up, which are excluded
+	// from everything else, if there was one.
+	Warmup *WarmupResult `json:"warmup,omitempty"`
+	// WebSocket describes the connections of a WebSocket run, whose
+	// messages are reported as requests.
+	WebSocket *WebSocketResult `json:"websocket,omitempty"`
+	// Stream describes the streamed responses of a run measuring them,
+	// each of which is reported as a request lasting the stream.
+	Stream *StreamResult `json:"stream,omitempty"`
+}
+
+// WarmupResult holds the outcome of the requests sent during the warm-up.
+type WarmupResult struct {
+	Requests int64         `json:"requests"`
+	Errors   int64         `json:"errors"`
+	Latency  LatencyResult `json:"latency"`
+}
+
+// LatencyResult holds the latency distribution of a set of requests.
+// The full distribution is retained as a compressed HDR histogram so
+// that it can be merged or analysed further after the fact.
+type LatencyResult struct {
+	Min       int64   `json:"min"`
+	Max       int64   `json:"max"`
+	Mean      float64 `json:"mean"`
+	P50       int64   `json:"p50"`
+	P90       int64   `json:"p90"`
+	P95       int64   `json:"p95"`
+	P99       int64   `json:"p99"`
+	Histogram string  `json:"histogram"`
+}
+
+// EndpointResult holds the outcome of requests for a single endpoint.
+type EndpointResult struct {
+	Requests int64         `json:"requests"`
+	Errors   int64         `json:"errors"`
+	Failed   int64         `json:"failed"`
+	RPS      float64       `json:"rps"`
+	Latency  LatencyResult `json:"latency"`
+}
+
+// NewLatencyResult summarises the given histogram.
+func NewLatencyResult(h *hdrhistogram.Histogram) (LatencyResult, error) {
+	encoded, err := h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	if err != nil {
+		return LatencyResult{}, fmt.Errorf("unable to encode histogram: %w", err)
+	}
+	return LatencyResult{
+		Min:       h.Min(),
+		Max:       h.Max(),
+		Mean:      h.Mean(),
+		P50:       h.ValueAtQuantile(50),
+		P90:       h.ValueAtQuantile(90),
+		P95:       h.ValueAtQuantile(95),
+		P99:       h.ValueAtQuantile(99),
+		Histogram: string(encoded),
+	}, nil
+}
+
+// Decode decodes the retained latency distribution.
+func (l LatencyResult) Decode() (*hdrhistogram.Histogram, error) {
+	if l.Histogram == "" {
+		return newLatencyHistogram(), nil
+	}
+	return hdrhistogram.Decode([]byte(l.Histogram))
+}
+
+// Finish builds the Result of the run and hands it to every registered
+// sink.  It blocks until all results have been consumed and must only be
+// called once.  The result is returned even if a sink fails.
+func (e *EventCollector) Finish() (*Result, error) {
+	r, err := e.Result()
+	if err != nil {
+		return nil, err
+	}
+	return r, e.fanout.finish(r)
+}
+
+// Result builds the Result of the run.  It blocks until all results
+// have been consumed.
+func (e *EventCollector) Result() (*Result, error) {
+	e.Wait()
+	elapsed := e.elapsed()
+	latency, err := NewLatencyResult(&e.latency)
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{
+		Version:       e.cfg.Version,
+		Config:        e.cfg.Redacted(),
+		Endpoint:      config.RedactURL(e.cfg.Endpoint),
+		Started:       e.collectionRegistered,
+		Elapsed:       elapsed,
+		Requests:      e.seen,
+		Errors:        e.errored,
+		RPS:           perSecond(e.seen, elapsed),
+		ErrorRate:     ratio(e.errored, e.seen),
+		Failed:        e.failed,
+		FailureRate:   ratio(e.failed, e.seen),
+		CheckFailures: maps.Clone(e.checkFailures),
+		Late:          e.late,
+		Dropped:       e.dropped,
+		Latency:       latency,
+		StatusCodes:   e.counter.Counts(),
+		ErrorGroups:   e.errGrouper.Counts(),
+		Endpoints:     make(map[string]*EndpointResult, len(e.endpoints)),
+		Intervals:     e.timeline,
+	}
+	if w := e.warmup; w != nil {
+		latency, err := NewLatencyResult(w.latency)
+		if err != nil {
+			return nil, err
+		}
+		r.Warmup = &WarmupResult{Requests: w.requests, Errors: w.errored, Latency: latency}
+	}
+	if e.websocket != nil {
+		if r.WebSocket, err = e.websocket.result(elapsed); err != nil {
+			return nil, err
+		}
+	}
+	if e.stream != nil {
+		if r.Stream, err = e.stream.result(elapsed); err != nil {
+			return nil, err
+		}
+	}
+	for name, s := range e.endpoints {
+		latency, err := NewLatencyResult(s.latency)
+		if err != nil {
+			return nil, err
+		}
+		requests := s.latency.TotalCount() + s.errored
+		r.Endpoints[name] = &EndpointResult{
+			Requests: requests,
+			Errors:   s.errored,
+			Failed:   s.failed,
+			RPS:      perSecond(requests, elapsed),
+			Latency:  latency,
+		}
+	}
+	return r, nil
+}
+
+// WriteFile persists the result as JSON to the given path.
+func (r *Result) WriteFile(path string) error {
+	b, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// LoadResult reads a Result previously persisted with WriteFile.
+func LoadResult(path string) (*Result, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Result)
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("unable to parse result %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// perSecond returns the rate of count over the elapsed duration.
+func perSecond(count int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) / elapsed.Seconds()
+}
+
+// ratio returns n as a fraction of total, guarding against division
+// by zero.
+func ratio(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// toMillis converts a microsecond value into milliseconds.
+func toMillis(us int64) float64 {
+	return float64(us) / 1000
+}
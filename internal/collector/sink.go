@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/symonk/vessel/internal/stats"
+)
+
+// sinkBuffer is the number of events buffered for each sink before the
+// collector is slowed down to the pace of that sink.
+const sinkBuffer = 1024
+
+// Sink consumes the stream of results throughout a run.  Each sink is
+// driven from its own goroutine, so a sink does not need to be safe for
+// parallel use, but a slow sink will eventually apply back pressure.
+//
+// OnResult is called for every result, OnInterval each time an interval
+// (see IntervalRecorder) closes and OnFinish exactly once after all results
+// and intervals have been delivered, with the final result of the run.
+type Sink interface {
+	OnResult(stat *stats.Stats)
+	OnInterval(interval Interval)
+	OnFinish(result *Result) error
+}
+
+// NopSink implements Sink and ignores everything, it can be embedded by
+// sinks only interested in a subset of the events.
+type NopSink struct{}
+
+func (NopSink) OnResult(*stats.Stats)  {}
+func (NopSink) OnInterval(Interval)    {}
+func (NopSink) OnFinish(*Result) error { return nil }
+
+// event is a single result or closed interval delivered to a sink.
+type event struct {
+	stat     *stats.Stats
+	interval *Interval
+}
+
+// fanout delivers the same stream of events to multiple sinks which
+// consume them concurrently.
+type fanout struct {
+	sinks  []Sink
+	queues []chan event
+	wg     sync.WaitGroup
+}
+
+// newFanout starts a goroutine per sink and returns the fanout.
+func newFanout(sinks []Sink) *fanout {
+	f := &fanout{sinks: sinks}
+	for _, s := range sinks {
+		q := make(chan event, sinkBuffer)
+		f.queues = append(f.queues, q)
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			for ev := range q {
+				if ev.stat != nil {
+					s.OnResult(ev.stat)
+				} else {
+					s.OnInterval(*ev.interval)
+				}
+			}
+		}()
+	}
+	return f
+}
+
+// result delivers a single result to every sink.
+func (f *fanout) result(stat *stats.Stats) {
+	for _, q := range f.queues {
+		q <- event{stat: stat}
+	}
+}
+
+// interval delivers a closed interval to every sink.
+func (f *fanout) interval(i Interval) {
+	for _, q := range f.queues {
+		q <- event{interval: &i}
+	}
+}
+
+// drain stops delivering events and waits for every sink to consume the
+// events already delivered.
+func (f *fanout) drain() {
+	for _, q := range f.queues {
+		close(q)
+	}
+	f.wg.Wait()
+}
+
+// finish notifies every sink of the final result concurrently and
+// returns their joined errors.
+func (f *fanout) finish(result *Result) error {
+	errs := make([]error, len(f.sinks))
+	var wg sync.WaitGroup
+	for i, s := range f.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.OnFinish(result)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
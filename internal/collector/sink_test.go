@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+type recordingSink struct {
+	results   []*stats.Stats
+	intervals []Interval
+	finished  *Result
+	err       error
+}
+
+func (r *recordingSink) OnResult(s *stats.Stats)    { r.results = append(r.results, s) }
+func (r *recordingSink) OnInterval(i Interval)      { r.intervals = append(r.intervals, i) }
+func (r *recordingSink) OnFinish(res *Result) error { r.finished = res; return r.err }
+
+func TestSinksReceiveTheSameStream(t *testing.T) {
+	a, b := new(recordingSink), &recordingSink{err: errors.New("boom")}
+	ingress := make(chan *stats.Stats)
+	c := New(ingress, io.Discard, &config.Config{}, WithSink(a), WithSink(b), WithInterval(10*time.Millisecond))
+	for range 5 {
+		ingress <- &stats.Stats{Began: time.Now(), StatusCode: 200, Latency: time.Millisecond}
+	}
+	time.Sleep(25 * time.Millisecond)
+	ingress <- &stats.Stats{Began: time.Now(), Err: errors.New("failed")}
+	close(ingress)
+
+	result, err := c.Finish()
+	assert.ErrorContains(t, err, "boom")
+	require.NotNil(t, result)
+	for _, s := range []*recordingSink{a, b} {
+		assert.Len(t, s.results, 6)
+		assert.Same(t, result, s.finished)
+		var requests int64
+		intervals := make([]Interval, len(s.intervals))
+		for n, i := range s.intervals {
+			requests += i.Requests
+			require.NotNil(t, i.Latency)
+			assert.Equal(t, i.Requests-i.Errors, i.Latency.TotalCount())
+			i.Latency = nil
+			intervals[n] = i
+		}
+		assert.Equal(t, int64(6), requests)
+		assert.Equal(t, result.Intervals, intervals)
+	}
+}
+
+func TestWarmupIsExcludedFromResults(t *testing.T) {
+	sink := new(recordingSink)
+	ingress := make(chan *stats.Stats)
+	c := New(ingress, io.Discard, &config.Config{WarmupRequests: 2}, WithSink(sink))
+	for range 2 {
+		ingress <- &stats.Stats{Began: time.Now(), StatusCode: 200, Latency: time.Second, Warmup: true}
+	}
+	ingress <- &stats.Stats{Began: time.Now(), Err: errors.New("failed"), Warmup: true}
+	measured := time.Now()
+	for range 3 {
+		ingress <- &stats.Stats{Began: measured, StatusCode: 200, Latency: time.Millisecond}
+	}
+	close(ingress)
+
+	result, err := c.Finish()
+	require.NoError(t, err)
+	assert.Len(t, sink.results, 3)
+	assert.Equal(t, int64(3), result.Requests)
+	assert.Equal(t, int64(0), result.Errors)
+	assert.Equal(t, int64(3), result.StatusCodes[200])
+	assert.InDelta(t, 1000, result.Latency.Max, 10)
+	assert.Equal(t, measured, result.Started)
+	require.NotNil(t, result.Warmup)
+	assert.Equal(t, int64(3), result.Warmup.Requests)
+	assert.Equal(t, int64(1), result.Warmup.Errors)
+	assert.InDelta(t, 1_000_000, result.Warmup.Latency.Max, 1000)
+}
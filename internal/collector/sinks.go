@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/csv"
+	"os"
+	"strconv"
+)
+
+// JSONSink persists the final Result as JSON to a file, see LoadResult.
+type JSONSink struct {
+	NopSink
+	path string
+}
+
+// NewJSONSink instantiates a new JSONSink writing to path and returns a
+// ptr to it.
+func NewJSONSink(path string) *JSONSink {
+	return &JSONSink{path: path}
+}
+
+// OnFinish implements Sink.
+func (j *JSONSink) OnFinish(result *Result) error {
+	return result.WriteFile(j.path)
+}
+
+// TimeSeriesSink writes each interval as a CSV row as soon as it closes,
+// allowing a run to be followed (e.g with tail -f) while it is in progress.
+type TimeSeriesSink struct {
+	NopSink
+	f   *os.File
+	buf *bufio.Writer
+	w   *csv.Writer
+	err error
+}
+
+// NewTimeSeriesSink creates (or truncates) the file at path, writes the
+// CSV header and returns a ptr to the TimeSeriesSink.
+func NewTimeSeriesSink(path string) (*TimeSeriesSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	t := &TimeSeriesSink{f: f, buf: buf, w: csv.NewWriter(buf)}
+	t.write([]string{"offset_seconds", "requests", "errors", "rps", "mean_ms", "p50_ms", "p90_ms", "p99_ms", "max_ms"})
+	return t, t.err
+}
+
+// OnInterval implements Sink.
+func (t *TimeSeriesSink) OnInterval(i Interval) {
+	t.write([]string{
+		strconv.FormatFloat(i.Offset.Seconds(), 'f', 3, 64),
+		strconv.FormatInt(i.Requests, 10),
+		strconv.FormatInt(i.Errors, 10),
+		strconv.FormatFloat(i.RPS, 'f', 2, 64),
+		strconv.FormatFloat(i.Mean/1000, 'f', 3, 64),
+		strconv.FormatFloat(toMillis(i.P50), 'f', 3, 64),
+		strconv.FormatFloat(toMillis(i.P90), 'f', 3, 64),
+		strconv.FormatFloat(toMillis(i.P99), 'f', 3, 64),
+		strconv.FormatFloat(toMillis(i.Max), 'f', 3, 64),
+	})
+}
+
+// OnFinish implements Sink and closes the file.
+func (t *TimeSeriesSink) OnFinish(*Result) error {
+	if err := t.f.Close(); t.err == nil {
+		t.err = err
+	}
+	return t.err
+}
+
+// write writes and flushes a single row, retaining the first error.
+func (t *TimeSeriesSink) write(row []string) {
+	if t.err != nil {
+		return
+	}
+	if t.err = t.w.Write(row); t.err != nil {
+		return
+	}
+	t.w.Flush()
+	if t.err = t.w.Error(); t.err == nil {
+		t.err = t.buf.Flush()
+	}
+}
@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+// StreamResult holds the outcome of the streamed responses of a run, each
+// of which is otherwise reported as a single request lasting the stream.
+type StreamResult struct {
+	// Streams is the number of streamed responses received.
+	Streams         int64   `json:"streams"`
+	Events          int64   `json:"events"`
+	EventsPerSecond float64 `json:"events_per_second"`
+	// FirstEvent is the time to the first event, measured from each
+	// request being dispatched.
+	FirstEvent LatencyResult `json:"first_event"`
+	// Gap is the time between consecutive events of a stream.
+	Gap LatencyResult `json:"gap"`
+	// Duration is the time taken by the streams which completed without
+	// error, measured from each request being dispatched.
+	Duration LatencyResult `json:"duration"`
+	// TimedOut and Truncated count the streams cut short by the stream
+	// timeout (or the end of the run) and maximum number of events
+	// respectively.
+	TimedOut  int64 `json:"timed_out"`
+	Truncated int64 `json:"truncated"`
+}
+
+// streamStats tracks the streamed responses of a run.
+type streamStats struct {
+	streams    int64
+	events     int64
+	firstEvent *hdrhistogram.Histogram
+	gap        *hdrhistogram.Histogram
+	duration   *hdrhistogram.Histogram
+	timedOut   int64
+	truncated  int64
+}
+
+// newStreamStats returns empty stream stats.  Streams may far outlast a
+// request, their duration is recorded up to an hour.
+func newStreamStats() *streamStats {
+	return &streamStats{
+		firstEvent: newLatencyHistogram(),
+		gap:        newLatencyHistogram(),
+		duration:   hdrhistogram.New(1, 3_600_000_000, 3),
+	}
+}
+
+// record accounts for a single streamed response, requests which failed
+// before a response was received are not streams.
+func (s *streamStats) record(stat *stats.Stats) {
+	if stat.StatusCode == 0 {
+		return
+	}
+	s.streams++
+	s.events += stat.Events
+	if stat.Events > 0 {
+		_ = s.firstEvent.RecordValue(stat.FirstEvent.Microseconds())
+	}
+	if stat.EventGaps != nil {
+		s.gap.Merge(stat.EventGaps)
+	}
+	if stat.Err == nil {
+		_ = s.duration.RecordValue(stat.Latency.Microseconds())
+	}
+	if stat.StreamTimedOut {
+		s.timedOut++
+	}
+	if stat.StreamTruncated {
+		s.truncated++
+	}
+}
+
+// result summarises the stats over the elapsed duration.
+func (s *streamStats) result(elapsed time.Duration) (*StreamResult, error) {
+	firstEvent, err := NewLatencyResult(s.firstEvent)
+	if err != nil {
+		return nil, err
+	}
+	gap, err := NewLatencyResult(s.gap)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := NewLatencyResult(s.duration)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamResult{
+		Streams:         s.streams,
+		Events:          s.events,
+		EventsPerSecond: perSecond(s.events, elapsed),
+		FirstEvent:      firstEvent,
+		Gap:             gap,
+		Duration:        duration,
+		TimedOut:        s.timedOut,
+		Truncated:       s.truncated,
+	}, nil
+}
+
+// merge adds the streams of a result.
+func (s *streamStats) merge(r *StreamResult) error {
+	for _, m := range []struct {
+		name string
+		into *hdrhistogram.Histogram
+		from LatencyResult
+	}{
+		{name: "first event", into: s.firstEvent, from: r.FirstEvent},
+		{name: "gap", into: s.gap, from: r.Gap},
+		{name: "duration", into: s.duration, from: r.Duration},
+	} {
+		h, err := m.from.Decode()
+		if err != nil {
+			return fmt.Errorf("unable to decode stream %s: %w", m.name, err)
+		}
+		m.into.Merge(h)
+	}
+	s.streams += r.Streams
+	s.events += r.Events
+	s.timedOut += r.TimedOut
+	s.truncated += r.Truncated
+	return nil
+}
+
+// String describes the result on a single line.
+func (s *StreamResult) String() string {
+	return fmt.Sprintf("Streams: %d, events %d (%.2f/second), first event p50=%.2fms, p99=%.2fms, gap p50=%.2fms, p99=%.2fms, duration p50=%.2fms, p99=%.2fms, timed out %d, truncated %d",
+		s.Streams, s.Events, s.EventsPerSecond,
+		toMillis(s.FirstEvent.P50), toMillis(s.FirstEvent.P99),
+		toMillis(s.Gap.P50), toMillis(s.Gap.P99),
+		toMillis(s.Duration.P50), toMillis(s.Duration.P99),
+		s.TimedOut, s.Truncated)
+}
@@ -18,6 +18,11 @@ type Summary struct {
 	// This is a single 'joined' error for now.
 	RawErrors         error
 	Errors            string
+	Checks            string
+	Arrivals          string
+	Warmup            string
+	WebSocket         string
+	Stream            string
 	RealTime          time.Duration
 	Results           *StatusCodeCounter
 	Workers           int
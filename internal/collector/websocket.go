@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+// WebSocketResult holds the outcome of the connections of a WebSocket run,
+// whose messages are otherwise reported as requests.
+type WebSocketResult struct {
+	// Connections is the number of connections opened.
+	Connections int64 `json:"connections"`
+	// Handshake is the latency of the opening handshakes.
+	Handshake LatencyResult `json:"handshake"`
+	// Received is the number of messages received, including replies.
+	Received          int64   `json:"received"`
+	ReceivedPerSecond float64 `json:"received_per_second"`
+	// CloseCodes counts the connections closed by the server by their
+	// close code.
+	CloseCodes map[int]int64 `json:"close_codes"`
+}
+
+// websocketStats tracks the connections of a WebSocket run.
+type websocketStats struct {
+	connections int64
+	handshake   *hdrhistogram.Histogram
+	received    int64
+	closeCodes  map[int]int64
+}
+
+// newWebSocketStats returns empty WebSocket stats.
+func newWebSocketStats() *websocketStats {
+	return &websocketStats{handshake: newLatencyHistogram(), closeCodes: make(map[int]int64)}
+}
+
+// record accounts for the connection lifecycle of a single message.
+func (w *websocketStats) record(stat *stats.Stats) {
+	if stat.Handshake > 0 {
+		w.connections++
+		_ = w.handshake.RecordValue(stat.Handshake.Microseconds())
+	}
+	w.received += stat.Received
+	if stat.CloseCode != 0 {
+		w.closeCodes[stat.CloseCode]++
+	}
+}
+
+// result summarises the stats over the elapsed duration.
+func (w *websocketStats) result(elapsed time.Duration) (*WebSocketResult, error) {
+	handshake, err := NewLatencyResult(w.handshake)
+	if err != nil {
+		return nil, err
+	}
+	return &WebSocketResult{
+		Connections:       w.connections,
+		Handshake:         handshake,
+		Received:          w.received,
+		ReceivedPerSecond: perSecond(w.received, elapsed),
+		CloseCodes:        maps.Clone(w.closeCodes),
+	}, nil
+}
+
+// String describes the result on a single line.
+func (w *WebSocketResult) String() string {
+	codes := make([]string, 0, len(w.CloseCodes))
+	for _, code := range slices.Sorted(maps.Keys(w.CloseCodes)) {
+		codes = append(codes, strconv.Itoa(code)+"("+strconv.FormatInt(w.CloseCodes[code], 10)+")")
+	}
+	closes := "none"
+	if len(codes) > 0 {
+		closes = strings.Join(codes, ", ")
+	}
+	return fmt.Sprintf("Connections: %d, handshake p50=%.2fms, p99=%.2fms, received %d (%.2f/second), closed by server: %s",
+		w.Connections, toMillis(w.Handshake.P50), toMillis(w.Handshake.P99), w.Received, w.ReceivedPerSecond, closes)
+}
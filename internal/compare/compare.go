@@ -0,0 +1,326 @@
+package compare
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/collector"
+)
+
+// Direction describes which way a metric moves when it gets worse.
+type Direction int
+
+const (
+	// HigherIsWorse is used for metrics such as latency and errors.
+	HigherIsWorse Direction = iota
+	// LowerIsWorse is used for metrics such as throughput.
+	LowerIsWorse
+)
+
+const (
+	// ErrorRate is the metric name of the error rate, its tolerance is
+	// expressed in absolute percentage points rather than relative change.
+	ErrorRate = "error_rate"
+	// FailureRate is the metric name of the rate of responses failing
+	// checks, like ErrorRate its tolerance is in percentage points.
+	FailureRate = "failure_rate"
+	// Default is the tolerance key applied to metrics without an explicit
+	// tolerance of their own.
+	Default = "default"
+)
+
+// Tolerances maps metric names to the permitted worsening of that metric
+// in percent.  A metric with no tolerance configured (and no Default) is
+// reported but never considered a regression.
+//
+// Metric names are either the exact name as reported (e.g. p99 or
+// "GET http://localhost/ p99") or the metric kind (e.g. p99) which
+// applies to the overall and every per endpoint metric of that kind.
+type Tolerances map[string]float64
+
+// Delta is the difference in a single metric between two runs.
+type Delta struct {
+	Metric    string
+	Baseline  float64
+	Current   float64
+	Absolute  float64
+	Percent   float64
+	Tolerance float64
+	Checked   bool
+	Regressed bool
+}
+
+// Significance is the outcome of a Mann-Whitney U test between the
+// baseline and current latency distributions.
+type Significance struct {
+	Z           float64
+	PValue      float64
+	Alpha       float64
+	Slower      bool
+	Significant bool
+}
+
+// Report is the outcome of comparing two runs.
+type Report struct {
+	Deltas       []Delta
+	Significance *Significance
+}
+
+// Compare aligns the metrics of the baseline and current run and reports
+// their differences.  When alpha is greater than zero latency regressions
+// are only flagged when the latency distributions also differ with
+// statistical significance at that level.
+func Compare(baseline, current *collector.Result, tolerances Tolerances, alpha float64) (*Report, error) {
+	r := new(Report)
+	sig, err := significance(baseline.Latency, current.Latency, alpha)
+	if err != nil {
+		return nil, err
+	}
+	r.Significance = sig
+	gate := alpha > 0 && !sig.Significant
+
+	r.add(tolerances, "rps", "rps", baseline.RPS, current.RPS, LowerIsWorse, false)
+	r.add(tolerances, ErrorRate, ErrorRate, baseline.ErrorRate*100, current.ErrorRate*100, HigherIsWorse, false)
+	r.add(tolerances, FailureRate, FailureRate, baseline.FailureRate*100, current.FailureRate*100, HigherIsWorse, false)
+	r.latency(tolerances, "", baseline.Latency, current.Latency, gate)
+
+	// Status codes are compared as a share of the requests, runs of a
+	// different length are then still comparable.
+	for _, code := range statusCodes(baseline, current) {
+		name := "status." + strconv.Itoa(code)
+		r.add(tolerances, name, name, share(baseline.StatusCodes[code], baseline.Requests), share(current.StatusCodes[code], current.Requests), HigherIsWorse, false)
+	}
+
+	for _, endpoint := range endpoints(baseline, current) {
+		b, c := baseline.Endpoints[endpoint], current.Endpoints[endpoint]
+		if b == nil || c == nil {
+			// The endpoint was only targeted in one of the runs, there is
+			// nothing to align it with.
+			continue
+		}
+		r.add(tolerances, endpoint+" rps", "rps", b.RPS, c.RPS, LowerIsWorse, false)
+		r.add(tolerances, endpoint+" "+ErrorRate, ErrorRate, share(b.Errors, b.Requests), share(c.Errors, c.Requests), HigherIsWorse, false)
+		r.latency(tolerances, endpoint+" ", b.Latency, c.Latency, gate)
+	}
+	return r, nil
+}
+
+// Regressed reports whether any of the checked metrics regressed beyond
+// their tolerance.
+func (r *Report) Regressed() bool {
+	for _, d := range r.Deltas {
+		if d.Regressed {
+			return true
+		}
+	}
+	return false
+}
+
+// Write renders the report as a table.
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Metric\tBaseline\tCurrent\tDelta\tDelta %\tTolerance\tResult")
+	for _, d := range r.Deltas {
+		tolerance, result := "-", "-"
+		if d.Checked {
+			tolerance = fmt.Sprintf("%.2f%%", d.Tolerance)
+			result = "ok"
+			if d.Regressed {
+				result = "REGRESSED"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%+.2f\t%+.2f%%\t%s\t%s\n", d.Metric, d.Baseline, d.Current, d.Absolute, d.Percent, tolerance, result)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if s := r.Significance; s != nil {
+		direction := "faster"
+		if s.Slower {
+			direction = "slower"
+		}
+		_, err := fmt.Fprintf(w, "\nMann-Whitney U: z=%.3f, p=%.4f (current is %s)\n", s.Z, s.PValue, direction)
+		return err
+	}
+	return nil
+}
+
+// latency adds the latency deltas (in milliseconds) for a single scope.
+func (r *Report) latency(tolerances Tolerances, prefix string, baseline, current collector.LatencyResult, gate bool) {
+	r.add(tolerances, prefix+"mean", "mean", baseline.Mean/1000, current.Mean/1000, HigherIsWorse, gate)
+	r.add(tolerances, prefix+"p50", "p50", ms(baseline.P50), ms(current.P50), HigherIsWorse, gate)
+	r.add(tolerances, prefix+"p90", "p90", ms(baseline.P90), ms(current.P90), HigherIsWorse, gate)
+	r.add(tolerances, prefix+"p95", "p95", ms(baseline.P95), ms(current.P95), HigherIsWorse, gate)
+	r.add(tolerances, prefix+"p99", "p99", ms(baseline.P99), ms(current.P99), HigherIsWorse, gate)
+	r.add(tolerances, prefix+"max", "max", ms(baseline.Max), ms(current.Max), HigherIsWorse, gate)
+}
+
+// add computes and records the delta of a single metric.  A gated metric
+// is never considered regressed.  A metric worsening from zero has no
+// relative change to speak of and is always considered regressed.
+func (r *Report) add(tolerances Tolerances, metric, kind string, baseline, current float64, direction Direction, gate bool) {
+	d := Delta{
+		Metric:   metric,
+		Baseline: baseline,
+		Current:  current,
+		Absolute: current - baseline,
+	}
+	switch {
+	case baseline != 0:
+		d.Percent = d.Absolute / baseline * 100
+	case current != 0:
+		d.Percent = math.Inf(int(math.Copysign(1, current)))
+	}
+	d.Tolerance, d.Checked = lookup(tolerances, metric, kind)
+	if d.Checked && !gate {
+		worsened := d.Percent
+		if points(kind) {
+			// The rate is already a percentage, relative change of tiny
+			// rates is meaningless so compare the points instead.
+			worsened = d.Absolute
+		}
+		if direction == LowerIsWorse {
+			worsened = -worsened
+		}
+		d.Regressed = worsened > d.Tolerance || baseline == 0 && worsened > 0
+	}
+	r.Deltas = append(r.Deltas, d)
+}
+
+// points reports whether the metric kind is a percentage of the requests,
+// whose tolerance is in absolute percentage points.
+func points(kind string) bool {
+	return kind == ErrorRate || kind == FailureRate || strings.HasPrefix(kind, "status.")
+}
+
+// share returns n as a percentage of the requests.
+func share(n, requests int64) float64 {
+	return 100 * float64(n) / math.Max(1, float64(requests))
+}
+
+// lookup finds the tolerance for a metric, preferring the exact metric
+// name, then its kind and finally the default.
+func lookup(tolerances Tolerances, metric, kind string) (float64, bool) {
+	for _, key := range []string{metric, kind} {
+		if t, ok := tolerances[key]; ok {
+			return t, true
+		}
+	}
+	// status codes are purely informational unless explicitly configured.
+	if strings.HasPrefix(kind, "status.") {
+		return 0, false
+	}
+	t, ok := tolerances[Default]
+	return t, ok
+}
+
+// significance performs a two sided Mann-Whitney U test on the latency
+// distributions of both runs.
+func significance(baseline, current collector.LatencyResult, alpha float64) (*Significance, error) {
+	b, err := baseline.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode baseline histogram: %w", err)
+	}
+	c, err := current.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode current histogram: %w", err)
+	}
+	z, p := MannWhitneyU(b, c)
+	return &Significance{
+		Z:           z,
+		PValue:      p,
+		Alpha:       alpha,
+		Slower:      z < 0,
+		Significant: p < alpha,
+	}, nil
+}
+
+// MannWhitneyU performs a two sided Mann-Whitney U test (normal
+// approximation, tie corrected) on the values recorded in two
+// histograms.  The returned z score is negative when the values
+// in b tend to be larger than those in a.
+func MannWhitneyU(a, b *hdrhistogram.Histogram) (z, p float64) {
+	n1, n2 := float64(a.TotalCount()), float64(b.TotalCount())
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+	counts := make(map[int64][2]int64)
+	for i, h := range []*hdrhistogram.Histogram{a, b} {
+		for _, bar := range h.Distribution() {
+			if bar.Count == 0 {
+				continue
+			}
+			c := counts[bar.From]
+			c[i] += bar.Count
+			counts[bar.From] = c
+		}
+	}
+	values := make([]int64, 0, len(counts))
+	for v := range counts {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	var ranked, rankSum, ties float64
+	for _, v := range values {
+		c := counts[v]
+		t := float64(c[0] + c[1])
+		rankSum += float64(c[0]) * (ranked + (t+1)/2)
+		ties += t*t*t - t
+		ranked += t
+	}
+	n := n1 + n2
+	u := rankSum - n1*(n1+1)/2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 0, 1
+	}
+	z = (u - mean) / math.Sqrt(variance)
+	return z, math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// statusCodes returns the sorted union of status codes seen in either run.
+func statusCodes(baseline, current *collector.Result) []int {
+	seen := make(map[int]struct{})
+	for code := range baseline.StatusCodes {
+		seen[code] = struct{}{}
+	}
+	for code := range current.StatusCodes {
+		seen[code] = struct{}{}
+	}
+	codes := make([]int, 0, len(seen))
+	for code := range seen {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// endpoints returns the sorted union of endpoints targeted in either run.
+func endpoints(baseline, current *collector.Result) []string {
+	seen := make(map[string]struct{})
+	for e := range baseline.Endpoints {
+		seen[e] = struct{}{}
+	}
+	for e := range current.Endpoints {
+		seen[e] = struct{}{}
+	}
+	names := make([]string, 0, len(seen))
+	for e := range seen {
+		names = append(names, e)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ms converts microseconds into milliseconds.
+func ms(us int64) float64 {
+	return float64(us) / 1000
+}
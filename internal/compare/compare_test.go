@@ -0,0 +1,137 @@
+package compare
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/collector"
+)
+
+func result(t *testing.T, rps float64, errorRate float64, latencies ...int64) *collector.Result {
+	t.Helper()
+	h := hdrhistogram.New(1, 60_000_000, 3)
+	for _, l := range latencies {
+		require.NoError(t, h.RecordValue(l))
+	}
+	latency, err := collector.NewLatencyResult(h)
+	require.NoError(t, err)
+	return &collector.Result{
+		Requests:    int64(len(latencies)),
+		RPS:         rps,
+		ErrorRate:   errorRate,
+		Latency:     latency,
+		StatusCodes: map[int]int64{200: int64(len(latencies))},
+	}
+}
+
+func TestCompareFlagsRegressions(t *testing.T) {
+	tests := map[string]struct {
+		baseline  func(t *testing.T) *collector.Result
+		current   func(t *testing.T) *collector.Result
+		regressed bool
+	}{
+		"unchanged": {
+			baseline:  func(t *testing.T) *collector.Result { return result(t, 100, 0, 1000, 2000, 3000) },
+			current:   func(t *testing.T) *collector.Result { return result(t, 100, 0, 1000, 2000, 3000) },
+			regressed: false,
+		},
+		"slower": {
+			baseline:  func(t *testing.T) *collector.Result { return result(t, 100, 0, 1000, 2000, 3000) },
+			current:   func(t *testing.T) *collector.Result { return result(t, 100, 0, 5000, 6000, 7000) },
+			regressed: true,
+		},
+		"lower_throughput": {
+			baseline:  func(t *testing.T) *collector.Result { return result(t, 100, 0, 1000) },
+			current:   func(t *testing.T) *collector.Result { return result(t, 80, 0, 1000) },
+			regressed: true,
+		},
+		"higher_throughput": {
+			baseline:  func(t *testing.T) *collector.Result { return result(t, 100, 0, 1000) },
+			current:   func(t *testing.T) *collector.Result { return result(t, 200, 0, 1000) },
+			regressed: false,
+		},
+		"more_errors": {
+			baseline:  func(t *testing.T) *collector.Result { return result(t, 100, 0.01, 1000) },
+			current:   func(t *testing.T) *collector.Result { return result(t, 100, 0.05, 1000) },
+			regressed: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			report, err := Compare(test.baseline(t), test.current(t), Tolerances{Default: 10, ErrorRate: 1}, 0)
+			require.NoError(t, err)
+			assert.Equal(t, test.regressed, report.Regressed())
+		})
+	}
+}
+
+func TestStatusCodesCompareAsAShareOfRequests(t *testing.T) {
+	tests := map[string]struct {
+		baseline  map[int]int64
+		current   map[int]int64
+		requests  [2]int64
+		regressed bool
+	}{
+		"new status code":        {baseline: map[int]int64{200: 100}, current: map[int]int64{200: 99, 500: 1}, requests: [2]int64{100, 100}, regressed: true},
+		"longer run, same share": {baseline: map[int]int64{200: 90, 500: 10}, current: map[int]int64{200: 900, 500: 100}, requests: [2]int64{100, 1000}, regressed: false},
+		"share grows":            {baseline: map[int]int64{200: 90, 500: 10}, current: map[int]int64{200: 80, 500: 20}, requests: [2]int64{100, 100}, regressed: true},
+		"status code disappears": {baseline: map[int]int64{200: 99, 500: 1}, current: map[int]int64{200: 100}, requests: [2]int64{100, 100}, regressed: false},
+		"share within tolerance": {baseline: map[int]int64{200: 90, 500: 10}, current: map[int]int64{200: 89, 500: 11}, requests: [2]int64{100, 100}, regressed: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			baseline, current := result(t, 100, 0, 1000), result(t, 100, 0, 1000)
+			baseline.StatusCodes, baseline.Requests = tc.baseline, tc.requests[0]
+			current.StatusCodes, current.Requests = tc.current, tc.requests[1]
+			report, err := Compare(baseline, current, Tolerances{"status.500": 5}, 0)
+			require.NoError(t, err)
+			assert.Equal(t, tc.regressed, report.Regressed())
+		})
+	}
+
+	report, err := Compare(result(t, 100, 0, 1000), &collector.Result{RPS: 100, Requests: 4, StatusCodes: map[int]int64{500: 1}}, Tolerances{}, 0)
+	require.NoError(t, err)
+	i := slices.IndexFunc(report.Deltas, func(d Delta) bool { return d.Metric == "status.500" })
+	require.NotEqual(t, -1, i)
+	assert.Equal(t, 25.0, report.Deltas[i].Current)
+	assert.True(t, math.IsInf(report.Deltas[i].Percent, 1))
+}
+
+func TestMetricsWithoutToleranceAreNotChecked(t *testing.T) {
+	report, err := Compare(result(t, 100, 0, 1000), result(t, 10, 0, 9000), Tolerances{}, 0)
+	require.NoError(t, err)
+	assert.False(t, report.Regressed())
+}
+
+func TestSignificanceGatesLatencyRegressions(t *testing.T) {
+	// a single slow outlier moves p99 but is not significant.
+	base := make([]int64, 0, 100)
+	for range 100 {
+		base = append(base, 1000)
+	}
+	current := append(append([]int64{}, base[1:]...), 50000)
+	report, err := Compare(result(t, 100, 0, base...), result(t, 100, 0, current...), Tolerances{"max": 10}, 0.05)
+	require.NoError(t, err)
+	assert.False(t, report.Significance.Significant)
+	assert.False(t, report.Regressed())
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	a := hdrhistogram.New(1, 60_000_000, 3)
+	b := hdrhistogram.New(1, 60_000_000, 3)
+	for i := range int64(500) {
+		require.NoError(t, a.RecordValue(1000+i))
+		require.NoError(t, b.RecordValue(1200+i))
+	}
+	z, p := MannWhitneyU(a, b)
+	assert.Less(t, z, 0.0)
+	assert.Less(t, p, 0.001)
+
+	z, p = MannWhitneyU(a, a)
+	assert.InDelta(t, 0, z, 1e-9)
+	assert.InDelta(t, 1, p, 1e-9)
+}
@@ -2,13 +2,31 @@ package config
 
 import (
 	"encoding/json"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// redacted replaces credentials in a redacted Config.
+const redacted = "********"
+
+// Modes of Config.Stream, how a streamed response is split into events.
+const (
+	// StreamSSE splits the response into server-sent events.
+	StreamSSE = "sse"
+	// StreamChunks takes each read of the response as it arrives as an
+	// event, such as the chunks of a chunked response.
+	StreamChunks = "chunks"
+)
+
 // Config encapsulates the runtime configuration options
 type Config struct {
 	QuietSet        bool
 	MaxRPS          int
+	Rate            float64
+	Arrival         string
+	Seed            uint64
+	Shape           string
 	Concurrency     int
 	Duration        time.Duration
 	Method          string
@@ -19,15 +37,69 @@ type Config struct {
 	Endpoint        string
 	BasicAuth       string
 	Headers         []string
+	Body            string
+	Resolve         []string
+	WSCorrelate     string
+	WSNoReply       bool
+	GRPC            bool
+	Stream          string
+	StreamTimeout   time.Duration
+	StreamMaxEvents int64
 	Amount          int64
+	Warmup          time.Duration
+	WarmupRequests  int64
 	Debug           bool
 	FollowRedirects bool
 	Version         string
 	Cache           bool
 	Insecure        bool
 	MaxConnections  int
+	Sessions        bool
+	UserPool        bool
+	Feeder          string
+	ThinkTime       string
+	Pacing          time.Duration
 	Certificate     string
 	PrivateKey      string
+	Traceparent     bool
+	OTLPEndpoint    string
+	TraceSampleRate float64
+	CorrelationID   string
+	BearerFile      string
+	HMAC            string
+	Checks          []string
+	Thresholds      []string
+}
+
+// Redacted returns a copy of the config with its credentials, the basic
+// auth, hmac secret, bearer token file, header values and any password in
+// the endpoint, masked so that it is safe to share in results and reports.
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Endpoint = RedactURL(c.Endpoint)
+	for _, s := range []*string{&r.BasicAuth, &r.HMAC, &r.BearerFile} {
+		if *s != "" {
+			*s = redacted
+		}
+	}
+	if c.Headers != nil {
+		r.Headers = make([]string, len(c.Headers))
+		for i, header := range c.Headers {
+			name, _, _ := strings.Cut(header, ":")
+			r.Headers[i] = name + ": " + redacted
+		}
+	}
+	return &r
+}
+
+// RedactURL returns the url with the password of its userinfo masked, as
+// url.URL.Redacted does.  A url which does not parse is returned as is.
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
 }
 
 func (c *Config) String() string {
@@ -3,16 +3,29 @@ package coordinator
 import (
 	"context"
 	"crypto/tls"
+	"math"
+	"math/rand/v2"
 	"net/http"
+	"net/http/cookiejar"
 	"sync"
 	"time"
 
+	"github.com/symonk/vessel/internal/check"
 	"github.com/symonk/vessel/internal/collector"
 	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/distribution"
+	"github.com/symonk/vessel/internal/hook"
+	"github.com/symonk/vessel/internal/shape"
 	"github.com/symonk/vessel/internal/stats"
+	"github.com/symonk/vessel/internal/telemetry"
+	"github.com/symonk/vessel/internal/websocket"
 	"github.com/symonk/vessel/internal/worker"
 )
 
+// idle is the resolution at which a shape is re-evaluated whilst its rate
+// is zero.
+const idle = time.Millisecond
+
 // Coordinator sends HTTP requests to a server (typically at scale) and
 // can be signalled to wait until all requests have finalized through
 // Wait()
@@ -20,6 +33,15 @@ type Coordinator interface {
 	Wait()
 }
 
+// Source produces the requests to dispatch in place of the template, for
+// example to replay recorded traffic.
+type Source interface {
+	// Next returns the next request, derived from the template, and the
+	// offset from the start of the run at which it is due (zero dispatches
+	// it as soon as possible).  It returns false once exhausted.
+	Next(template *http.Request) (*http.Request, time.Duration, bool)
+}
+
 // RequestCoordinator takes a request and fans out many instances
 // of that request until either the maximum count is reached
 // or the duration has been surpassed.
@@ -32,46 +54,188 @@ type RequestCoordinator struct {
 	template  *http.Request
 	workerCh  chan *http.Request
 	wg        sync.WaitGroup
+	workerOpt []worker.Option
+	ended     chan struct{} // Closed once the duration of the run elapses.
+	arrivals  distribution.Distribution
+	shape     shape.Shape
+	source    Source
+}
+
+// Option is a functional option for the RequestCoordinator.
+type Option func(*RequestCoordinator)
+
+// WithTracer enables trace context propagation (and optionally export
+// of client spans) for every request sent.
+func WithTracer(t *telemetry.Tracer) Option {
+	return func(r *RequestCoordinator) {
+		r.workerOpt = append(r.workerOpt, worker.WithTracer(t))
+	}
+}
+
+// WithRequestHooks runs the hooks on every request right before it is
+// dispatched.
+func WithRequestHooks(hooks ...hook.RequestHook) Option {
+	return func(r *RequestCoordinator) {
+		r.workerOpt = append(r.workerOpt, worker.WithRequestHooks(hooks...))
+	}
+}
+
+// WithResponseHooks runs the hooks on every response once its body has
+// been read.
+func WithResponseHooks(hooks ...hook.ResponseHook) Option {
+	return func(r *RequestCoordinator) {
+		r.workerOpt = append(r.workerOpt, worker.WithResponseHooks(hooks...))
+	}
+}
+
+// WithChecks validates every response against the checks.
+func WithChecks(checks ...check.Check) Option {
+	return func(r *RequestCoordinator) {
+		r.workerOpt = append(r.workerOpt, worker.WithChecks(checks...))
+	}
+}
+
+// WithArrivals dispatches requests with gaps sampled from the
+// distribution, rather than evenly at the configured rate.
+func WithArrivals(d distribution.Distribution) Option {
+	return func(r *RequestCoordinator) {
+		r.arrivals = d
+	}
+}
+
+// WithShape varies the arrival rate over the measured phase of the run,
+// the gaps sampled from the arrivals are scaled from a rate of one per
+// second to the rate of the shape at the time.
+func WithShape(s shape.Shape) Option {
+	return func(r *RequestCoordinator) {
+		r.shape = s
+	}
+}
+
+// WithSource dispatches the requests of the source rather than the
+// template, the run ends early if the source is exhausted.
+func WithSource(s Source) Option {
+	return func(r *RequestCoordinator) {
+		r.source = s
+	}
+}
+
+// WithThinkTime pauses every worker for a duration sampled from the
+// distribution between its iterations.
+func WithThinkTime(d distribution.Distribution) Option {
+	return func(r *RequestCoordinator) {
+		r.workerOpt = append(r.workerOpt, worker.WithThinkTime(d))
+	}
+}
+
+// WithPacing begins each worker's iterations at most once per interval.
+func WithPacing(interval time.Duration) Option {
+	return func(r *RequestCoordinator) {
+		r.workerOpt = append(r.workerOpt, worker.WithPacing(interval))
+	}
 }
 
 // New instantiates a new instance of RequestCoordinator and returns
 // the ptr to it.
-func New(ctx context.Context, out chan<- *stats.Stats, cfg *config.Config, collector collector.ResultCollector, template *http.Request) *RequestCoordinator {
+func New(ctx context.Context, out chan<- *stats.Stats, cfg *config.Config, collector collector.ResultCollector, template *http.Request, options ...Option) *RequestCoordinator {
 	maxWorkers := max(1, cfg.Concurrency)
+	// The client timeout spans reading the body, which for a WebSocket is
+	// the connection itself, workers time each message out instead.  A
+	// stream is bounded by the stream timeout, the transport times out
+	// waiting for its response to begin.
+	timeout := cfg.Timeout
+	if websocket.IsWebSocket(cfg.Endpoint) || cfg.Stream != "" {
+		timeout = 0
+	}
 	r := &RequestCoordinator{
 		ctx:       ctx,
 		collector: collector,
 		cfg:       cfg,
 		out:       out,
 		client: &http.Client{
-			Timeout: cfg.Timeout,
-			Transport: NewRateLimitingTransport(
-				cfg.MaxRPS,
-				&RateLimitingTransport{
-					// TODO: Overhaul this.
-					Next: &http.Transport{
-						Proxy:                 http.ProxyFromEnvironment,
-						ForceAttemptHTTP2:     true,
-						MaxConnsPerHost:       cfg.MaxConnections,
-						IdleConnTimeout:       90 * time.Second,
-						TLSHandshakeTimeout:   10 * time.Second,
-						ExpectContinueTimeout: 1 * time.Second,
-						TLSClientConfig: &tls.Config{
-							// Skip server verification checks.  Enables testing against
-							// self signed/expired certs, wrong domain or untrusted.
-							InsecureSkipVerify: cfg.Insecure,
-						},
-					},
-				}),
+			Timeout:   timeout,
+			Transport: NewRateLimitingTransport(cfg.MaxRPS, newTransport(cfg)),
 		},
 		template: template,
 		workerCh: make(chan *http.Request, maxWorkers),
+		ended:    make(chan struct{}),
 	}
-	r.wg.Add(maxWorkers)
+	if cfg.Rate > 0 {
+		r.arrivals, _ = distribution.Arrivals("constant", cfg.Rate)
+	}
+	for _, opt := range options {
+		opt(r)
+	}
+	// The dispatcher publishes the arrivals it drops, it is waited upon
+	// alongside the workers.
+	r.wg.Add(maxWorkers + 1)
 	go r.spawn(maxWorkers)
 	return r
 }
 
+// newTransport returns the transport requests are sent with, holding the
+// pool of connections.
+func newTransport(cfg *config.Config) http.RoundTripper {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ForceAttemptHTTP2:     true,
+		MaxConnsPerHost:       cfg.MaxConnections,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig: &tls.Config{
+			// Skip server verification checks.  Enables testing against
+			// self signed/expired certs, wrong domain or untrusted.
+			InsecureSkipVerify: cfg.Insecure,
+		},
+	}
+	if cfg.Stream != "" {
+		transport.ResponseHeaderTimeout = cfg.Timeout
+	}
+	// gRPC requires HTTP/2, without TLS it is spoken from the outset (h2c).
+	if cfg.GRPC {
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	// The overrides were validated upfront.
+	if overrides, err := ParseResolve(cfg.Resolve); err == nil && len(overrides) > 0 {
+		transport.DialContext = resolvingDialer(overrides)
+	}
+	// TODO: Overhaul this.
+	return &RateLimitingTransport{Next: transport}
+}
+
+// NewClient returns a client configured as those requests are sent with,
+// for requests made outside of the run such as to discover what to send.
+func NewClient(cfg *config.Config) *http.Client {
+	return &http.Client{Timeout: cfg.Timeout, Transport: newTransport(cfg)}
+}
+
+// userClient returns the client a worker sends requests with.  Workers
+// share a single client unless they act as distinct virtual users, in
+// which case each is given its own cookie jar and, optionally, its own
+// pool of connections.  The rate limit remains shared by every worker.
+func (r *RequestCoordinator) userClient() *http.Client {
+	if !r.cfg.Sessions && !r.cfg.UserPool {
+		return r.client
+	}
+	client := *r.client
+	if r.cfg.Sessions {
+		// cookiejar.New never returns an error without options.
+		client.Jar, _ = cookiejar.New(nil)
+	}
+	if r.cfg.UserPool {
+		limiter := r.client.Transport.(*RateLimitingTransport)
+		client.Transport = &RateLimitingTransport{
+			Next:       newTransport(r.cfg),
+			sema:       limiter.sema,
+			throttling: limiter.throttling,
+		}
+	}
+	return &client
+}
+
 // Wait waits until all requests are finished and all workers
 // have cleanly shutdown.
 func (r *RequestCoordinator) Wait() {
@@ -81,26 +245,55 @@ func (r *RequestCoordinator) Wait() {
 // spawn fans out workers in the pool upto the configured
 // concurrency.
 func (r *RequestCoordinator) spawn(count int) {
-	for range count {
-		w := worker.New(r.client, r.workerCh, r.out, &r.wg, r.ctx, r.cfg)
+	for i := range count {
+		options := append([]worker.Option{worker.WithID(i), worker.WithEnd(r.ended)}, r.workerOpt...)
+		w := worker.New(r.userClient(), r.workerCh, r.out, &r.wg, r.ctx, r.cfg, options...)
 		go w.Accept()
 	}
 
 	// Asynchronously load requests into the queue.
 	// Depending on -d or -a (duration || amount) the strategy
 	// for loading requests onto the queues differs.
-	var seen int64
-	var tick <-chan time.Time
-	if dur := r.cfg.Duration; dur > 0 {
-		ticker := time.NewTicker(dur)
-		defer ticker.Stop()
-		tick = ticker.C
+	//
+	// Requests dispatched during the warm-up (if any) are marked so they
+	// can be excluded from the results, the measured phase begins once it
+	// is over and the pool it warmed is reused.
+	//
+	// Scheduled arrivals are an open model, they are never held back by
+	// busy workers.  An arrival waits in the queue for a free worker, its
+	// latency measured from when it was due, or is dropped if the queue is
+	// full.
+	var seen, dispatched, dropped int64
+	var tick <-chan struct{}
+	start := time.Now()
+	due := start
+	rng := distribution.NewRand(r.cfg.Seed, math.MaxUint64)
+	warming := r.cfg.Warmup > 0 || r.cfg.WarmupRequests > 0
+	// The shape begins once the measured phase does, holding its initial
+	// rate throughout the warm-up.
+	var measured time.Time
+	if !warming {
+		measured = start
 	}
 
 	defer func() {
 		close(r.workerCh)
+		if dropped > 0 {
+			r.out <- &stats.Stats{Dropped: dropped}
+		}
+		r.wg.Done()
 	}()
 	for {
+		if warming && dispatched >= r.cfg.WarmupRequests && time.Since(start) >= r.cfg.Warmup {
+			warming = false
+			measured = time.Now()
+		}
+		if dur := r.cfg.Duration; dur > 0 && tick == nil && !warming {
+			// Closing the channel also ends the streams still being read.
+			timer := time.AfterFunc(dur, func() { close(r.ended) })
+			defer timer.Stop()
+			tick = r.ended
+		}
 		select {
 		case <-tick:
 			// if a duration was set, we have reached it.
@@ -113,11 +306,111 @@ func (r *RequestCoordinator) spawn(count int) {
 		default:
 			// keep track of seen requests and keep providing requests
 			// to workers as fast as possible.
-			if tick == nil && seen == r.cfg.Amount {
+			if !warming && r.cfg.Duration == 0 && seen == r.cfg.Amount {
 				return
 			}
-			seen++
-			r.workerCh <- r.template
+			// Hold the request back until it is due if dispatching on an
+			// arrival schedule, each gap is relative to the previous arrival
+			// being due so that late dispatches catch up.
+			scheduled := r.arrivals != nil
+			if scheduled {
+				if dispatched > 0 {
+					due = r.next(due, measured, rng)
+				}
+				if !r.waitUntil(due, tick) {
+					return
+				}
+			}
+			request := r.template
+			if r.source != nil {
+				next, at, ok := r.source.Next(r.template)
+				if !ok || !r.waitUntil(start.Add(at), tick) {
+					return
+				}
+				if at > 0 && !scheduled {
+					due, scheduled = start.Add(at), true
+				}
+				request = next
+			}
+			dispatched++
+			ctx := request.Context()
+			if warming {
+				ctx = stats.WithWarmup(ctx)
+			} else {
+				seen++
+			}
+			if !scheduled {
+				// Workers stop taking requests once the run ends, even
+				// those pausing between iterations.
+				select {
+				case r.workerCh <- request.WithContext(ctx):
+				case <-tick:
+					return
+				case <-r.ctx.Done():
+					return
+				}
+				continue
+			}
+			select {
+			case r.workerCh <- request.WithContext(stats.WithDue(ctx, due)):
+			default:
+				if !warming {
+					dropped = r.drop(dropped)
+				}
+			}
 		}
 	}
 }
+
+// drop accounts for an arrival dropped on top of those pending and returns
+// the number still pending, they are published once doing so would not
+// hold up the schedule.
+func (r *RequestCoordinator) drop(pending int64) int64 {
+	pending++
+	select {
+	case r.out <- &stats.Stats{Dropped: pending}:
+		return 0
+	default:
+		return pending
+	}
+}
+
+// next returns when the arrival following the one due at the given time is
+// due.  With a shape the gap is scaled to its rate at the time, a period
+// in which the rate is zero is skipped.
+func (r *RequestCoordinator) next(due, measured time.Time, rng *rand.Rand) time.Time {
+	gap := r.arrivals.Sample(rng)
+	if r.shape == nil {
+		return due.Add(gap)
+	}
+	for {
+		var elapsed time.Duration
+		if !measured.IsZero() {
+			elapsed = max(0, due.Sub(measured))
+		}
+		rate := r.shape.Rate(elapsed)
+		if rate > 0 {
+			return due.Add(time.Duration(float64(gap) / rate))
+		}
+		due = due.Add(idle)
+	}
+}
+
+// waitUntil blocks until the given time, returning false if the duration
+// elapses or a signal is received in the meantime.
+func (r *RequestCoordinator) waitUntil(due time.Time, tick <-chan struct{}) bool {
+	wait := time.Until(due)
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-tick:
+		return false
+	case <-r.ctx.Done():
+		return false
+	}
+}
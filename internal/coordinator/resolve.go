@@ -0,0 +1,40 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ParseResolve parses overrides of the address connected to for a host and
+// port, each in the form host:port:address as with curl's --resolve, into
+// the address to dial for each host:port.
+func ParseResolve(entries []string) (map[string]string, error) {
+	overrides := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("resolve %q must be host:port:address", entry)
+		}
+		address := strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+		if net.ParseIP(address) == nil {
+			return nil, fmt.Errorf("resolve %q must map to an ip address", entry)
+		}
+		overrides[net.JoinHostPort(parts[0], parts[1])] = net.JoinHostPort(address, parts[1])
+	}
+	return overrides, nil
+}
+
+// resolvingDialer dials the overridden address of a host and port rather
+// than resolving it.
+func resolvingDialer(overrides map[string]string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if override, ok := overrides[addr]; ok {
+			addr = override
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
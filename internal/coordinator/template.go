@@ -2,15 +2,17 @@ package coordinator
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/symonk/vessel/internal/config"
 )
 
 // GenerateTemplateRequest generates a template http request that can
-// be cloned internally when sending > 1.  Care should be taken with the
-// generated request bodies in terms of 're-reading' them etc.  This
-// function is currently a naive implementation and offers no complexity
-// for anything other than a simple GET request.
+// be cloned internally when sending > 1.  The body, if any, is re-read
+// from the start for every clone through the request's GetBody.
 func GenerateTemplateRequest(cfg *config.Config) (*http.Request, error) {
-	return http.NewRequest(cfg.Method, cfg.Endpoint, nil)
+	if cfg.Body == "" {
+		return http.NewRequest(cfg.Method, cfg.Endpoint, nil)
+	}
+	return http.NewRequest(cfg.Method, cfg.Endpoint, strings.NewReader(cfg.Body))
 }
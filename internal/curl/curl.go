@@ -0,0 +1,293 @@
+// Package curl translates curl command lines into the configuration of a
+// load test, so that a request reproduced with curl can be load tested
+// without translating it by hand.
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/symonk/vessel/internal/config"
+)
+
+// ignored are the options which only affect curl's own output and take no
+// value, they have no bearing on the request sent.
+var ignored = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-v": true, "--verbose": true,
+	"-i": true, "--include": true, "-f": true, "--fail": true, "-#": true, "--progress-bar": true,
+	"--compressed": true, "--no-progress-meter": true, "-N": true, "--no-buffer": true,
+}
+
+// ignoredWithValue are the output options which take a value.
+var ignoredWithValue = map[string]bool{
+	"-o": true, "--output": true, "-w": true, "--write-out": true, "-D": true, "--dump-header": true,
+}
+
+// aliases maps short options onto their long form.
+var aliases = map[string]string{
+	"-X": "--request", "-H": "--header", "-d": "--data", "-u": "--user", "-k": "--insecure",
+	"-E": "--cert", "-A": "--user-agent", "-L": "--location", "-m": "--max-time", "-b": "--cookie",
+	"-e": "--referer", "-G": "--get", "-I": "--head",
+}
+
+// flags are the options which take no value.
+var flags = map[string]bool{
+	"--insecure": true, "--location": true, "--get": true, "--head": true, "--http2": true, "--http1.1": true,
+}
+
+// Parse parses a curl command line, see Split, into cfg overriding the
+// fields the options set.  The supported options are those describing the
+// request: the method, url, headers, data, user, cookie, referer, user
+// agent, insecure, cert, key, resolve, location, max time and the http
+// version.  Headers are appended to any already configured.  Options which
+// only affect curl's output (and --compressed, as responses are always
+// decompressed) are ignored, any other is an error.
+func Parse(command string, cfg *config.Config) error {
+	args, err := Split(command)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+	args = expand(args)
+
+	var (
+		data     []string
+		get      bool
+		method   string
+		endpoint string
+	)
+	// curl does not follow redirects unless asked to.
+	cfg.FollowRedirects = false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if ignored[arg] {
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if endpoint != "" {
+				return fmt.Errorf("only a single url is supported, got %q and %q", endpoint, arg)
+			}
+			endpoint = arg
+			continue
+		}
+		name := arg
+		if long, ok := aliases[arg]; ok {
+			name = long
+		}
+		var value string
+		if !flags[name] {
+			if i+1 == len(args) {
+				return fmt.Errorf("curl option %s requires a value", arg)
+			}
+			i++
+			value = args[i]
+		}
+		if ignoredWithValue[name] {
+			continue
+		}
+		switch name {
+		case "--url":
+			endpoint = value
+		case "--request":
+			method = strings.ToUpper(value)
+		case "--header":
+			cfg.Headers = append(cfg.Headers, value)
+		case "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode", "--json":
+			d, err := readData(name, value)
+			if err != nil {
+				return err
+			}
+			data = append(data, d)
+			if name == "--json" {
+				cfg.Headers = append(cfg.Headers, "Content-Type: application/json", "Accept: application/json")
+			}
+		case "--user":
+			cfg.BasicAuth = value
+		case "--cookie":
+			if !strings.Contains(value, "=") {
+				return fmt.Errorf("curl --cookie %q reads a cookie file, which is unsupported", value)
+			}
+			cfg.Headers = append(cfg.Headers, "Cookie: "+value)
+		case "--referer":
+			cfg.Headers = append(cfg.Headers, "Referer: "+value)
+		case "--user-agent":
+			cfg.UserAgent = value
+		case "--insecure":
+			cfg.Insecure = true
+		case "--cert":
+			cfg.Certificate = value
+		case "--key":
+			cfg.PrivateKey = value
+		case "--resolve":
+			cfg.Resolve = append(cfg.Resolve, value)
+		case "--location":
+			cfg.FollowRedirects = true
+		case "--max-time":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("curl --max-time %q must be a number of seconds", value)
+			}
+			cfg.Timeout = time.Duration(seconds * float64(time.Second))
+		case "--http2":
+			cfg.HTTP2 = true
+		case "--http1.1":
+			cfg.HTTP2 = false
+		case "--get":
+			get = true
+		case "--head":
+			method = "HEAD"
+		default:
+			return fmt.Errorf("unsupported curl option %s", arg)
+		}
+	}
+	if endpoint == "" {
+		return errors.New("the curl command has no url")
+	}
+
+	body := strings.Join(data, "&")
+	switch {
+	case get && body != "":
+		separator := "?"
+		if strings.Contains(endpoint, "?") {
+			separator = "&"
+		}
+		endpoint += separator + body
+		body = ""
+	case body != "" && !hasHeader(cfg.Headers, "Content-Type"):
+		cfg.Headers = append(cfg.Headers, "Content-Type: application/x-www-form-urlencoded")
+	}
+	if method == "" {
+		method = "GET"
+		if body != "" {
+			method = "POST"
+		}
+	}
+	cfg.Endpoint, cfg.Method, cfg.Body = endpoint, method, body
+	return nil
+}
+
+// readData returns the data of a data option, reading it from a file when
+// prefixed with @ as curl does.
+func readData(option, value string) (string, error) {
+	switch option {
+	case "--data-raw":
+		return value, nil
+	case "--data-urlencode":
+		// name=content encodes the content alone, as does =content.
+		if name, content, ok := strings.Cut(value, "="); ok {
+			if name == "" {
+				return url.QueryEscape(content), nil
+			}
+			return name + "=" + url.QueryEscape(content), nil
+		}
+		return url.QueryEscape(value), nil
+	}
+	path, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return value, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("curl %s: %w", option, err)
+	}
+	if option == "--data-binary" || option == "--json" {
+		return string(b), nil
+	}
+	// --data strips carriage returns and newlines from files.
+	return strings.NewReplacer("\r", "", "\n", "").Replace(string(b)), nil
+}
+
+// hasHeader reports whether the header is present.
+func hasHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		if k, _, _ := strings.Cut(h, ":"); strings.EqualFold(strings.TrimSpace(k), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand splits combined short options (e.g -sSL or -XPOST) into their
+// individual options.
+func expand(args []string) []string {
+	var out []string
+	for _, arg := range args {
+		if len(arg) <= 2 || arg[0] != '-' || arg[1] == '-' {
+			out = append(out, arg)
+			continue
+		}
+		for i := 1; i < len(arg); i++ {
+			short := "-" + string(arg[i])
+			out = append(out, short)
+			// The remainder is the value of an option which takes one.
+			if long, ok := aliases[short]; (ok && !flags[long]) || ignoredWithValue[short] {
+				if i+1 < len(arg) {
+					out = append(out, arg[i+1:])
+				}
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Split splits a command line into its arguments as a POSIX shell would,
+// honouring single and double quotes, backslash escapes and line
+// continuations.
+func Split(command string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		started bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range command {
+		switch {
+		case escaped:
+			escaped = false
+			// A double quoted backslash only escapes special characters.
+			if quote == '"' && !strings.ContainsRune("\\\"$`\n", r) {
+				current.WriteRune('\\')
+			}
+			// An escaped newline continues the line.
+			if r != '\n' {
+				current.WriteRune(r)
+				started = true
+			}
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, started = r, true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("the command has an unterminated quote")
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
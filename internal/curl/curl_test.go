@@ -0,0 +1,122 @@
+package curl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/config"
+)
+
+func TestSplit(t *testing.T) {
+	tests := map[string]struct {
+		command string
+		want    []string
+	}{
+		"plain":                {command: "curl -X GET http://a", want: []string{"curl", "-X", "GET", "http://a"}},
+		"single quotes":        {command: `curl -H 'A: "b" \c'`, want: []string{"curl", "-H", `A: "b" \c`}},
+		"double quotes":        {command: `curl -d "{\"a\": \$1}"`, want: []string{"curl", "-d", `{"a": $1}`}},
+		"escaped space":        {command: `curl a\ b`, want: []string{"curl", "a b"}},
+		"line continuations":   {command: "curl \\\n  -k \\\n  http://a", want: []string{"curl", "-k", "http://a"}},
+		"empty quoted":         {command: `curl -d ''`, want: []string{"curl", "-d", ""}},
+		"adjacent quoting":     {command: `curl 'a'"b"c`, want: []string{"curl", "abc"}},
+		"double quoted escape": {command: `curl "a\b"`, want: []string{"curl", `a\b`}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Split(tc.command)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+	_, err := Split(`curl 'a`)
+	assert.ErrorContains(t, err, "unterminated quote")
+}
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	require.NoError(t, os.WriteFile(data, []byte("a=1\nb=2\n"), 0o600))
+
+	tests := map[string]struct {
+		command string
+		want    config.Config
+	}{
+		"get": {
+			command: "curl https://a.com/x",
+			want:    config.Config{Endpoint: "https://a.com/x", Method: "GET"},
+		},
+		"data defaults to a form post": {
+			command: "curl https://a.com -d a=1 --data-raw @b",
+			want: config.Config{
+				Endpoint: "https://a.com", Method: "POST", Body: "a=1&@b",
+				Headers: []string{"Content-Type: application/x-www-form-urlencoded"},
+			},
+		},
+		"data from a file strips newlines": {
+			command: "curl https://a.com -d @" + data,
+			want: config.Config{
+				Endpoint: "https://a.com", Method: "POST", Body: "a=1b=2",
+				Headers: []string{"Content-Type: application/x-www-form-urlencoded"},
+			},
+		},
+		"json": {
+			command: `curl -X PUT --url https://a.com --json '{"a":1}'`,
+			want: config.Config{
+				Endpoint: "https://a.com", Method: "PUT", Body: `{"a":1}`,
+				Headers: []string{"Content-Type: application/json", "Accept: application/json"},
+			},
+		},
+		"get moves data into the query": {
+			command: "curl -G 'https://a.com?x=1' --data-urlencode 'q=a b'",
+			want:    config.Config{Endpoint: "https://a.com?x=1&q=a+b", Method: "GET"},
+		},
+		"attached values": {
+			command: "curl -sXPOST -m2 -HAccept:x https://a.com",
+			want:    config.Config{Endpoint: "https://a.com", Method: "POST", Timeout: 2 * time.Second, Headers: []string{"Accept:x"}},
+		},
+		"request options": {
+			command: "curl -sSLk -XDELETE https://a.com -u u:p -A agent -b 'a=1' -e https://r.com -m 1.5 --http2 --resolve a.com:443:127.0.0.1 -E c.pem --key k.pem -o /dev/null -w '%{http_code}' --compressed",
+			want: config.Config{
+				Endpoint: "https://a.com", Method: "DELETE", BasicAuth: "u:p", UserAgent: "agent",
+				Headers: []string{"Cookie: a=1", "Referer: https://r.com"},
+				Timeout: 1500 * time.Millisecond, HTTP2: true, Insecure: true, FollowRedirects: true,
+				Resolve: []string{"a.com:443:127.0.0.1"}, Certificate: "c.pem", PrivateKey: "k.pem",
+			},
+		},
+		"head": {
+			command: "curl -I https://a.com",
+			want:    config.Config{Endpoint: "https://a.com", Method: "HEAD"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var cfg config.Config
+			require.NoError(t, Parse(tc.command, &cfg))
+			assert.Equal(t, tc.want, cfg)
+		})
+	}
+}
+
+func TestParseRejectsBadCommands(t *testing.T) {
+	tests := map[string]struct {
+		command string
+		err     string
+	}{
+		"no url":         {command: "curl -k", err: "no url"},
+		"two urls":       {command: "curl https://a https://b", err: "single url"},
+		"unknown option": {command: "curl --proxy x https://a", err: "unsupported curl option --proxy"},
+		"missing value":  {command: "curl https://a -H", err: "requires a value"},
+		"bad max time":   {command: "curl https://a -m soon", err: "number of seconds"},
+		"cookie file":    {command: "curl https://a -b cookies.txt", err: "cookie file"},
+		"missing data":   {command: "curl https://a -d @missing", err: "--data"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorContains(t, Parse(tc.command, &config.Config{}), tc.err)
+		})
+	}
+}
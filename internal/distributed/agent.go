@@ -0,0 +1,342 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/runner"
+)
+
+// errNoPlan is returned when the controller asks the agent to poll again.
+var errNoPlan = errors.New("no plan yet")
+
+// reportBuffer is the number of intervals buffered for sending to the
+// controller, beyond which intervals are dropped rather than slowing the
+// run.
+const reportBuffer = 64
+
+// reportAttempts is the number of times sending an interval or the result
+// is attempted.
+const reportAttempts = 3
+
+// AgentOption is a functional option for the Agent.
+type AgentOption func(*Agent)
+
+// WithName sets the name the agent registers with, for diagnostics.
+func WithName(name string) AgentOption {
+	return func(a *Agent) {
+		a.name = name
+	}
+}
+
+// WithRunnerOptions applies the options to the agent's share of the run.
+func WithRunnerOptions(options ...runner.Option) AgentOption {
+	return func(a *Agent) {
+		a.options = append(a.options, options...)
+	}
+}
+
+// WithRetryInterval sets how often registration is retried while the
+// controller is unavailable, defaults to one second.
+func WithRetryInterval(interval time.Duration) AgentOption {
+	return func(a *Agent) {
+		a.retry = interval
+	}
+}
+
+// Agent runs its share of a load test on behalf of a controller.
+type Agent struct {
+	controller string
+	token      string
+	name       string
+	client     *http.Client
+	options    []runner.Option
+	retry      time.Duration
+}
+
+// NewAgent instantiates a new Agent which registers with the controller
+// at the given base URL, presenting the token shared with it.
+func NewAgent(controller, token string, options ...AgentOption) *Agent {
+	a := &Agent{
+		controller: strings.TrimSuffix(controller, "/"),
+		token:      token,
+		client:     &http.Client{Timeout: time.Minute},
+		retry:      time.Second,
+	}
+	for _, opt := range options {
+		opt(a)
+	}
+	return a
+}
+
+// Run registers with the controller, waits for the plan and runs the
+// agent's share of the load test, streaming intervals back throughout.
+// The result of the agent's share is returned.  The run is stopped should
+// the agent lose contact with the controller.
+func (a *Agent) Run(ctx context.Context) (*collector.Result, error) {
+	reg, err := a.register(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id := reg.ID
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	// Reporting outlives the caller stopping the run early, so that the work
+	// completed is still reported, but not losing contact with the controller.
+	reporting, stopReporting := context.WithCancelCause(context.WithoutCancel(ctx))
+	defer stopReporting(nil)
+	go a.heartbeat(ctx, id, reg.Heartbeat, func(err error) {
+		cancel(err)
+		stopReporting(err)
+	})
+
+	var plan *Plan
+	for plan == nil {
+		plan, err = a.plan(ctx, id)
+		if errors.Is(err, errNoPlan) {
+			continue
+		}
+		if err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				return nil, cause
+			}
+			return nil, err
+		}
+	}
+
+	// Begin in sync with every other agent.
+	start := time.NewTimer(plan.StartIn)
+	defer start.Stop()
+	select {
+	case <-start.C:
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+
+	reporter := newReporter(reporting, a, id)
+	options := append(append([]runner.Option{}, a.options...), runner.WithSinks(reporter))
+	result, err := runner.Run(ctx, plan.Config, options...)
+	// A run stopped early on losing the controller is not a success, unlike
+	// one stopped by the caller.
+	if cause := context.Cause(ctx); err == nil && cause != ctx.Err() {
+		err = cause
+	}
+	return result, err
+}
+
+// register registers with the controller, retrying until it is available.
+func (a *Agent) register(ctx context.Context) (*Registered, error) {
+	for {
+		reg := new(Registered)
+		err := a.do(ctx, http.MethodPost, registerPath, Registration{Name: a.name}, reg)
+		if err == nil {
+			return reg, nil
+		}
+		// Only retry while the controller is unreachable, a rejection is final.
+		var status *statusError
+		if errors.As(err, &status) {
+			return nil, fmt.Errorf("unable to register: %w", err)
+		}
+		select {
+		case <-time.After(a.retry):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("unable to register: %w", errors.Join(err, ctx.Err()))
+		}
+	}
+}
+
+// heartbeat sends heartbeats to the controller at the given interval until
+// ctx is done.  Contact is lost, and the run cancelled, once the controller
+// turns the agent away or has not been reached for three intervals.
+func (a *Agent) heartbeat(ctx context.Context, id string, interval time.Duration, cancel context.CancelCauseFunc) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	reached := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		err := a.do(ctx, http.MethodPost, agentPath(heartbeatPath, id), nil, nil)
+		if err == nil {
+			reached = time.Now()
+			continue
+		}
+		var status *statusError
+		if errors.As(err, &status) || time.Since(reached) > missedHeartbeats*interval {
+			cancel(fmt.Errorf("lost contact with the controller: %w", err))
+			return
+		}
+	}
+}
+
+// plan long polls the controller for the plan.
+func (a *Agent) plan(ctx context.Context, id string) (*Plan, error) {
+	plan := new(Plan)
+	if err := a.do(ctx, http.MethodGet, agentPath(planPath, id), nil, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// do sends body as JSON to the controller and decodes the response into
+// out, if any.  errNoPlan is returned for an empty response where one was
+// expected.
+func (a *Agent) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.controller+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= http.StatusBadRequest:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &statusError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	case resp.StatusCode == http.StatusNoContent && out != nil:
+		return errNoPlan
+	case out != nil:
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// statusError is an error response from the controller.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (s *statusError) Error() string {
+	return fmt.Sprintf("controller responded %d: %s", s.code, s.msg)
+}
+
+// agentPath substitutes the agent id into the path pattern.
+func agentPath(pattern, id string) string {
+	return strings.Replace(pattern, "{id}", id, 1)
+}
+
+// reporter is the sink streaming the agent's intervals and result back
+// to the controller.  Intervals are sent from a background goroutine, so
+// that a slow or unreachable controller does not hold up the run.
+type reporter struct {
+	collector.NopSink
+	ctx     context.Context
+	agent   *Agent
+	id      string
+	queue   chan collector.Interval
+	done    chan struct{}
+	dropped int
+	failed  int
+	err     error
+}
+
+// newReporter returns a reporter for the agent with the given id, which
+// sends intervals until OnFinish or ctx is done.
+func newReporter(ctx context.Context, agent *Agent, id string) *reporter {
+	r := &reporter{
+		ctx:   ctx,
+		agent: agent,
+		id:    id,
+		queue: make(chan collector.Interval, reportBuffer),
+		done:  make(chan struct{}),
+	}
+	go r.send()
+	return r
+}
+
+// OnInterval implements collector.Sink, queueing the interval to be sent.
+func (r *reporter) OnInterval(i collector.Interval) {
+	select {
+	case r.queue <- i:
+	default:
+		r.dropped++
+	}
+}
+
+// send sends the queued intervals, with the latency histogram taken from
+// the IntervalRecorder, to the controller until the queue is closed.
+func (r *reporter) send() {
+	defer close(r.done)
+	for i := range r.queue {
+		report := IntervalReport{Interval: i}
+		report.Interval.Latency = nil
+		if i.Latency != nil {
+			encoded, err := i.Latency.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+			if err != nil {
+				r.failed++
+				r.err = err
+				continue
+			}
+			report.Histogram = string(encoded)
+		}
+		if err := r.post(intervalsPath, report); err != nil {
+			r.failed++
+			r.err = err
+		}
+	}
+}
+
+// post sends body to the agent's path of the controller, retrying on
+// failure.
+func (r *reporter) post(path string, body any) error {
+	var err error
+	for attempt := range reportAttempts {
+		if attempt > 0 {
+			select {
+			case <-time.After(r.agent.retry):
+			case <-r.ctx.Done():
+				return errors.Join(err, r.ctx.Err())
+			}
+		}
+		if err = r.agent.do(r.ctx, http.MethodPost, agentPath(path, r.id), body, nil); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// OnFinish implements collector.Sink, reporting the result of the agent
+// once every queued interval has been sent.  Intervals which could not be
+// sent fail the agent's run, as the controller's intervals are incomplete.
+func (r *reporter) OnFinish(result *collector.Result) error {
+	close(r.queue)
+	<-r.done
+	var errs []error
+	if err := r.post(resultPath, result); err != nil {
+		errs = append(errs, fmt.Errorf("unable to report result: %w", err))
+	}
+	if r.failed > 0 {
+		errs = append(errs, fmt.Errorf("unable to send %d intervals: %w", r.failed, r.err))
+	}
+	if r.dropped > 0 {
+		errs = append(errs, fmt.Errorf("dropped %d intervals, the controller could not keep up", r.dropped))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,551 @@
+// Package distributed spreads a single load test across many agents, which
+// may run on separate machines, coordinated by a controller.
+//
+// Agents register with the controller over HTTP, presenting the token they
+// share with it, and long poll for the plan of the run.  Once the expected
+// number of agents have registered each is handed its share of the
+// configuration and a delay after which to start, so that all agents begin
+// (near enough) at the same time regardless of their clock.  Throughout the
+// run agents stream each interval, including its latency histogram, back to
+// the controller and finally the result of their run.  The controller
+// merges the histograms and counters losslessly into a single result.
+// Agents send heartbeats from registering until they report their result,
+// the run fails if an agent falls silent.
+//
+// The plan holds the full configuration, credentials included, and is sent
+// in the clear.  The controller should only be reachable over a trusted
+// network.
+package distributed
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/shape"
+)
+
+// Paths of the controller API.
+const (
+	registerPath  = "/v1/agents"
+	planPath      = "/v1/agents/{id}/plan"
+	intervalsPath = "/v1/agents/{id}/intervals"
+	resultPath    = "/v1/agents/{id}/result"
+	heartbeatPath = "/v1/agents/{id}/heartbeat"
+)
+
+// missedHeartbeats is the number of heartbeats an agent may miss before it
+// is considered lost.
+const missedHeartbeats = 3
+
+// Registration is sent by an agent to register with the controller.
+type Registration struct {
+	Name string `json:"name"`
+}
+
+// Registered is the response to a Registration.
+type Registered struct {
+	ID string `json:"id"`
+	// Heartbeat is the interval at which the agent must send heartbeats.
+	Heartbeat time.Duration `json:"heartbeat"`
+}
+
+// Plan describes the share of the run an agent is responsible for.
+type Plan struct {
+	Config *config.Config `json:"config"`
+	// StartIn is the delay, from receiving the plan, after which the
+	// agent must begin.
+	StartIn time.Duration `json:"start_in"`
+}
+
+// IntervalReport is sent by an agent each time an interval closes.
+type IntervalReport struct {
+	Interval collector.Interval `json:"interval"`
+	// Histogram is the compressed latency histogram of the interval.
+	Histogram string `json:"histogram"`
+}
+
+// newIntervalHistogram returns a histogram for the latencies of a single
+// interval, matching the precision of the IntervalRecorder.
+func newIntervalHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, 60_000_000, 2)
+}
+
+// NewToken returns a random token to share between a controller and its
+// agents.
+func NewToken() string {
+	return rand.Text()
+}
+
+// ControllerOption is a functional option for the Controller.
+type ControllerOption func(*Controller)
+
+// WithLead sets the delay between the final agent registering and the run
+// starting, defaults to two seconds.
+func WithLead(lead time.Duration) ControllerOption {
+	return func(c *Controller) {
+		c.lead = lead
+	}
+}
+
+// WithIntervalFunc calls fn with every interval of the run, merged across
+// all agents, once every agent has reported it.
+func WithIntervalFunc(fn func(collector.Interval)) ControllerOption {
+	return func(c *Controller) {
+		c.onInterval = fn
+	}
+}
+
+// WithPollTimeout sets how long a request for the plan is held open before
+// the agent is asked to poll again, defaults to thirty seconds.
+func WithPollTimeout(timeout time.Duration) ControllerOption {
+	return func(c *Controller) {
+		c.pollTimeout = timeout
+	}
+}
+
+// WithHeartbeat sets the interval at which agents send heartbeats,
+// defaults to five seconds.  An agent missing three in a row is lost and
+// fails the run.
+func WithHeartbeat(interval time.Duration) ControllerOption {
+	return func(c *Controller) {
+		c.heartbeat = interval
+	}
+}
+
+// Controller coordinates a run across the expected number of agents.  It
+// implements http.Handler serving the API agents communicate with, every
+// request must present the token as a bearer token.
+type Controller struct {
+	cfg         *config.Config
+	expected    int
+	token       string
+	lead        time.Duration
+	pollTimeout time.Duration
+	heartbeat   time.Duration
+	onInterval  func(collector.Interval)
+	mux         *http.ServeMux
+
+	mu        sync.Mutex
+	agents    map[string]*agentState
+	order     []string
+	startAt   time.Time
+	intervals map[time.Duration]*mergedInterval
+	emitted   []collector.Interval
+	ready     chan struct{}
+	finished  chan struct{}
+	aborted   error
+}
+
+// agentState is the controller's view of a single agent.
+type agentState struct {
+	name   string
+	plan   *Plan
+	last   time.Duration
+	seen   time.Time
+	result *collector.Result
+}
+
+// mergedInterval accumulates a single interval across every agent.
+type mergedInterval struct {
+	requests int64
+	errors   int64
+	rps      float64
+	latency  *hdrhistogram.Histogram
+}
+
+// NewController instantiates a new Controller which splits the run
+// described by cfg across the expected number of agents, which must
+// present the token.  An empty token is replaced by a random one, see
+// Token.
+func NewController(cfg *config.Config, agents int, token string, options ...ControllerOption) *Controller {
+	if token == "" {
+		token = NewToken()
+	}
+	c := &Controller{
+		cfg:         cfg,
+		expected:    max(1, agents),
+		token:       token,
+		lead:        2 * time.Second,
+		pollTimeout: 30 * time.Second,
+		heartbeat:   5 * time.Second,
+		agents:      make(map[string]*agentState),
+		intervals:   make(map[time.Duration]*mergedInterval),
+		ready:       make(chan struct{}),
+		finished:    make(chan struct{}),
+		mux:         http.NewServeMux(),
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	c.mux.HandleFunc("POST "+registerPath, c.register)
+	c.mux.HandleFunc("GET "+planPath, c.plan)
+	c.mux.HandleFunc("POST "+intervalsPath, c.interval)
+	c.mux.HandleFunc("POST "+resultPath, c.result)
+	c.mux.HandleFunc("POST "+heartbeatPath, c.beat)
+	return c
+}
+
+// ServeHTTP implements http.Handler, rejecting requests without the token.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) != 1 {
+		http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+	c.mux.ServeHTTP(w, r)
+}
+
+// Token returns the token agents must present.
+func (c *Controller) Token() string {
+	return c.token
+}
+
+// Ready is closed once every expected agent has registered.
+func (c *Controller) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// Wait blocks until every agent has reported its result and returns the
+// merged result of the run.  The run fails if ctx is done first or an agent
+// is lost, after which the remaining agents are turned away.
+func (c *Controller) Wait(ctx context.Context) (*collector.Result, error) {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.finished:
+			return c.merge()
+		case <-ctx.Done():
+			c.abort(ctx.Err())
+			return nil, ctx.Err()
+		case <-ticker.C:
+			if err := c.lost(); err != nil {
+				c.abort(err)
+				return nil, err
+			}
+		}
+	}
+}
+
+// merge merges the results reported by every agent.
+func (c *Controller) merge() (*collector.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	results := make([]*collector.Result, 0, len(c.order))
+	for _, id := range c.order {
+		results = append(results, c.agents[id].result)
+	}
+	merged, err := collector.MergeResults(results...)
+	if err != nil {
+		return nil, err
+	}
+	merged.Config = c.cfg.Redacted()
+	merged.Intervals = c.emitted
+	return merged, nil
+}
+
+// lost returns an error naming the first agent, which has yet to report
+// its result, that has missed too many heartbeats.
+func (c *Controller) lost() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timeout := missedHeartbeats * c.heartbeat
+	for _, id := range c.order {
+		a := c.agents[id]
+		if a.result == nil && time.Since(a.seen) > timeout {
+			return fmt.Errorf("lost %s (%s), no heartbeat for %s", id, a.name, timeout)
+		}
+	}
+	return nil
+}
+
+// abort fails the run, agents are turned away from then on.
+func (c *Controller) abort(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.aborted = err
+}
+
+// register registers an agent, once the final agent registers the plans
+// are created and the start time set.
+func (c *Controller) register(w http.ResponseWriter, r *http.Request) {
+	var reg Registration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aborted != nil {
+		http.Error(w, "run aborted: "+c.aborted.Error(), http.StatusGone)
+		return
+	}
+	if len(c.order) == c.expected {
+		http.Error(w, "all agents have already registered", http.StatusConflict)
+		return
+	}
+	id := "agent-" + strconv.Itoa(len(c.order)+1)
+	c.agents[id] = &agentState{name: reg.Name, last: -1, seen: time.Now()}
+	c.order = append(c.order, id)
+	if len(c.order) == c.expected {
+		for i, cfg := range Split(c.cfg, c.expected) {
+			c.agents[c.order[i]].plan = &Plan{Config: cfg}
+		}
+		c.startAt = time.Now().Add(c.lead)
+		close(c.ready)
+	}
+	writeJSON(w, Registered{ID: id, Heartbeat: c.heartbeat})
+}
+
+// beat records a heartbeat of an agent.
+func (c *Controller) beat(w http.ResponseWriter, r *http.Request) {
+	if _, ok := c.agent(w, r); !ok {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// plan long polls until every agent has registered and hands out the plan.
+func (c *Controller) plan(w http.ResponseWriter, r *http.Request) {
+	a, ok := c.agent(w, r)
+	if !ok {
+		return
+	}
+	timeout := time.NewTimer(c.pollTimeout)
+	defer timeout.Stop()
+	select {
+	case <-c.ready:
+	case <-timeout.C:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	c.mu.Lock()
+	plan := Plan{Config: a.plan.Config, StartIn: max(0, time.Until(c.startAt))}
+	c.mu.Unlock()
+	writeJSON(w, plan)
+}
+
+// interval merges an interval reported by an agent.
+func (c *Controller) interval(w http.ResponseWriter, r *http.Request) {
+	a, ok := c.agent(w, r)
+	if !ok {
+		return
+	}
+	var report IntervalReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h, err := hdrhistogram.Decode([]byte(report.Histogram))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := report.Interval
+	m, ok := c.intervals[i.Offset]
+	if !ok {
+		m = &mergedInterval{latency: newIntervalHistogram()}
+		c.intervals[i.Offset] = m
+	}
+	m.requests += i.Requests
+	m.errors += i.Errors
+	m.rps += i.RPS
+	m.latency.Merge(h)
+	a.last = max(a.last, i.Offset)
+	c.emit(false)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// result records the final result of an agent.
+func (c *Controller) result(w http.ResponseWriter, r *http.Request) {
+	a, ok := c.agent(w, r)
+	if !ok {
+		return
+	}
+	result := new(collector.Result)
+	if err := json.NewDecoder(r.Body).Decode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if a.result != nil {
+		http.Error(w, "result already reported", http.StatusConflict)
+		return
+	}
+	a.result = result
+	done := 0
+	for _, other := range c.agents {
+		if other.result != nil {
+			done++
+		}
+	}
+	if done == c.expected {
+		c.emit(true)
+		close(c.finished)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// emit emits, in order, the merged intervals every agent has moved beyond.
+// Agents which have finished are not waited upon.  With all set every
+// remaining interval is emitted.
+//
+// The lock must be held.
+func (c *Controller) emit(all bool) {
+	upto := time.Duration(-1)
+	if !all {
+		first := true
+		for _, a := range c.agents {
+			if a.result != nil {
+				continue
+			}
+			if first || a.last < upto {
+				upto = a.last
+			}
+			first = false
+		}
+	}
+	offsets := make([]time.Duration, 0, len(c.intervals))
+	for offset := range c.intervals {
+		if all || offset <= upto {
+			offsets = append(offsets, offset)
+		}
+	}
+	slices.Sort(offsets)
+	for _, offset := range offsets {
+		m := c.intervals[offset]
+		delete(c.intervals, offset)
+		i := collector.Interval{
+			Offset:   offset,
+			Requests: m.requests,
+			Errors:   m.errors,
+			RPS:      m.rps,
+			Mean:     m.latency.Mean(),
+			P50:      m.latency.ValueAtQuantile(50),
+			P90:      m.latency.ValueAtQuantile(90),
+			P99:      m.latency.ValueAtQuantile(99),
+			Max:      m.latency.Max(),
+		}
+		c.emitted = append(c.emitted, i)
+		if c.onInterval != nil {
+			c.onInterval(i)
+		}
+	}
+}
+
+// agent returns the state of the agent identified in the request path,
+// noting that it has been heard from.  Unknown agents, and every agent once
+// the run is aborted, are turned away.
+func (c *Controller) agent(w http.ResponseWriter, r *http.Request) (*agentState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aborted != nil {
+		http.Error(w, "run aborted: "+c.aborted.Error(), http.StatusGone)
+		return nil, false
+	}
+	a, ok := c.agents[r.PathValue("id")]
+	if !ok {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	a.seen = time.Now()
+	return a, true
+}
+
+// Split divides the run described by cfg between n agents.  The number of
+// requests, the concurrency, the arrival rate and the rate limit are shared
+// out (with any remainder given to the first agents), everything else is
+// copied.
+func Split(cfg *config.Config, n int) []*config.Config {
+	share := func(total, i int64) int64 {
+		s := total / int64(n)
+		if i < total%int64(n) {
+			s++
+		}
+		return s
+	}
+	configs := make([]*config.Config, n)
+	for i := range n {
+		c := *cfg
+		c.Concurrency = int(max(1, share(int64(cfg.Concurrency), int64(i))))
+		if cfg.Duration == 0 {
+			c.Amount = share(cfg.Amount, int64(i))
+		}
+		c.Rate = cfg.Rate / float64(n)
+		if s, err := shape.Parse(cfg.Shape); err == nil {
+			c.Shape = s.Scale(1 / float64(n)).String()
+		}
+		// Agents sharing a seed would share a schedule.
+		if cfg.Seed != 0 {
+			c.Seed = cfg.Seed + uint64(i)
+		}
+		if cfg.MaxRPS > 0 {
+			c.MaxRPS = int(max(1, share(int64(cfg.MaxRPS), int64(i))))
+		}
+		configs[i] = &c
+	}
+	return configs
+}
+
+// ValidateSplit ensures every agent has a share of the requests to send,
+// and that none of the options read local files, which the agents running
+// on other hosts would not have.
+func ValidateSplit(cfg *config.Config, n int) error {
+	if cfg.Duration == 0 && cfg.Amount < int64(n) {
+		return fmt.Errorf("%d requests cannot be shared between %d agents", cfg.Amount, n)
+	}
+	if option := localFile(cfg); option != "" {
+		return fmt.Errorf("%s is read from a local file the agents do not have, it cannot be shared between agents", option)
+	}
+	if cfg.Shape != "" {
+		if _, err := shape.Parse(cfg.Shape); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localFile returns the first option of cfg naming a local file, if any.
+func localFile(cfg *config.Config) string {
+	switch {
+	case cfg.Feeder != "":
+		return "the feeder"
+	case cfg.BearerFile != "":
+		return "the bearer token file"
+	case cfg.Certificate != "" || cfg.PrivateKey != "":
+		return "the client certificate"
+	case strings.HasPrefix(cfg.Arrival, "empirical:"):
+		return "the empirical arrival process"
+	}
+	for _, c := range cfg.Checks {
+		if strings.HasPrefix(strings.TrimSpace(c), "schema=") {
+			return "the schema check"
+		}
+	}
+	return ""
+}
+
+// writeJSON writes v as the JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
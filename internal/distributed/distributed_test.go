@@ -0,0 +1,208 @@
+package distributed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/config"
+)
+
+func TestSplit(t *testing.T) {
+	tests := map[string]struct {
+		cfg         config.Config
+		agents      int
+		concurrency []int
+		amount      []int64
+		rps         []int
+	}{
+		"even": {
+			cfg:         config.Config{Concurrency: 10, Amount: 100, MaxRPS: 50},
+			agents:      2,
+			concurrency: []int{5, 5},
+			amount:      []int64{50, 50},
+			rps:         []int{25, 25},
+		},
+		"remainder goes to the first agents": {
+			cfg:         config.Config{Concurrency: 10, Amount: 101, MaxRPS: 5},
+			agents:      3,
+			concurrency: []int{4, 3, 3},
+			amount:      []int64{34, 34, 33},
+			rps:         []int{2, 2, 1},
+		},
+		"every agent has a worker and unlimited rate is kept": {
+			cfg:         config.Config{Concurrency: 1, Amount: 3},
+			agents:      3,
+			concurrency: []int{1, 1, 1},
+			amount:      []int64{1, 1, 1},
+			rps:         []int{0, 0, 0},
+		},
+		"duration runs keep the amount": {
+			cfg:         config.Config{Concurrency: 4, Amount: 50, Duration: time.Second},
+			agents:      2,
+			concurrency: []int{2, 2},
+			amount:      []int64{50, 50},
+			rps:         []int{0, 0},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			configs := Split(&tc.cfg, tc.agents)
+			require.Len(t, configs, tc.agents)
+			for i, c := range configs {
+				assert.Equal(t, tc.concurrency[i], c.Concurrency)
+				assert.Equal(t, tc.amount[i], c.Amount)
+				assert.Equal(t, tc.rps[i], c.MaxRPS)
+			}
+		})
+	}
+}
+
+func TestSplitSeeds(t *testing.T) {
+	configs := Split(&config.Config{Concurrency: 3, Amount: 3, Seed: 7}, 3)
+	for i, c := range configs {
+		assert.Equal(t, uint64(7+i), c.Seed)
+	}
+	for _, c := range Split(&config.Config{Concurrency: 3, Amount: 3}, 3) {
+		assert.Zero(t, c.Seed)
+	}
+}
+
+func TestSplitShapes(t *testing.T) {
+	for _, c := range Split(&config.Config{Concurrency: 4, Duration: time.Second, Shape: "sine:min=10,max=100,period=1m0s"}, 2) {
+		assert.Equal(t, "sine:min=5,max=50,period=1m0s", c.Shape)
+	}
+	assert.ErrorContains(t, ValidateSplit(&config.Config{Duration: time.Second, Shape: "wave"}, 2), "unknown shape")
+}
+
+func TestValidateSplit(t *testing.T) {
+	assert.NoError(t, ValidateSplit(&config.Config{Amount: 2}, 2))
+	assert.NoError(t, ValidateSplit(&config.Config{Duration: time.Second}, 10))
+	assert.ErrorContains(t, ValidateSplit(&config.Config{Amount: 1}, 2), "cannot be shared")
+
+	local := map[string]*config.Config{
+		"the feeder":                    {Feeder: "users.csv"},
+		"the bearer token file":         {BearerFile: "token"},
+		"the client certificate":        {Certificate: "cert.pem", PrivateKey: "key.pem"},
+		"the empirical arrival process": {Rate: 10, Arrival: "empirical:gaps.txt"},
+		"the schema check":              {Checks: []string{"status=200", "schema=pet.json"}},
+	}
+	for option, cfg := range local {
+		cfg.Duration = time.Second
+		assert.ErrorContains(t, ValidateSplit(cfg, 2), option+" is read from a local file")
+	}
+	assert.NoError(t, ValidateSplit(&config.Config{Duration: time.Second, Arrival: "poisson", Checks: []string{"status=200"}}, 2))
+}
+
+func TestControllerMergesAgents(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	cfg := &config.Config{Endpoint: target.URL, Method: http.MethodGet, Concurrency: 4, Amount: 101, MaxConnections: 10}
+	var (
+		mu        sync.Mutex
+		intervals []collector.Interval
+	)
+	controller := NewController(cfg, 2, "token", WithLead(10*time.Millisecond), WithIntervalFunc(func(i collector.Interval) {
+		mu.Lock()
+		defer mu.Unlock()
+		intervals = append(intervals, i)
+	}))
+	server := httptest.NewServer(controller)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	results := make([]*collector.Result, 2)
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = NewAgent(server.URL, "token", WithName("test")).Run(ctx)
+		}()
+	}
+
+	merged, err := controller.Wait(ctx)
+	require.NoError(t, err)
+	wg.Wait()
+	for i := range 2 {
+		require.NoError(t, errs[i])
+	}
+	assert.ElementsMatch(t, []int64{51, 50}, []int64{results[0].Requests, results[1].Requests})
+	assert.Equal(t, int64(101), merged.Requests)
+	assert.Equal(t, int64(101), merged.StatusCodes[http.StatusOK])
+	assert.Equal(t, cfg.Redacted(), merged.Config)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, intervals)
+	assert.Equal(t, intervals, merged.Intervals)
+	var requests int64
+	for _, i := range intervals {
+		requests += i.Requests
+	}
+	assert.Equal(t, int64(101), requests)
+}
+
+func TestControllerRejectsExtraAgents(t *testing.T) {
+	controller := NewController(&config.Config{Amount: 1}, 1, "token", WithPollTimeout(10*time.Millisecond))
+	server := httptest.NewServer(controller)
+	defer server.Close()
+
+	agent := NewAgent(server.URL, "token")
+	_, err := agent.register(context.Background())
+	require.NoError(t, err)
+	_, err = agent.register(context.Background())
+	assert.ErrorContains(t, err, "409")
+}
+
+func TestControllerRequiresToken(t *testing.T) {
+	controller := NewController(&config.Config{Amount: 1}, 1, "")
+	require.NotEmpty(t, controller.Token())
+	server := httptest.NewServer(controller)
+	defer server.Close()
+
+	for _, token := range []string{"", "wrong"} {
+		_, err := NewAgent(server.URL, token).register(context.Background())
+		assert.ErrorContains(t, err, "401")
+	}
+	_, err := NewAgent(server.URL, controller.Token()).register(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestControllerFailsOnLostAgent(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	cfg := &config.Config{Endpoint: target.URL, Method: http.MethodGet, Concurrency: 1, Duration: time.Minute, MaxConnections: 1}
+	controller := NewController(cfg, 2, "token", WithLead(10*time.Millisecond), WithHeartbeat(20*time.Millisecond))
+	server := httptest.NewServer(controller)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	agentErr := make(chan error, 1)
+	go func() {
+		_, err := NewAgent(server.URL, "token", WithName("alive")).Run(ctx)
+		agentErr <- err
+	}()
+	// The second agent registers but never sends a heartbeat.
+	_, err := NewAgent(server.URL, "token", WithName("silent")).register(ctx)
+	require.NoError(t, err)
+
+	_, err = controller.Wait(ctx)
+	assert.ErrorContains(t, err, "(silent), no heartbeat")
+	assert.ErrorContains(t, <-agentErr, "lost contact with the controller")
+}
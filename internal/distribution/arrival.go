@@ -0,0 +1,115 @@
+package distribution
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Empirical samples uniformly from a set of observed durations.
+type Empirical []time.Duration
+
+// Sample implements Distribution.
+func (e Empirical) Sample(r *rand.Rand) time.Duration {
+	return e[r.IntN(len(e))]
+}
+
+// Mean implements Distribution.
+func (e Empirical) Mean() time.Duration {
+	var sum time.Duration
+	for _, d := range e {
+		sum += d
+	}
+	return sum / time.Duration(len(e))
+}
+
+// Scale returns the observations scaled such that their mean is the given
+// duration, preserving the shape of the distribution.
+func (e Empirical) Scale(mean time.Duration) Empirical {
+	factor := float64(mean) / float64(e.Mean())
+	scaled := make(Empirical, len(e))
+	for i, d := range e {
+		scaled[i] = time.Duration(float64(d) * factor)
+	}
+	return scaled
+}
+
+// ReadEmpirical reads observed durations from a file, one per line either
+// as a duration (e.g 120ms) or a number of seconds (e.g 0.12).  Blank lines
+// and lines beginning with # are ignored.
+func ReadEmpirical(path string) (Empirical, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var e Empirical
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		d, err := time.ParseDuration(line)
+		if err != nil {
+			seconds, ferr := strconv.ParseFloat(line, 64)
+			if ferr != nil {
+				return nil, fmt.Errorf("%s:%d: %q is neither a duration nor a number of seconds", path, n, line)
+			}
+			d = time.Duration(seconds * float64(time.Second))
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("%s:%d: %q is negative", path, n, line)
+		}
+		e = append(e, d)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(e) == 0 || e.Mean() == 0 {
+		return nil, fmt.Errorf("%s holds no gaps between arrivals", path)
+	}
+	return e, nil
+}
+
+// Arrivals returns the distribution of the gaps between the arrivals of
+// requests at the given rate per second, the process being one of:
+//
+//	constant           evenly spaced, the default
+//	poisson            exponentially distributed gaps, as independent arrivals
+//	uniform            gaps uniformly between zero and twice the mean
+//	empirical:file     gaps sampled from the file (see ReadEmpirical), scaled
+//	                   to the rate if one is given
+func Arrivals(process string, rate float64) (Distribution, error) {
+	if path, ok := strings.CutPrefix(process, "empirical:"); ok {
+		e, err := ReadEmpirical(path)
+		if err != nil {
+			return nil, err
+		}
+		if rate > 0 {
+			return e.Scale(gap(rate)), nil
+		}
+		return e, nil
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("%s arrivals require a rate", process)
+	}
+	switch process {
+	case "", "constant":
+		return Fixed(gap(rate)), nil
+	case "poisson":
+		return Exponential{Mu: gap(rate)}, nil
+	case "uniform":
+		return Uniform{Max: 2 * gap(rate)}, nil
+	}
+	return nil, fmt.Errorf("unknown arrival process %q, must be constant, poisson, uniform or empirical:file", process)
+}
+
+// gap is the mean gap between arrivals at the rate per second.
+func gap(rate float64) time.Duration {
+	return time.Duration(float64(time.Second) / rate)
+}
@@ -0,0 +1,149 @@
+// Package distribution samples durations from statistical distributions,
+// used to model the think time of virtual users and the gaps between the
+// arrival of requests.
+package distribution
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Distribution samples durations.  The source of randomness is provided
+// by the caller so that each goroutine can own one.
+type Distribution interface {
+	Sample(r *rand.Rand) time.Duration
+	// Mean is the expected value of a sample.
+	Mean() time.Duration
+}
+
+// Fixed always samples the same duration.
+type Fixed time.Duration
+
+// Sample implements Distribution.
+func (f Fixed) Sample(*rand.Rand) time.Duration { return time.Duration(f) }
+
+// Mean implements Distribution.
+func (f Fixed) Mean() time.Duration { return time.Duration(f) }
+
+// Uniform samples uniformly between Min and Max (inclusive).
+type Uniform struct {
+	Min, Max time.Duration
+}
+
+// Sample implements Distribution.
+func (u Uniform) Sample(r *rand.Rand) time.Duration {
+	return u.Min + time.Duration(r.Int64N(int64(u.Max-u.Min)+1))
+}
+
+// Mean implements Distribution.
+func (u Uniform) Mean() time.Duration { return u.Min + (u.Max-u.Min)/2 }
+
+// Normal samples from a normal distribution, negative samples are clamped
+// to zero.
+type Normal struct {
+	Mu, StdDev time.Duration
+}
+
+// Sample implements Distribution.
+func (n Normal) Sample(r *rand.Rand) time.Duration {
+	return max(0, n.Mu+time.Duration(r.NormFloat64()*float64(n.StdDev)))
+}
+
+// Mean implements Distribution.
+func (n Normal) Mean() time.Duration { return n.Mu }
+
+// Exponential samples from an exponential distribution with the given
+// mean, modelling the gaps between independent events such as the arrivals
+// of a Poisson process.
+type Exponential struct {
+	Mu time.Duration
+}
+
+// Sample implements Distribution.
+func (e Exponential) Sample(r *rand.Rand) time.Duration {
+	return time.Duration(r.ExpFloat64() * float64(e.Mu))
+}
+
+// Mean implements Distribution.
+func (e Exponential) Mean() time.Duration { return e.Mu }
+
+// Parse parses a distribution from its specification, one of:
+//
+//	500ms                a fixed duration
+//	fixed:500ms          a fixed duration
+//	uniform:200ms-800ms  uniformly between the two durations
+//	normal:500ms,100ms   normally distributed with a mean and standard deviation
+//	exponential:500ms    exponentially distributed with a mean (alias exp)
+func Parse(spec string) (Distribution, error) {
+	kind, arg, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		kind, arg = "fixed", kind
+	}
+	switch kind {
+	case "fixed":
+		d, err := duration(spec, arg)
+		if err != nil {
+			return nil, err
+		}
+		return Fixed(d), nil
+	case "uniform":
+		lo, hi, ok := strings.Cut(arg, "-")
+		if !ok {
+			return nil, fmt.Errorf("distribution %q must be uniform:min-max", spec)
+		}
+		min, err := duration(spec, lo)
+		if err != nil {
+			return nil, err
+		}
+		max, err := duration(spec, hi)
+		if err != nil {
+			return nil, err
+		}
+		if max < min {
+			return nil, fmt.Errorf("distribution %q has a maximum below its minimum", spec)
+		}
+		return Uniform{Min: min, Max: max}, nil
+	case "normal":
+		mean, stddev, ok := strings.Cut(arg, ",")
+		if !ok {
+			return nil, fmt.Errorf("distribution %q must be normal:mean,stddev", spec)
+		}
+		mu, err := duration(spec, mean)
+		if err != nil {
+			return nil, err
+		}
+		sigma, err := duration(spec, stddev)
+		if err != nil {
+			return nil, err
+		}
+		return Normal{Mu: mu, StdDev: sigma}, nil
+	case "exponential", "exp":
+		mu, err := duration(spec, arg)
+		if err != nil {
+			return nil, err
+		}
+		return Exponential{Mu: mu}, nil
+	}
+	return nil, fmt.Errorf("unknown distribution %q, must be fixed, uniform, normal or exponential", kind)
+}
+
+// duration parses a non negative duration of the specification.
+func duration(spec, s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("distribution %q has an invalid duration %q", spec, s)
+	}
+	return d, nil
+}
+
+// NewRand returns a source of randomness for the given stream of the seed,
+// allowing independent goroutines to sample reproducibly.  A zero seed is
+// replaced with a random one.
+func NewRand(seed uint64, stream uint64) *rand.Rand {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return rand.New(rand.NewPCG(seed, stream))
+}
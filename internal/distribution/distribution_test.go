@@ -0,0 +1,120 @@
+package distribution
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		spec string
+		want Distribution
+		err  string
+	}{
+		"bare duration":      {spec: "2s", want: Fixed(2 * time.Second)},
+		"fixed":              {spec: "fixed:500ms", want: Fixed(500 * time.Millisecond)},
+		"uniform":            {spec: "uniform:1s-3s", want: Uniform{Min: time.Second, Max: 3 * time.Second}},
+		"normal":             {spec: "normal:2s, 500ms", want: Normal{Mu: 2 * time.Second, StdDev: 500 * time.Millisecond}},
+		"exponential":        {spec: "exponential:2s", want: Exponential{Mu: 2 * time.Second}},
+		"exp alias":          {spec: "exp:1s", want: Exponential{Mu: time.Second}},
+		"unknown kind":       {spec: "gamma:1s", err: "unknown distribution"},
+		"bad duration":       {spec: "fixed:soon", err: "invalid duration"},
+		"negative duration":  {spec: "-1s", err: "invalid duration"},
+		"uniform needs both": {spec: "uniform:1s", err: "uniform:min-max"},
+		"uniform inverted":   {spec: "uniform:3s-1s", err: "below its minimum"},
+		"normal needs both":  {spec: "normal:1s", err: "normal:mean,stddev"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(tc.spec)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSample(t *testing.T) {
+	tests := map[string]struct {
+		dist     Distribution
+		min, max time.Duration
+	}{
+		"fixed":       {dist: Fixed(time.Second), min: time.Second, max: time.Second},
+		"uniform":     {dist: Uniform{Min: time.Second, Max: 2 * time.Second}, min: time.Second, max: 2 * time.Second},
+		"normal":      {dist: Normal{Mu: time.Second, StdDev: time.Second}, min: 0, max: time.Hour},
+		"exponential": {dist: Exponential{Mu: time.Second}, min: 0, max: time.Hour},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := NewRand(1, 0)
+			var sum time.Duration
+			const n = 10_000
+			for range n {
+				d := tc.dist.Sample(r)
+				assert.GreaterOrEqual(t, d, tc.min)
+				assert.LessOrEqual(t, d, tc.max)
+				sum += d
+			}
+			assert.InDelta(t, float64(tc.dist.Mean()), float64(sum/n), 0.1*float64(tc.dist.Mean()))
+		})
+	}
+}
+
+func TestNewRandIsReproducible(t *testing.T) {
+	a, b := NewRand(42, 1), NewRand(42, 1)
+	for range 10 {
+		assert.Equal(t, a.Uint64(), b.Uint64())
+	}
+	assert.NotEqual(t, NewRand(42, 1).Uint64(), NewRand(42, 2).Uint64())
+}
+
+func TestArrivals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gaps.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# observed\n100ms\n\n0.3\n"), 0o600))
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	require.NoError(t, os.WriteFile(empty, []byte("# nothing\n"), 0o600))
+
+	tests := map[string]struct {
+		process string
+		rate    float64
+		want    Distribution
+		err     string
+	}{
+		"default is constant":       {process: "", rate: 10, want: Fixed(100 * time.Millisecond)},
+		"constant":                  {process: "constant", rate: 4, want: Fixed(250 * time.Millisecond)},
+		"poisson":                   {process: "poisson", rate: 10, want: Exponential{Mu: 100 * time.Millisecond}},
+		"uniform":                   {process: "uniform", rate: 10, want: Uniform{Max: 200 * time.Millisecond}},
+		"empirical as observed":     {process: "empirical:" + path, want: Empirical{100 * time.Millisecond, 300 * time.Millisecond}},
+		"empirical scaled to rate":  {process: "empirical:" + path, rate: 10, want: Empirical{50 * time.Millisecond, 150 * time.Millisecond}},
+		"empirical requires gaps":   {process: "empirical:" + empty, err: "no gaps"},
+		"empirical requires a file": {process: "empirical:" + path + ".missing", err: "no such file"},
+		"poisson requires a rate":   {process: "poisson", err: "require a rate"},
+		"unknown process":           {process: "bursty", rate: 1, err: "unknown arrival process"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Arrivals(tc.process, tc.rate)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestReadEmpiricalRejectsGarbage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gaps.txt")
+	require.NoError(t, os.WriteFile(path, []byte("100ms\nsoon\n"), 0o600))
+	_, err := ReadEmpirical(path)
+	assert.ErrorContains(t, err, ":2:")
+}
@@ -0,0 +1,202 @@
+// Package grpc sends gRPC calls as ordinary HTTP/2 requests, encoding the
+// JSON form of request messages with descriptors loaded at runtime so
+// that methods can be called without generated code.
+package grpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/symonk/vessel/internal/hook"
+	"github.com/symonk/vessel/internal/protobuf"
+)
+
+// ContentType is the content type of gRPC requests and responses.
+const ContentType = "application/grpc"
+
+// Status codes of gRPC calls.
+const (
+	OK                 = 0
+	Cancelled          = 1
+	Unknown            = 2
+	InvalidArgument    = 3
+	DeadlineExceeded   = 4
+	NotFound           = 5
+	AlreadyExists      = 6
+	PermissionDenied   = 7
+	ResourceExhausted  = 8
+	FailedPrecondition = 9
+	Aborted            = 10
+	OutOfRange         = 11
+	Unimplemented      = 12
+	Internal           = 13
+	Unavailable        = 14
+	DataLoss           = 15
+	Unauthenticated    = 16
+)
+
+// codes are the names of the status codes, indexed by code.
+var codes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// CodeName returns the name of the status code, such as NOT_FOUND.
+func CodeName(code int) string {
+	if code < 0 || code >= len(codes) {
+		return strconv.Itoa(code)
+	}
+	return codes[code]
+}
+
+// ParseCode parses a status code given by name (case insensitively) or
+// number.
+func ParseCode(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	for code, name := range codes {
+		if strings.EqualFold(s, name) {
+			return code, nil
+		}
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil || code < 0 || code >= len(codes) {
+		return 0, fmt.Errorf("invalid grpc status code %q", s)
+	}
+	return code, nil
+}
+
+// Status returns the status code and message of a call once its body has
+// been read, the trailers are only populated then.  A response without a
+// grpc-status, such as an error from a proxy, is given the code its HTTP
+// status maps to.
+func Status(response *http.Response) (int, string) {
+	// Trailers-Only responses carry the status in their headers.
+	for _, header := range []http.Header{response.Trailer, response.Header} {
+		status := header.Get("Grpc-Status")
+		if status == "" {
+			continue
+		}
+		message, _ := url.PathUnescape(header.Get("Grpc-Message"))
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return Unknown, fmt.Sprintf("invalid grpc-status %q", status)
+		}
+		return code, message
+	}
+	switch response.StatusCode {
+	case http.StatusBadRequest:
+		return Internal, response.Status
+	case http.StatusUnauthorized:
+		return Unauthenticated, response.Status
+	case http.StatusForbidden:
+		return PermissionDenied, response.Status
+	case http.StatusNotFound:
+		return Unimplemented, response.Status
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return Unavailable, response.Status
+	}
+	return Unknown, response.Status
+}
+
+// Frame prefixes each message with the uncompressed flag and its length,
+// as the messages of a call are sent.
+func Frame(messages ...[]byte) []byte {
+	var b []byte
+	for _, m := range messages {
+		b = append(b, 0)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(m)))
+		b = append(b, m...)
+	}
+	return b
+}
+
+// ReadFrames splits the body of a call into its messages.  Compressed
+// messages are unsupported, no compression is ever requested.
+func ReadFrames(b []byte) ([][]byte, error) {
+	var messages [][]byte
+	for len(b) > 0 {
+		if len(b) < 5 {
+			return nil, errors.New("grpc: truncated message")
+		}
+		if b[0] != 0 {
+			return nil, errors.New("grpc: compressed messages are unsupported")
+		}
+		size := binary.BigEndian.Uint32(b[1:5])
+		if uint64(len(b)-5) < uint64(size) {
+			return nil, errors.New("grpc: truncated message")
+		}
+		messages = append(messages, b[5:5+size])
+		b = b[5+size:]
+	}
+	return messages, nil
+}
+
+// Encode encodes the JSON body of a call to the method.  The body is a
+// single message object, or for methods which stream requests an array
+// of them sent in turn.  An empty body sends an empty message.
+func Encode(method *protobuf.Method, body []byte) ([]byte, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return Frame(nil), nil
+	}
+	if method.ClientStreaming && body[0] == '[' {
+		var messages []json.RawMessage
+		if err := json.Unmarshal(body, &messages); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+		encoded := make([][]byte, len(messages))
+		for i, m := range messages {
+			var err error
+			if encoded[i], err = method.Marshal(m); err != nil {
+				return nil, fmt.Errorf("message %d: %w", i, err)
+			}
+		}
+		return Frame(encoded...), nil
+	}
+	message, err := method.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return Frame(message), nil
+}
+
+// Encoder returns a RequestHook replacing the JSON body of every request
+// with the call to the method it describes.  It must run after any hook
+// templating the body.
+func Encoder(method *protobuf.Method) hook.RequestHook {
+	return hook.RequestHookFunc(func(request *http.Request, _ hook.Info) error {
+		var body []byte
+		if request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(request.Body); err != nil {
+				return err
+			}
+			_ = request.Body.Close()
+		}
+		encoded, err := Encode(method, body)
+		if err != nil {
+			return fmt.Errorf("unable to encode %s: %w", method.Input.FullName(), err)
+		}
+		request.Body = io.NopCloser(bytes.NewReader(encoded))
+		request.ContentLength = int64(len(encoded))
+		request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(encoded)), nil
+		}
+		return nil
+	})
+}
+
+// Header returns the headers every call is sent with.
+func Header() []string {
+	return []string{"Content-Type: " + ContentType, "TE: trailers"}
+}
@@ -0,0 +1,167 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/hook"
+	"github.com/symonk/vessel/internal/protobuf"
+	"github.com/symonk/vessel/internal/test/mockserver"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestFrames(t *testing.T) {
+	b := Frame([]byte("ab"), nil, []byte("c"))
+	assert.Equal(t, []byte{0, 0, 0, 0, 2, 'a', 'b', 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 'c'}, b)
+	messages, err := ReadFrames(b)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("ab"), {}, []byte("c")}, messages)
+
+	_, err = ReadFrames(b[:len(b)-1])
+	assert.ErrorContains(t, err, "truncated")
+	_, err = ReadFrames([]byte{1, 0, 0, 0, 0})
+	assert.ErrorContains(t, err, "compressed")
+}
+
+func TestStatus(t *testing.T) {
+	tests := map[string]struct {
+		response *http.Response
+		code     int
+		message  string
+	}{
+		"trailers": {
+			response: &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Trailer: http.Header{"Grpc-Status": {"5"}, "Grpc-Message": {"no%20such%20thing"}}},
+			code:     NotFound,
+			message:  "no such thing",
+		},
+		"trailers only": {
+			response: &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Grpc-Status": {"12"}}},
+			code:     Unimplemented,
+		},
+		"invalid status": {
+			response: &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Grpc-Status": {"x"}}},
+			code:     Unknown,
+			message:  `invalid grpc-status "x"`,
+		},
+		"http status": {
+			response: &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Header: http.Header{}},
+			code:     Unavailable,
+			message:  "503 Service Unavailable",
+		},
+		"missing status": {
+			response: &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}},
+			code:     Unknown,
+			message:  "200 OK",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			code, message := Status(tc.response)
+			assert.Equal(t, tc.code, code)
+			assert.Equal(t, tc.message, message)
+		})
+	}
+}
+
+func TestParseCode(t *testing.T) {
+	tests := map[string]struct {
+		code int
+		ok   bool
+	}{
+		"OK":        {code: OK, ok: true},
+		"not_found": {code: NotFound, ok: true},
+		"16":        {code: Unauthenticated, ok: true},
+		"17":        {},
+		"NOPE":      {},
+	}
+	for s, tc := range tests {
+		t.Run(s, func(t *testing.T) {
+			code, err := ParseCode(s)
+			if !tc.ok {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.code, code)
+		})
+	}
+	assert.Equal(t, "UNAVAILABLE", CodeName(Unavailable))
+	assert.Equal(t, "99", CodeName(99))
+}
+
+func TestEncoder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "echo.protoset")
+	require.NoError(t, os.WriteFile(path, mockserver.GRPCDescriptorSet(), 0o600))
+	r, err := protobuf.ReadDescriptorSet(path)
+	require.NoError(t, err)
+	unary, err := r.Method("echo.Echo/Say")
+	require.NoError(t, err)
+	streaming, err := r.Method("echo.Echo/Collect")
+	require.NoError(t, err)
+	text := func(s string) []byte {
+		return protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), s)
+	}
+	a, b := text("a"), text("b")
+
+	tests := map[string]struct {
+		method *protobuf.Method
+		body   string
+		want   []byte
+		err    string
+	}{
+		"message":           {method: unary, body: `{"text": "a"}`, want: Frame(a)},
+		"empty body":        {method: unary, want: Frame(nil)},
+		"streamed messages": {method: streaming, body: ` [{"text": "a"}, {"text": "b"}]`, want: Frame(a, b)},
+		"single streamed":   {method: streaming, body: `{"text": "a"}`, want: Frame(a)},
+		"array to unary":    {method: unary, body: `[{"text": "a"}]`, err: "unexpected token ["},
+		"invalid message":   {method: streaming, body: `[{"text": "a"}, {"nope": 1}]`, err: "message 1"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var body io.Reader
+			if tc.body != "" {
+				body = strings.NewReader(tc.body)
+			}
+			r := httptest.NewRequest(http.MethodPost, "http://localhost/"+tc.method.Name, body)
+			err := Encoder(tc.method).BeforeRequest(r, hook.Info{})
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tc.want)), r.ContentLength)
+			got, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(tc.want, got))
+		})
+	}
+}
+
+func TestReflect(t *testing.T) {
+	server := mockserver.New(mockserver.WithGRPCEchoHandler())
+	defer server.Close()
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: transport}
+
+	// The v1 service is unimplemented, the v1alpha one is fallen back to.
+	r, err := Reflect(context.Background(), client, server.Server.URL, nil, "echo.Echo")
+	require.NoError(t, err)
+	assert.True(t, r.Has("google/protobuf/empty.proto"))
+	assert.Equal(t, []string{"echo.Echo/Collect", "echo.Echo/Ping", "echo.Echo/Repeat", "echo.Echo/Say"}, r.Methods())
+	method, err := r.Method("echo.Echo/Ping")
+	require.NoError(t, err)
+	assert.Equal(t, "google.protobuf.Empty", string(method.Input.FullName()))
+
+	_, err = Reflect(context.Background(), client, server.Server.URL, nil, "other.Other")
+	assert.ErrorContains(t, err, "reflection: not found")
+}
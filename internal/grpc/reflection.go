@@ -0,0 +1,203 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/symonk/vessel/internal/protobuf"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// reflectionServices are the server reflection services, the preferred
+// first, the older v1alpha is still served by many servers alone.
+var reflectionServices = []string{
+	"grpc.reflection.v1.ServerReflection",
+	"grpc.reflection.v1alpha.ServerReflection",
+}
+
+// Fields of the reflection messages.
+const (
+	requestFileByFilename       = 3
+	requestFileContainingSymbol = 4
+	responseFileDescriptor      = 4
+	responseError               = 7
+	fileDescriptorProto         = 1
+	errorMessage                = 2
+)
+
+// Reflect loads the descriptors of the service, and the files it depends
+// upon, through the server reflection service of the server at target (a
+// base URL such as http://localhost:50051).  The header is sent with every
+// call, for example to authorize them.
+func Reflect(ctx context.Context, client *http.Client, target string, header http.Header, service string) (*protobuf.Registry, error) {
+	target = strings.TrimSuffix(target, "/")
+	var err error
+	for _, reflection := range reflectionServices {
+		var r *protobuf.Registry
+		if r, err = reflect(ctx, client, target+"/"+reflection+"/ServerReflectionInfo", header, service); err == nil {
+			return r, nil
+		}
+		var status *statusError
+		if !errors.As(err, &status) || status.code != Unimplemented {
+			break
+		}
+	}
+	return nil, fmt.Errorf("server reflection failed: %w", err)
+}
+
+// reflect loads the service through the reflection method at url, then
+// each round of dependencies in turn.
+func reflect(ctx context.Context, client *http.Client, url string, header http.Header, service string) (*protobuf.Registry, error) {
+	r := protobuf.NewRegistry()
+	requests := [][]byte{reflectionRequest(requestFileContainingSymbol, service)}
+	requested := make(map[string]bool)
+	for len(requests) > 0 {
+		responses, err := call(ctx, client, url, header, requests...)
+		if err != nil {
+			return nil, err
+		}
+		var missing []string
+		for i, response := range responses {
+			files, err := descriptors(response)
+			if err != nil {
+				// The symbol must be found, a dependency which cannot be is
+				// only a problem if a type it holds is used.
+				if i == 0 && len(requested) == 0 {
+					return nil, err
+				}
+				continue
+			}
+			for _, file := range files {
+				dependencies, err := r.AddFile(file)
+				if err != nil {
+					return nil, fmt.Errorf("invalid file descriptor: %w", err)
+				}
+				missing = append(missing, dependencies...)
+			}
+		}
+		requests = requests[:0]
+		for _, file := range missing {
+			if r.Has(file) || requested[file] {
+				continue
+			}
+			requested[file] = true
+			requests = append(requests, reflectionRequest(requestFileByFilename, file))
+		}
+	}
+	return r, nil
+}
+
+// reflectionRequest encodes a ServerReflectionRequest for the symbol or
+// file given as field.
+func reflectionRequest(field protowire.Number, name string) []byte {
+	return protowire.AppendString(protowire.AppendTag(nil, field, protowire.BytesType), name)
+}
+
+// descriptors returns the encoded files of a ServerReflectionResponse.
+func descriptors(response []byte) ([][]byte, error) {
+	var (
+		files   [][]byte
+		failure error
+	)
+	err := messageFields(response, func(number protowire.Number, value []byte) error {
+		switch number {
+		case responseFileDescriptor:
+			return messageFields(value, func(number protowire.Number, value []byte) error {
+				if number == fileDescriptorProto {
+					files = append(files, value)
+				}
+				return nil
+			})
+		case responseError:
+			message := "not found"
+			err := messageFields(value, func(number protowire.Number, value []byte) error {
+				if number == errorMessage {
+					message = string(value)
+				}
+				return nil
+			})
+			failure = fmt.Errorf("reflection: %s", message)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return files, nil
+}
+
+// messageFields calls fn with every length delimited field of the encoded
+// message, the only kind the reflection responses are read for.
+func messageFields(b []byte, fn func(protowire.Number, []byte) error) error {
+	for len(b) > 0 {
+		number, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(number, typ, b); n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(number, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statusError is a call which completed with a status other than OK.
+type statusError struct {
+	code    int
+	message string
+}
+
+// Error implements error.
+func (s *statusError) Error() string {
+	return fmt.Sprintf("rpc error: code = %s desc = %s", CodeName(s.code), s.message)
+}
+
+// call sends the messages to the method at url, half closing once they
+// are sent, and returns the messages received in reply.
+func call(ctx context.Context, client *http.Client, url string, header http.Header, messages ...[]byte) ([][]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(Frame(messages...)))
+	if err != nil {
+		return nil, err
+	}
+	request.Header = header.Clone()
+	if request.Header == nil {
+		request.Header = make(http.Header)
+	}
+	request.Header.Set("Content-Type", ContentType)
+	request.Header.Set("TE", "trailers")
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if code, message := Status(response); code != OK {
+		return nil, &statusError{code: code, message: message}
+	}
+	return ReadFrames(body)
+}
@@ -0,0 +1,168 @@
+// Package har imports the requests captured in HTTP Archive (HAR) files,
+// such as those exported by browser developer tools, so that a recorded
+// session can be run as a load test.
+package har
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/symonk/vessel/internal/scenario"
+)
+
+// archive is the subset of the HAR 1.2 format describing requests.
+type archive struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// skipped are the headers which are not replayed, they either describe
+// the connection or are managed by the client.
+var skipped = map[string]bool{
+	"Host":              true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Accept-Encoding":   true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"Te":                true,
+	"Trailer":           true,
+}
+
+// Option is a functional option for ReadFile.
+type Option func(*reader)
+
+// reader holds the options of ReadFile.
+type reader struct {
+	domains      []string
+	stripCookies bool
+}
+
+// WithDomains keeps only the requests to the domains, or their subdomains.
+func WithDomains(domains ...string) Option {
+	return func(r *reader) {
+		r.domains = append(r.domains, domains...)
+	}
+}
+
+// WithoutCookies strips the cookies captured with each request.
+func WithoutCookies() Option {
+	return func(r *reader) {
+		r.stripCookies = true
+	}
+}
+
+// ReadFile reads the requests of a HAR file in the order they were
+// captured.  Entries which are not HTTP requests, such as the data:, blob:
+// and extension urls browsers also record, are skipped.
+func ReadFile(path string, options ...Option) ([]scenario.Request, error) {
+	r := &reader{}
+	for _, opt := range options {
+		opt(r)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var a archive
+	if err := json.Unmarshal(b, &a); err != nil {
+		return nil, fmt.Errorf("unable to read har %s: %w", path, err)
+	}
+	var entries []scenario.Request
+	for i, captured := range a.Log.Entries {
+		req := captured.Request
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return nil, fmt.Errorf("har %s entry %d has an invalid url %q", path, i, req.URL)
+		}
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" || !r.keep(u.Hostname()) {
+			continue
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("har %s entry %d has an invalid url %q", path, i, req.URL)
+		}
+		if _, err := http.NewRequest(req.Method, req.URL, nil); err != nil {
+			return nil, fmt.Errorf("har %s entry %d is invalid: %w", path, i, err)
+		}
+		e := scenario.Request{Method: req.Method, URL: req.URL, Header: make(http.Header), Weight: 1}
+		if e.Method == "" {
+			e.Method = http.MethodGet
+		}
+		for _, h := range req.Headers {
+			name := http.CanonicalHeaderKey(h.Name)
+			if strings.HasPrefix(name, ":") || skipped[name] || (r.stripCookies && name == "Cookie") {
+				continue
+			}
+			e.Header.Add(name, h.Value)
+		}
+		if data := req.PostData; data != nil {
+			e.Body = []byte(data.Text)
+			if data.Encoding == "base64" {
+				if e.Body, err = base64.StdEncoding.DecodeString(data.Text); err != nil {
+					return nil, fmt.Errorf("har %s entry %d has an invalid body: %w", path, i, err)
+				}
+			}
+			if data.MimeType != "" && e.Header.Get("Content-Type") == "" {
+				e.Header.Set("Content-Type", data.MimeType)
+			}
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("har %s has no requests to send", path)
+	}
+	return entries, nil
+}
+
+// keep reports whether requests to the host should be kept.
+func (r *reader) keep(host string) bool {
+	if len(r.domains) == 0 {
+		return true
+	}
+	for _, d := range r.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Weigh sets the weight of the requests whose url contains the pattern of
+// each pattern=weight, later patterns taking precedence.
+func Weigh(entries []scenario.Request, weights []string) error {
+	for _, w := range weights {
+		pattern, value, ok := strings.Cut(w, "=")
+		weight, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil || weight < 0 {
+			return fmt.Errorf("weight %q must be pattern=weight", w)
+		}
+		for i := range entries {
+			if strings.Contains(entries[i].URL, pattern) {
+				entries[i].Weight = weight
+			}
+		}
+	}
+	return nil
+}
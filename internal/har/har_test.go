@@ -0,0 +1,131 @@
+package har
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/scenario"
+)
+
+const archiveJSON = `{"log": {"version": "1.2", "entries": [
+	{"request": {"method": "GET", "url": "https://www.shop.com/", "headers": [
+		{"name": ":authority", "value": "www.shop.com"},
+		{"name": "accept", "value": "text/html"},
+		{"name": "cookie", "value": "session=abc"},
+		{"name": "accept-encoding", "value": "gzip, br"}
+	]}},
+	{"request": {"method": "GET", "url": "https://cdn.other.com/app.js", "headers": []}},
+	{"request": {"method": "GET", "url": "data:image/png;base64,iVBORw0KGgo=", "headers": []}},
+	{"request": {"method": "GET", "url": "blob:https://www.shop.com/0b1c", "headers": []}},
+	{"request": {"method": "GET", "url": "chrome-extension://abcdef/script.js", "headers": []}},
+	{"request": {"method": "POST", "url": "https://api.shop.com/basket", "headers": [
+		{"name": "Content-Length", "value": "11"}
+	], "postData": {"mimeType": "application/json", "text": "eyJpZCI6IDF9", "encoding": "base64"}}}
+]}}`
+
+func write(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "session.har")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	path := write(t, archiveJSON)
+
+	tests := map[string]struct {
+		options []Option
+		names   []string
+		cookie  string
+	}{
+		"every request": {
+			names:  []string{"GET https://www.shop.com/", "GET https://cdn.other.com/app.js", "POST https://api.shop.com/basket"},
+			cookie: "session=abc",
+		},
+		"filtered by domain": {
+			options: []Option{WithDomains("shop.com")},
+			names:   []string{"GET https://www.shop.com/", "POST https://api.shop.com/basket"},
+			cookie:  "session=abc",
+		},
+		"filtered by subdomain": {
+			options: []Option{WithDomains("api.shop.com", "cdn.other.com")},
+			names:   []string{"GET https://cdn.other.com/app.js", "POST https://api.shop.com/basket"},
+		},
+		"without cookies": {
+			options: []Option{WithoutCookies()},
+			names:   []string{"GET https://www.shop.com/", "GET https://cdn.other.com/app.js", "POST https://api.shop.com/basket"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			entries, err := ReadFile(path, tc.options...)
+			require.NoError(t, err)
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			assert.Equal(t, tc.names, names)
+			if entries[0].URL == "https://www.shop.com/" {
+				assert.Equal(t, tc.cookie, entries[0].Header.Get("Cookie"))
+				assert.Equal(t, "text/html", entries[0].Header.Get("Accept"))
+				assert.Empty(t, entries[0].Header.Get("Accept-Encoding"))
+				assert.NotContains(t, entries[0].Header, ":authority")
+			}
+		})
+	}
+}
+
+func TestReadFileDecodesBodies(t *testing.T) {
+	entries, err := ReadFile(write(t, archiveJSON), WithDomains("api.shop.com"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, `{"id": 1}`, string(entries[0].Body))
+	assert.Equal(t, "application/json", entries[0].Header.Get("Content-Type"))
+	assert.Empty(t, entries[0].Header.Get("Content-Length"))
+}
+
+func TestReadFileFiltersBeforeValidating(t *testing.T) {
+	// An entry the domains exclude is skipped however malformed it is.
+	content := `{"log": {"entries": [
+		{"request": {"method": "GET", "url": "/relative"}},
+		{"request": {"method": "GE T", "url": "http://other.com"}},
+		{"request": {"method": "GET", "url": "https://shop.com/"}}
+	]}}`
+	entries, err := ReadFile(write(t, content), WithDomains("shop.com"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "https://shop.com/", entries[0].URL)
+}
+
+func TestReadFileErrors(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		options []Option
+		err     string
+	}{
+		"not json":      {content: "<html>", err: "unable to read har"},
+		"relative url":  {content: `{"log": {"entries": [{"request": {"method": "GET", "url": "/a"}}]}}`, err: "invalid url"},
+		"bad method":    {content: `{"log": {"entries": [{"request": {"method": "GE T", "url": "http://a.com"}}]}}`, err: "entry 0 is invalid"},
+		"only data":     {content: `{"log": {"entries": [{"request": {"method": "GET", "url": "data:,a"}}]}}`, err: "no requests"},
+		"nothing left":  {content: archiveJSON, options: []Option{WithDomains("nowhere.com")}, err: "no requests"},
+		"bad base64":    {content: `{"log": {"entries": [{"request": {"method": "POST", "url": "http://a.com", "postData": {"text": "%%", "encoding": "base64"}}}]}}`, err: "invalid body"},
+		"empty archive": {content: `{"log": {"entries": []}}`, err: "no requests"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadFile(write(t, tc.content), tc.options...)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestWeigh(t *testing.T) {
+	entries := []scenario.Request{{URL: "https://a.com/api/search", Weight: 1}, {URL: "https://a.com/static/x.js", Weight: 1}}
+	require.NoError(t, Weigh(entries, []string{"/api/=5", "search=3", "/static/=0"}))
+	assert.Equal(t, 3.0, entries[0].Weight)
+	assert.Equal(t, 0.0, entries[1].Weight)
+	assert.ErrorContains(t, Weigh(entries, []string{"/api/"}), "pattern=weight")
+	assert.ErrorContains(t, Weigh(entries, []string{"/api/=-1"}), "pattern=weight")
+}
@@ -0,0 +1,101 @@
+package hdrlog
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+func TestSinkWritesIntervals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.hlog")
+	start := time.UnixMilli(1_700_000_000_000)
+	sink, err := NewSink(path, start, time.Second)
+	require.NoError(t, err)
+	at := func(offset time.Duration, latency time.Duration) *stats.Stats {
+		return &stats.Stats{Began: start.Add(offset), Latency: latency, TimeOnDns: time.Millisecond, TimeOnConn: 2 * time.Millisecond}
+	}
+	sink.OnResult(at(100*time.Millisecond, 10*time.Millisecond))
+	sink.OnResult(at(200*time.Millisecond, 20*time.Millisecond))
+	sink.OnResult(&stats.Stats{Began: start.Add(300 * time.Millisecond), Err: errors.New("failed")})
+	sink.OnResult(at(2500*time.Millisecond, 30*time.Millisecond))
+	require.NoError(t, sink.OnFinish(nil))
+
+	log, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, start.Equal(log.Start))
+
+	var latencies []Interval
+	for _, i := range log.Intervals {
+		if i.Tag == Latency {
+			latencies = append(latencies, i)
+		}
+	}
+	require.Len(t, latencies, 3)
+	for n, want := range []int64{2, 0, 1} {
+		assert.Equal(t, want, latencies[n].Histogram.TotalCount())
+		assert.True(t, start.Add(time.Duration(n)*time.Second).Equal(latencies[n].Start))
+		assert.Equal(t, time.Second, latencies[n].Length)
+	}
+
+	merged := Merge(log)
+	assert.Equal(t, int64(3), merged[Latency].TotalCount())
+	assert.Equal(t, int64(3), merged[DNS].TotalCount())
+	assert.Equal(t, int64(3), merged[GetConn].TotalCount())
+	assert.NotContains(t, merged, TLS)
+	assert.InDelta(t, 20_000, merged[Latency].ValueAtQuantile(50), 20)
+}
+
+func TestMergedLogsRoundTrip(t *testing.T) {
+	logs := make([]*Log, 0, 2)
+	for i, offset := range []time.Duration{0, 1500 * time.Millisecond} {
+		start := time.UnixMilli(1_700_000_000_000).Add(offset)
+		h := NewHistogram()
+		require.NoError(t, h.RecordValue(int64(1000*(i+1))))
+		var buf bytes.Buffer
+		w := NewWriter(&buf, start)
+		require.NoError(t, w.WriteHeader())
+		require.NoError(t, w.WriteInterval(Latency, 0, time.Second, h))
+		require.NoError(t, w.Flush())
+		log, err := Read(&buf)
+		require.NoError(t, err)
+		logs = append(logs, log)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMerged(&buf, logs[1], logs[0]))
+	merged, err := Read(&buf)
+	require.NoError(t, err)
+	require.Len(t, merged.Intervals, 2)
+	assert.True(t, logs[0].Start.Equal(merged.Start))
+	assert.True(t, logs[1].Start.Equal(merged.Intervals[1].Start))
+	assert.Equal(t, int64(2), Merge(merged)[Latency].TotalCount())
+}
+
+func TestReadAbsoluteTimestamps(t *testing.T) {
+	h := NewHistogram()
+	require.NoError(t, h.RecordValue(500))
+	var buf bytes.Buffer
+	w := NewWriter(&buf, time.Unix(0, 0))
+	require.NoError(t, w.WriteInterval("A", 1_700_000_000*time.Second, time.Second, h))
+	require.NoError(t, w.Flush())
+
+	log, err := Read(strings.NewReader("#comment\n" + buf.String()))
+	require.NoError(t, err)
+	require.Len(t, log.Intervals, 1)
+	assert.Equal(t, "A", log.Intervals[0].Tag)
+	assert.Equal(t, int64(1_700_000_000), log.Intervals[0].Start.Unix())
+}
+
+func TestReadRejectsMalformedLines(t *testing.T) {
+	for _, line := range []string{"1,2,3", "x,1,1,HISTF", "0,1,1,notbase64"} {
+		_, err := Read(strings.NewReader(line + "\n"))
+		assert.Error(t, err, line)
+	}
+}
@@ -0,0 +1,184 @@
+package hdrlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Interval is a single histogram read from an interval log.
+type Interval struct {
+	Tag       string
+	Start     time.Time
+	Length    time.Duration
+	Histogram *hdrhistogram.Histogram
+}
+
+// Log is the content of an interval log.
+type Log struct {
+	// Start is the start time of the log, or the start of the first
+	// interval if the log does not record one.
+	Start     time.Time
+	Intervals []Interval
+}
+
+// ReadFile reads the interval log at path.
+func ReadFile(path string) (*Log, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	log, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	return log, nil
+}
+
+// Read reads an interval log written by vessel or any other HdrHistogram
+// implementation.  Timestamps are relative to the base time if the log
+// records one, or absolute otherwise.
+func Read(r io.Reader) (*Log, error) {
+	var (
+		log               = new(Log)
+		startTime         float64
+		baseTime          float64
+		hasStart, hasBase bool
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "", strings.HasPrefix(text, `"`):
+			continue
+		case strings.HasPrefix(text, "#"):
+			if v, ok := header(text, "StartTime"); ok {
+				startTime, hasStart = v, true
+			}
+			// Some writers spell it Basetime.
+			if v, ok := header(strings.Replace(text, "Basetime", "BaseTime", 1), "BaseTime"); ok {
+				baseTime, hasBase = v, true
+			}
+			continue
+		}
+
+		var tag string
+		if rest, ok := strings.CutPrefix(text, "Tag="); ok {
+			tag, text, ok = strings.Cut(rest, ",")
+			if !ok {
+				return nil, fmt.Errorf("line %d: malformed tag", line)
+			}
+		}
+		fields := strings.SplitN(text, ",", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: expected 4 columns, got %d", line, len(fields))
+		}
+		start, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad start timestamp: %w", line, err)
+		}
+		length, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad interval length: %w", line, err)
+		}
+		h, err := hdrhistogram.Decode([]byte(fields[3]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad histogram: %w", line, err)
+		}
+		if !hasStart {
+			startTime, hasStart = start, true
+		}
+		if !hasBase {
+			// As with the reference implementation timestamps over a year
+			// prior to the start time are assumed to be relative.
+			if start < startTime-365*24*3600 {
+				baseTime = startTime
+			}
+			hasBase = true
+		}
+		log.Intervals = append(log.Intervals, Interval{
+			Tag:       tag,
+			Start:     seconds(baseTime + start),
+			Length:    time.Duration(length * float64(time.Second)),
+			Histogram: h,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	log.Start = seconds(startTime)
+	return log, nil
+}
+
+// header parses the seconds since epoch of a #[Name: 123.456 ...] line.
+func header(line, name string) (float64, bool) {
+	rest, ok := strings.CutPrefix(line, "#["+name+": ")
+	if !ok {
+		return 0, false
+	}
+	if i := strings.IndexAny(rest, " ]"); i >= 0 {
+		rest = rest[:i]
+	}
+	v, err := strconv.ParseFloat(rest, 64)
+	return v, err == nil
+}
+
+// seconds converts seconds since epoch into a time.
+func seconds(s float64) time.Time {
+	whole, frac := math.Modf(s)
+	return time.Unix(int64(whole), int64(math.Round(frac*1e3))*int64(time.Millisecond))
+}
+
+// Merge merges the intervals of every log into a single histogram per tag.
+func Merge(logs ...*Log) map[string]*hdrhistogram.Histogram {
+	merged := make(map[string]*hdrhistogram.Histogram)
+	for _, log := range logs {
+		for _, i := range log.Intervals {
+			h, ok := merged[i.Tag]
+			if !ok {
+				h = NewHistogram()
+				merged[i.Tag] = h
+			}
+			h.Merge(i.Histogram)
+		}
+	}
+	return merged
+}
+
+// WriteMerged writes the intervals of every log to w as a single interval
+// log, ordered by their start time and relative to the earliest start.
+func WriteMerged(w io.Writer, logs ...*Log) error {
+	var (
+		intervals []Interval
+		start     time.Time
+	)
+	for _, log := range logs {
+		if start.IsZero() || log.Start.Before(start) {
+			start = log.Start
+		}
+		intervals = append(intervals, log.Intervals...)
+	}
+	slices.SortStableFunc(intervals, func(a, b Interval) int {
+		return a.Start.Compare(b.Start)
+	})
+	out := NewWriter(w, start)
+	if err := out.WriteHeader(); err != nil {
+		return err
+	}
+	for _, i := range intervals {
+		if err := out.WriteInterval(i.Tag, i.Start.Sub(start), i.Length, i.Histogram); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,179 @@
+// Package hdrlog reads and writes latency histograms in the HdrHistogram
+// interval log format, allowing vessel output to be analysed by existing
+// tooling (such as HistogramLogAnalyzer) and runs to be merged losslessly.
+//
+// Values are recorded in microseconds, the untagged histograms hold the
+// latency of successful requests and the tagged histograms the time spent
+// in each phase of the request lifecycle.
+package hdrlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+// Tags of the phase histograms.  The latency histogram is untagged.
+const (
+	Latency   = ""
+	DNS       = "dns"
+	Connect   = "connect"
+	TLS       = "tls"
+	GetConn   = "get_conn"
+	FirstByte = "ttfb"
+)
+
+// Tags holds every tag written, in the order they are written.
+var Tags = []string{Latency, DNS, Connect, TLS, GetConn, FirstByte}
+
+// FormatVersion is the version of the log format written.
+const FormatVersion = "1.3"
+
+// unitRatio scales the microsecond values to the milliseconds reported
+// in the Interval_Max column.
+const unitRatio = 1000
+
+// NewHistogram returns a histogram suitable for recording latencies in
+// microseconds, upto a maximum of one minute.
+func NewHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, 60_000_000, 3)
+}
+
+// Sink records the latency and phase timings of every request into
+// interval histograms written to an interval log as each interval closes.
+type Sink struct {
+	collector.NopSink
+	f          *os.File
+	w          *Writer
+	start      time.Time
+	width      time.Duration
+	index      int64
+	histograms map[string]*hdrhistogram.Histogram
+	err        error
+}
+
+// NewSink creates (or truncates) the file at path and returns a ptr to
+// the Sink writing intervals of the given width, measured from start.
+func NewSink(path string, start time.Time, width time.Duration) (*Sink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		f:          f,
+		w:          NewWriter(f, start),
+		start:      start,
+		width:      width,
+		histograms: make(map[string]*hdrhistogram.Histogram, len(Tags)),
+	}
+	for _, tag := range Tags {
+		s.histograms[tag] = NewHistogram()
+	}
+	s.err = s.w.WriteHeader()
+	return s, s.err
+}
+
+// OnResult implements collector.Sink.
+func (s *Sink) OnResult(stat *stats.Stats) {
+	if s.err != nil {
+		return
+	}
+	// Results are bucketed by their completion time, a result completing
+	// within an interval already written is accounted to the current one.
+	index := int64(stat.Began.Add(stat.Latency).Sub(s.start) / s.width)
+	for s.index < index && s.err == nil {
+		s.flush()
+	}
+	if stat.Err != nil {
+		return
+	}
+	_ = s.histograms[Latency].RecordValue(stat.Latency.Microseconds())
+	record := func(tag string, d time.Duration) {
+		if d > 0 {
+			_ = s.histograms[tag].RecordValue(d.Microseconds())
+		}
+	}
+	record(DNS, stat.TimeOnDns)
+	record(Connect, stat.TimeOnConnect)
+	record(TLS, stat.TimeOnTls)
+	record(FirstByte, stat.TimeToFirstByte)
+	// Getting a connection is always recorded, a zero value is meaningful
+	// as it indicates an idle connection was immediately available.
+	_ = s.histograms[GetConn].RecordValue(stat.TimeOnConn.Microseconds())
+}
+
+// OnFinish implements collector.Sink, writing the final (partial) interval
+// and closing the file.
+func (s *Sink) OnFinish(*collector.Result) error {
+	if s.err == nil && s.histograms[Latency].TotalCount() > 0 {
+		s.flush()
+	}
+	if err := s.f.Close(); s.err == nil {
+		s.err = err
+	}
+	return s.err
+}
+
+// flush writes the histograms of the current interval and begins the next.
+func (s *Sink) flush() {
+	offset := time.Duration(s.index) * s.width
+	for _, tag := range Tags {
+		h := s.histograms[tag]
+		if tag != Latency && h.TotalCount() == 0 {
+			continue
+		}
+		if s.err = s.w.WriteInterval(tag, offset, s.width, h); s.err != nil {
+			return
+		}
+		h.Reset()
+	}
+	s.index++
+	s.err = s.w.Flush()
+}
+
+// Writer writes histograms in the interval log format.
+type Writer struct {
+	w     *bufio.Writer
+	start time.Time
+}
+
+// NewWriter returns a Writer whose interval offsets are relative to start.
+func NewWriter(w io.Writer, start time.Time) *Writer {
+	return &Writer{w: bufio.NewWriter(w), start: start}
+}
+
+// WriteHeader writes the format version, start time and legend.
+func (w *Writer) WriteHeader() error {
+	ms := w.start.UnixMilli()
+	fmt.Fprintf(w.w, "#[Histogram log format version %s]\n", FormatVersion)
+	fmt.Fprintln(w.w, "#[Written by vessel, values in microseconds]")
+	fmt.Fprintf(w.w, "#[StartTime: %d.%03d (seconds since epoch), %s]\n", ms/1000, ms%1000, w.start.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w.w, "#[BaseTime: %d.%03d (seconds since epoch)]\n", ms/1000, ms%1000)
+	fmt.Fprintln(w.w, `"StartTimestamp","Interval_Length","Interval_Max","Interval_Compressed_Histogram"`)
+	return w.w.Flush()
+}
+
+// WriteInterval writes a single interval histogram beginning offset after
+// the start time.  The tag may be empty.
+func (w *Writer) WriteInterval(tag string, offset, length time.Duration, h *hdrhistogram.Histogram) error {
+	encoded, err := h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	if err != nil {
+		return fmt.Errorf("unable to encode histogram: %w", err)
+	}
+	if tag != "" {
+		fmt.Fprintf(w.w, "Tag=%s,", tag)
+	}
+	_, err = fmt.Fprintf(w.w, "%.3f,%.3f,%.3f,%s\n", offset.Seconds(), length.Seconds(), float64(h.Max())/unitRatio, encoded)
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,103 @@
+package hook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CorrelationID sets a unique id in the given header on every request so
+// that individual requests can be located in server side logs.  Ids take
+// the form <run>-<worker>-<iteration>.
+func CorrelationID(header string) RequestHook {
+	run := strconv.FormatUint(rand.Uint64(), 36)
+	return RequestHookFunc(func(request *http.Request, info Info) error {
+		request.Header.Set(header, run+"-"+strconv.Itoa(info.Worker)+"-"+strconv.FormatInt(info.Iteration, 10))
+		return nil
+	})
+}
+
+// BearerFile sets the Authorization header from a bearer token held in a
+// file.  The file is re-read whenever it changes (checked at most every
+// second) so that rotating tokens are picked up part way through a run.
+func BearerFile(path string) (RequestHook, error) {
+	b := &bearerFile{path: path}
+	if err := b.reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// bearerFile is the RequestHook returned by BearerFile.
+type bearerFile struct {
+	path     string
+	mu       sync.RWMutex
+	token    string
+	modified time.Time
+	checked  time.Time
+}
+
+// BeforeRequest implements RequestHook.
+func (b *bearerFile) BeforeRequest(request *http.Request, _ Info) error {
+	b.mu.RLock()
+	stale := time.Since(b.checked) > time.Second
+	token := b.token
+	b.mu.RUnlock()
+	if stale {
+		if err := b.reload(); err != nil {
+			return err
+		}
+		b.mu.RLock()
+		token = b.token
+		b.mu.RUnlock()
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// reload re-reads the token if the file has been modified.
+func (b *bearerFile) reload() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checked = time.Now()
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return fmt.Errorf("unable to read bearer token: %w", err)
+	}
+	if !info.ModTime().After(b.modified) {
+		return nil
+	}
+	raw, err := os.ReadFile(b.path)
+	if err != nil {
+		return fmt.Errorf("unable to read bearer token: %w", err)
+	}
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		return errors.New("bearer token file is empty")
+	}
+	b.token, b.modified = token, info.ModTime()
+	return nil
+}
+
+// HMAC signs every request with HMAC-SHA256, keyed by secret, over the
+// method, request uri and unix timestamp (newline separated).  The
+// signature is set in the given header as t=<timestamp>,v1=<hex digest>.
+func HMAC(header, secret string) RequestHook {
+	key := []byte(secret)
+	return RequestHookFunc(func(request *http.Request, _ Info) error {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(request.Method + "\n" + request.URL.RequestURI() + "\n" + ts))
+		request.Header.Set(header, "t="+ts+",v1="+hex.EncodeToString(mac.Sum(nil)))
+		return nil
+	})
+}
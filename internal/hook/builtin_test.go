@@ -0,0 +1,61 @@
+package hook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelationID(t *testing.T) {
+	h := CorrelationID("X-Request-ID")
+	a := httptest.NewRequest(http.MethodGet, "/", nil)
+	b := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, h.BeforeRequest(a, Info{Worker: 3, Iteration: 7}))
+	require.NoError(t, h.BeforeRequest(b, Info{Worker: 3, Iteration: 8}))
+	assert.True(t, strings.HasSuffix(a.Header.Get("X-Request-ID"), "-3-7"))
+	assert.True(t, strings.HasSuffix(b.Header.Get("X-Request-ID"), "-3-8"))
+}
+
+func TestBearerFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+	h, err := BearerFile(path)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, h.BeforeRequest(r, Info{}))
+	assert.Equal(t, "Bearer first", r.Header.Get("Authorization"))
+
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+	h.(*bearerFile).checked = time.Time{}
+	require.NoError(t, h.BeforeRequest(r, Info{}))
+	assert.Equal(t, "Bearer second", r.Header.Get("Authorization"))
+}
+
+func TestBearerFileMissing(t *testing.T) {
+	_, err := BearerFile(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorContains(t, err, "unable to read bearer token")
+}
+
+func TestHMACSignature(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "http://localhost/foo?bar=1", nil)
+	require.NoError(t, HMAC("X-Signature", "secret").BeforeRequest(r, Info{}))
+	sig := r.Header.Get("X-Signature")
+	ts, digest, ok := strings.Cut(strings.TrimPrefix(sig, "t="), ",v1=")
+	require.True(t, ok)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("POST\n/foo?bar=1\n" + ts))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), digest)
+}
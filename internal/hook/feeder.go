@@ -0,0 +1,84 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Feeder gives every worker, acting as a virtual user, its own row of data
+// from a CSV file whose first row names the columns.  Placeholders of the
+// form ${column} in the URL path, query, header values and body of each
+// request are replaced with the worker's value of that column.  Workers
+// are assigned rows in turn, wrapping around if there are more workers
+// than rows.
+func Feeder(path string) (RequestHook, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read feeder %s: %w", path, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("feeder %s must have a header row and at least one row of data", path)
+	}
+	header := records[0]
+	users := make([]*strings.Replacer, 0, len(records)-1)
+	queries := make([]*strings.Replacer, 0, len(records)-1)
+	for _, record := range records[1:] {
+		plain := make([]string, 0, 2*len(header))
+		escaped := make([]string, 0, 2*len(header))
+		for i, column := range header {
+			placeholder := "${" + column + "}"
+			plain = append(plain, placeholder, record[i])
+			escaped = append(escaped, placeholder, url.QueryEscape(record[i]))
+		}
+		users = append(users, strings.NewReplacer(plain...))
+		queries = append(queries, strings.NewReplacer(escaped...))
+	}
+	return &feeder{users: users, queries: queries}, nil
+}
+
+// feeder is the RequestHook returned by Feeder.
+type feeder struct {
+	users   []*strings.Replacer
+	queries []*strings.Replacer
+}
+
+// BeforeRequest implements RequestHook.
+func (f *feeder) BeforeRequest(request *http.Request, info Info) error {
+	user := f.users[info.Worker%len(f.users)]
+	query := f.queries[info.Worker%len(f.queries)]
+
+	request.URL.Path = user.Replace(request.URL.Path)
+	request.URL.RawPath = ""
+	request.URL.RawQuery = query.Replace(request.URL.RawQuery)
+	for _, values := range request.Header {
+		for i, v := range values {
+			values[i] = user.Replace(v)
+		}
+	}
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil
+	}
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+	_ = request.Body.Close()
+	replaced := []byte(user.Replace(string(body)))
+	request.Body = io.NopCloser(bytes.NewReader(replaced))
+	request.ContentLength = int64(len(replaced))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(replaced)), nil
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package hook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,name,token\n1,ann smith,abc\n2,bob&co,def\n"), 0o600))
+	h, err := Feeder(path)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		worker int
+		path   string
+		query  string
+		auth   string
+	}{
+		"first user":          {worker: 0, path: "/users/1", query: "name=ann+smith&x=1", auth: "Bearer abc"},
+		"second user":         {worker: 1, path: "/users/2", query: "name=bob%26co&x=1", auth: "Bearer def"},
+		"users wrap around":   {worker: 2, path: "/users/1", query: "name=ann+smith&x=1", auth: "Bearer abc"},
+		"later iteration too": {worker: 3, path: "/users/2", query: "name=bob%26co&x=1", auth: "Bearer def"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://localhost/users/${id}?name=${name}&x=1", nil)
+			r.Header.Set("Authorization", "Bearer ${token}")
+			require.NoError(t, h.BeforeRequest(r, Info{Worker: tc.worker}))
+			assert.Equal(t, tc.path, r.URL.Path)
+			assert.Equal(t, tc.query, r.URL.RawQuery)
+			assert.Equal(t, tc.auth, r.Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestFeederReplacesBody(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,name\n1,ann\n"), 0o600))
+	h, err := Feeder(path)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "http://localhost/users", strings.NewReader(`{"id": ${id}, "name": "${name}"}`))
+	require.NoError(t, h.BeforeRequest(r, Info{}))
+	want := `{"id": 1, "name": "ann"}`
+	assert.Equal(t, int64(len(want)), r.ContentLength)
+	body, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(body))
+	replay, err := r.GetBody()
+	require.NoError(t, err)
+	body, err = io.ReadAll(replay)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(body))
+}
+
+func TestFeederRequiresData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,name\n"), 0o600))
+	_, err := Feeder(path)
+	assert.ErrorContains(t, err, "at least one row")
+
+	_, err = Feeder(filepath.Join(t.TempDir(), "missing.csv"))
+	assert.Error(t, err)
+}
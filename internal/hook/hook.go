@@ -0,0 +1,47 @@
+package hook
+
+import (
+	"net/http"
+)
+
+// Info describes the request a hook is invoked for.
+type Info struct {
+	// Worker is the id of the worker dispatching the request.
+	Worker int
+	// Iteration is the number of requests the worker has dispatched
+	// prior to this one.
+	Iteration int64
+}
+
+// RequestHook mutates a request right before it is dispatched, for example
+// to sign it or inject credentials.  Hooks run in the order registered on
+// the worker's goroutine with a private clone of the request.
+//
+// Returning an error aborts the request which is reported as failed.
+type RequestHook interface {
+	BeforeRequest(request *http.Request, info Info) error
+}
+
+// ResponseHook inspects a response once its body has been read in full,
+// for example to apply custom validation.  The body must not be retained.
+//
+// Returning an error reports the request as failed.
+type ResponseHook interface {
+	AfterResponse(response *http.Response, body []byte, info Info) error
+}
+
+// RequestHookFunc is an adapter to allow ordinary functions as RequestHooks.
+type RequestHookFunc func(request *http.Request, info Info) error
+
+// BeforeRequest implements RequestHook.
+func (f RequestHookFunc) BeforeRequest(request *http.Request, info Info) error {
+	return f(request, info)
+}
+
+// ResponseHookFunc is an adapter to allow ordinary functions as ResponseHooks.
+type ResponseHookFunc func(response *http.Response, body []byte, info Info) error
+
+// AfterResponse implements ResponseHook.
+func (f ResponseHookFunc) AfterResponse(response *http.Response, body []byte, info Info) error {
+	return f(response, body, info)
+}
@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds (in seconds) of the latency
+// histogram buckets.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// phases of the request lifecycle captured by httptrace.
+const (
+	phaseDNS     = "dns"
+	phaseConnect = "connect"
+	phaseTLS     = "tls"
+	phaseGetConn = "get_conn"
+)
+
+// Exporter exposes the results observed throughout a run in the Prometheus
+// text exposition format.  It implements collector.Sink so it can be fed
+// from the EventCollector and http.Handler so it can be scraped.
+//
+// Exporter is synchronised internally and is safe for parallel use.
+type Exporter struct {
+	collector.NopSink
+	mu            sync.Mutex
+	version       string
+	requests      map[int]int64
+	errors        map[collector.ErrorType]int64
+	bytesReceived int64
+	bytesSent     int64
+	latency       *histogram
+	phases        map[string]*histogram
+}
+
+// NewExporter instantiates a new Exporter and returns a ptr to it.
+func NewExporter(version string) *Exporter {
+	return &Exporter{
+		version:  version,
+		requests: make(map[int]int64),
+		errors:   make(map[collector.ErrorType]int64),
+		latency:  newHistogram(DefaultBuckets),
+		phases: map[string]*histogram{
+			phaseDNS:     newHistogram(DefaultBuckets),
+			phaseConnect: newHistogram(DefaultBuckets),
+			phaseTLS:     newHistogram(DefaultBuckets),
+			phaseGetConn: newHistogram(DefaultBuckets),
+		},
+	}
+}
+
+// OnResult implements collector.Sink.
+func (e *Exporter) OnResult(stat *stats.Stats) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if stat.Err != nil {
+		e.errors[collector.Classify(stat.Err)]++
+		return
+	}
+	e.requests[stat.StatusCode]++
+	e.bytesReceived += stat.BytesReceived
+	e.bytesSent += stat.BytesSent
+	e.latency.observe(stat.Latency)
+	e.phases[phaseGetConn].observe(stat.TimeOnConn)
+	// reused connections skip these phases entirely, observing them as
+	// zero would drag the distributions down.
+	for phase, d := range map[string]time.Duration{
+		phaseDNS:     stat.TimeOnDns,
+		phaseConnect: stat.TimeOnConnect,
+		phaseTLS:     stat.TimeOnTls,
+	} {
+		if d > 0 {
+			e.phases[phase].observe(d)
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler and writes the current metrics.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	bw := bufio.NewWriter(w)
+	e.write(bw)
+	_ = bw.Flush()
+}
+
+// write renders all metrics in the text exposition format.
+func (e *Exporter) write(w *bufio.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	header(w, "vessel_build_info", "gauge", "A metric with a constant '1' value labelled by the vessel version.")
+	fmt.Fprintf(w, "vessel_build_info{version=%q} 1\n", e.version)
+
+	header(w, "vessel_requests_total", "counter", "Total responses received, by status code.")
+	codes := make([]int, 0, len(e.requests))
+	for code := range e.requests {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "vessel_requests_total{status=\"%d\"} %d\n", code, e.requests[code])
+	}
+
+	header(w, "vessel_errors_total", "counter", "Total requests which failed without a response, by error type.")
+	types := make([]string, 0, len(e.errors))
+	for t := range e.errors {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(w, "vessel_errors_total{type=%q} %d\n", t, e.errors[t])
+	}
+
+	header(w, "vessel_received_bytes_total", "counter", "Total response body bytes received.")
+	fmt.Fprintf(w, "vessel_received_bytes_total %d\n", e.bytesReceived)
+	header(w, "vessel_sent_bytes_total", "counter", "Total request bytes sent.")
+	fmt.Fprintf(w, "vessel_sent_bytes_total %d\n", e.bytesSent)
+
+	header(w, "vessel_request_duration_seconds", "histogram", "Request latency, including reading the response body.")
+	e.latency.write(w, "vessel_request_duration_seconds", "")
+
+	header(w, "vessel_phase_duration_seconds", "histogram", "Time spent in each phase of the request lifecycle.")
+	for _, phase := range []string{phaseDNS, phaseConnect, phaseTLS, phaseGetConn} {
+		e.phases[phase].write(w, "vessel_phase_duration_seconds", fmt.Sprintf("phase=%q", phase))
+	}
+}
+
+// header writes the HELP and TYPE lines of a metric family.
+func header(w *bufio.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// histogram is a cumulative Prometheus histogram.
+type histogram struct {
+	bounds []float64
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)),
+	}
+}
+
+// observe records a duration.
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	h.count++
+	h.sum += v
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+}
+
+// write renders the histogram series with the given (preformatted) labels.
+func (h *histogram) write(w *bufio.Writer, name, labels string) {
+	join := func(extra string) string {
+		all := make([]string, 0, 2)
+		if labels != "" {
+			all = append(all, labels)
+		}
+		if extra != "" {
+			all = append(all, extra)
+		}
+		if len(all) == 0 {
+			return ""
+		}
+		return "{" + strings.Join(all, ",") + "}"
+	}
+	for i, bound := range h.bounds {
+		le := strconv.FormatFloat(bound, 'g', -1, 64)
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, join(fmt.Sprintf("le=%q", le)), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", name, join(`le="+Inf"`), h.count)
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, join(""), strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, join(""), h.count)
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+func TestExporterWritesTextFormat(t *testing.T) {
+	e := NewExporter("v1.2.3")
+	e.OnResult(&stats.Stats{StatusCode: 200, Latency: 3 * time.Millisecond, TimeOnDns: time.Millisecond, BytesReceived: 10})
+	e.OnResult(&stats.Stats{StatusCode: 200, Latency: 300 * time.Millisecond, BytesReceived: 5})
+	e.OnResult(&stats.Stats{StatusCode: 503, Latency: 2 * time.Second})
+	e.OnResult(&stats.Stats{Err: context.DeadlineExceeded})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, body, `vessel_build_info{version="v1.2.3"} 1`)
+	assert.Contains(t, body, `vessel_requests_total{status="200"} 2`)
+	assert.Contains(t, body, `vessel_requests_total{status="503"} 1`)
+	assert.Contains(t, body, `vessel_errors_total{type="Timeout"} 1`)
+	assert.Contains(t, body, "vessel_received_bytes_total 15")
+	assert.Contains(t, body, `vessel_request_duration_seconds_bucket{le="0.005"} 1`)
+	assert.Contains(t, body, `vessel_request_duration_seconds_bucket{le="0.5"} 2`)
+	assert.Contains(t, body, `vessel_request_duration_seconds_bucket{le="+Inf"} 3`)
+	assert.Contains(t, body, "vessel_request_duration_seconds_count 3")
+	assert.Contains(t, body, `vessel_phase_duration_seconds_count{phase="dns"} 1`)
+	assert.Contains(t, body, `vessel_phase_duration_seconds_count{phase="get_conn"} 3`)
+}
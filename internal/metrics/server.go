@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Server serves the /metrics endpoint for the lifetime of a run.
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+}
+
+// Serve starts serving the handler on /metrics at the given address in
+// the background.  The caller is responsible for calling Shutdown.
+func Serve(addr string, handler http.Handler) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	s := &Server{
+		srv:      &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		listener: l,
+	}
+	go func() {
+		// Serve always returns a non nil error, ErrServerClosed after Shutdown.
+		_ = s.srv.Serve(l)
+	}()
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Shutdown gracefully stops the server, allowing in flight scrapes to
+// finish.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
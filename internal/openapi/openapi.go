@@ -0,0 +1,531 @@
+// Package openapi derives load from an OpenAPI 3 specification, enumerating
+// its operations and synthesising a valid request for each from the
+// examples and schemas it documents.  Responses can be validated against
+// the documented response schemas.
+//
+// Specifications may be written in JSON or YAML.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/symonk/vessel/internal/check"
+	"github.com/symonk/vessel/internal/scenario"
+	"gopkg.in/yaml.v3"
+)
+
+// methods are the operations of a path item, in the order they are listed.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Spec is a parsed OpenAPI 3 specification.
+type Spec struct {
+	root map[string]any
+}
+
+// ReadFile reads an OpenAPI 3 specification in JSON.
+func ReadFile(path string) (*Spec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse openapi specification %s: %w", path, err)
+	}
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("%s is not an OpenAPI 3 specification", path)
+	}
+	return &Spec{root: root}, nil
+}
+
+// decode decodes a JSON or YAML specification.  YAML is decoded into the
+// same types as JSON: mappings keyed by strings (so a status code written
+// as 200 is the key "200"), numbers as float64 and dates left as strings.
+func decode(b []byte) (map[string]any, error) {
+	var root map[string]any
+	if json.Valid(b) {
+		err := json.Unmarshal(b, &root)
+		return root, err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(b, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, errors.New("the specification is empty")
+	}
+	v, err := fromYAML(document.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	root, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("the specification is not a mapping")
+	}
+	return root, nil
+}
+
+// fromYAML converts a YAML node into the value JSON would decode it as.
+func fromYAML(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return fromYAML(node.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := fromYAML(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[node.Content[i].Value] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		s := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			v, err := fromYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		return s, nil
+	}
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err := node.Decode(&b)
+		return b, err
+	case "!!int", "!!float":
+		var f float64
+		err := node.Decode(&f)
+		return f, err
+	}
+	return node.Value, nil
+}
+
+// Operation is a single documented operation.
+type Operation struct {
+	// ID is the operationId, or the method and path if there is none.
+	ID     string
+	Method string
+	// Path is the templated path, such as /pets/{id}.
+	Path string
+	// Request is the request synthesised for the operation.
+	Request scenario.Request
+	// responses holds the documented response of each status code (or
+	// range such as 2XX, or default).
+	responses map[string]any
+}
+
+// Operations returns the operations of the specification, ordered by path
+// and then method, with a request synthesised for each against the base
+// url.  Each request has a weight of one.
+func (s *Spec) Operations(base string) ([]*Operation, error) {
+	paths, _ := s.root["paths"].(map[string]any)
+	var operations []*Operation
+	for _, path := range keys(paths) {
+		item, _ := s.deref(paths[path]).(map[string]any)
+		shared, _ := item["parameters"].([]any)
+		for _, method := range methods {
+			node, ok := item[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			op := &Operation{Method: strings.ToUpper(method), Path: path}
+			op.ID, _ = node["operationId"].(string)
+			if op.ID == "" {
+				op.ID = op.Method + " " + path
+			}
+			op.responses, _ = node["responses"].(map[string]any)
+			parameters, _ := node["parameters"].([]any)
+			request, err := s.synthesise(base, op, append(slices.Clone(shared), parameters...), node["requestBody"])
+			if err != nil {
+				return nil, fmt.Errorf("operation %s: %w", op.ID, err)
+			}
+			request.ID = op.ID
+			op.Request = request
+			operations = append(operations, op)
+		}
+	}
+	if len(operations) == 0 {
+		return nil, errors.New("the specification documents no operations")
+	}
+	return operations, nil
+}
+
+// synthesise builds the request for the operation.  Path parameters and
+// required (or exemplified) query and header parameters are given values,
+// operation level parameters overriding those of the path.
+func (s *Spec) synthesise(base string, op *Operation, parameters []any, body any) (scenario.Request, error) {
+	request := scenario.Request{Method: op.Method, Header: make(http.Header), Weight: 1}
+	path := op.Path
+	query := url.Values{}
+	seen := make(map[string]bool)
+	for i := len(parameters) - 1; i >= 0; i-- {
+		p, _ := s.deref(parameters[i]).(map[string]any)
+		name, _ := p["name"].(string)
+		in, _ := p["in"].(string)
+		if seen[in+":"+name] {
+			continue
+		}
+		seen[in+":"+name] = true
+		required, _ := p["required"].(bool)
+		value, exemplified := s.example(p)
+		if !exemplified {
+			value = s.value(p["schema"], 0)
+		}
+		switch in {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(format(value)))
+		case "query":
+			if required || exemplified {
+				query.Set(name, format(value))
+			}
+		case "header":
+			if required || exemplified {
+				request.Header.Set(name, format(value))
+			}
+		}
+	}
+	if strings.Contains(path, "{") {
+		return request, fmt.Errorf("path %s has undocumented parameters", op.Path)
+	}
+	request.URL = strings.TrimSuffix(base, "/") + path
+	if len(query) > 0 {
+		request.URL += "?" + query.Encode()
+	}
+	if _, err := http.NewRequest(request.Method, request.URL, nil); err != nil {
+		return request, err
+	}
+
+	if rb, ok := s.deref(body).(map[string]any); ok {
+		content, _ := rb["content"].(map[string]any)
+		for _, mediaType := range keys(content) {
+			media, _ := content[mediaType].(map[string]any)
+			if !isJSON(mediaType) {
+				continue
+			}
+			value, ok := s.example(media)
+			if !ok {
+				value = s.value(media["schema"], 0)
+			}
+			b, err := json.Marshal(value)
+			if err != nil {
+				return request, err
+			}
+			request.Body = b
+			request.Header.Set("Content-Type", mediaType)
+			break
+		}
+	}
+	return request, nil
+}
+
+// example returns the example of a parameter or media type, if it has
+// one, either directly or as the first of its examples.
+func (s *Spec) example(node map[string]any) (any, bool) {
+	if v, ok := node["example"]; ok {
+		return v, true
+	}
+	if examples, ok := node["examples"].(map[string]any); ok {
+		for _, name := range keys(examples) {
+			if e, ok := s.deref(examples[name]).(map[string]any); ok {
+				if v, ok := e["value"]; ok {
+					return v, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// maxDepth bounds the synthesis of recursive schemas.
+const maxDepth = 8
+
+// value synthesises a value satisfying the schema, preferring its example,
+// default or first enumerated value.
+func (s *Spec) value(node any, depth int) any {
+	schema, _ := s.deref(node).(map[string]any)
+	if schema == nil || depth > maxDepth {
+		return nil
+	}
+	for _, keyword := range []string{"example", "default", "const"} {
+		if v, ok := schema[keyword]; ok {
+			return v
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if all, ok := schema["allOf"].([]any); ok {
+		merged := make(map[string]any)
+		for _, sub := range all {
+			if object, ok := s.value(sub, depth+1).(map[string]any); ok {
+				for k, v := range object {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		if choices, ok := schema[keyword].([]any); ok && len(choices) > 0 {
+			return s.value(choices[0], depth+1)
+		}
+	}
+
+	kind, _ := schema["type"].(string)
+	if types, ok := schema["type"].([]any); ok && len(types) > 0 {
+		kind, _ = types[0].(string)
+	}
+	if kind == "" && schema["properties"] != nil {
+		kind = "object"
+	}
+	switch kind {
+	case "object":
+		object := make(map[string]any)
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if n, ok := name.(string); ok {
+				object[n] = s.value(properties[n], depth+1)
+			}
+		}
+		// Optional properties are only sent when exemplified.
+		for name, property := range properties {
+			if _, ok := object[name]; ok {
+				continue
+			}
+			if p, ok := s.deref(property).(map[string]any); ok && p["example"] != nil {
+				object[name] = p["example"]
+			}
+		}
+		return object
+	case "array":
+		items := int(number(schema, "minItems", 1))
+		array := make([]any, max(1, items))
+		for i := range array {
+			array[i] = s.value(schema["items"], depth+1)
+		}
+		return array
+	case "integer":
+		return int64(number(schema, "minimum", 1))
+	case "number":
+		return number(schema, "minimum", 1)
+	case "boolean":
+		return true
+	case "string":
+		return str(schema)
+	}
+	return nil
+}
+
+// str synthesises a string of the schema's format and length.
+func str(schema map[string]any) string {
+	format, _ := schema["format"].(string)
+	switch format {
+	case "uuid":
+		return "00000000-0000-4000-8000-000000000000"
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "email":
+		return "vessel@example.com"
+	case "uri", "url":
+		return "https://example.com"
+	case "ipv4":
+		return "127.0.0.1"
+	}
+	v := "vessel"
+	if n := int(number(schema, "minLength", 0)); n > len(v) {
+		v += strings.Repeat("x", n-len(v))
+	}
+	if n := int(number(schema, "maxLength", float64(len(v)))); n < len(v) {
+		v = v[:n]
+	}
+	return v
+}
+
+// number returns the numeric keyword of the schema, or the fallback.
+func number(schema map[string]any, keyword string, fallback float64) float64 {
+	if v, ok := schema[keyword].(float64); ok {
+		return v
+	}
+	return fallback
+}
+
+// format renders a synthesised value as a parameter.
+func format(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// keys returns the keys of the object in order.
+func keys(object map[string]any) []string {
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isJSON reports whether the media type is JSON.
+func isJSON(mediaType string) bool {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// deref follows a local $ref of the node, if it is one.
+func (s *Spec) deref(node any) any {
+	for range maxDepth {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return node
+		}
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return node
+		}
+		node = s.pointer(ref)
+	}
+	return nil
+}
+
+// pointer resolves a local JSON pointer such as #/components/schemas/Pet.
+func (s *Spec) pointer(ref string) any {
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil
+	}
+	var node any = s.root
+	for _, token := range strings.Split(pointer, "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+		node = object[token]
+	}
+	return node
+}
+
+// Weigh sets the weight of the operations of each id=weight.
+func Weigh(operations []*Operation, weights []string) error {
+	for _, w := range weights {
+		id, value, ok := strings.Cut(w, "=")
+		weight, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil || weight < 0 {
+			return fmt.Errorf("weight %q must be operation=weight", w)
+		}
+		i := slices.IndexFunc(operations, func(op *Operation) bool { return op.ID == id })
+		if i < 0 {
+			return fmt.Errorf("weight %q names an unknown operation", w)
+		}
+		operations[i].Request.Weight = weight
+	}
+	return nil
+}
+
+// Select returns the operations with the given ids, or every operation if
+// none are given.
+func Select(operations []*Operation, ids []string) ([]*Operation, error) {
+	if len(ids) == 0 {
+		return operations, nil
+	}
+	var selected []*Operation
+	for _, id := range ids {
+		i := slices.IndexFunc(operations, func(op *Operation) bool { return op.ID == id })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown operation %q", id)
+		}
+		selected = append(selected, operations[i])
+	}
+	return selected, nil
+}
+
+// Validator is a check validating each response against the schema its
+// operation documents for the status code.  Responses with a status code
+// the operation does not document fail, as do JSON bodies which do not
+// validate.
+type Validator struct {
+	spec       *Spec
+	schema     *check.JSONSchema // The specification, its patterns compiled once.
+	operations map[string]*Operation
+}
+
+// NewValidator returns a Validator of the responses to the operations,
+// identified by the ID their synthesised request carries.  Responses to
+// requests of no known operation fail.
+func (s *Spec) NewValidator(operations []*Operation) *Validator {
+	v := &Validator{spec: s, schema: check.NewSchema(s.root, s.root), operations: make(map[string]*Operation, len(operations))}
+	for _, op := range operations {
+		v.operations[op.ID] = op
+	}
+	return v
+}
+
+// Name implements check.Check.
+func (v *Validator) Name() string { return "openapi" }
+
+// Check implements check.Check.
+func (v *Validator) Check(response *http.Response, body []byte) error {
+	// A redirect followed elsewhere carries the ID of the original request.
+	id, _ := scenario.ID(response.Request.Context())
+	op, ok := v.operations[id]
+	if !ok {
+		return fmt.Errorf("%s %s is not a request of a known operation", response.Request.Method, response.Request.URL)
+	}
+	documented := v.response(op, response.StatusCode)
+	if documented == nil {
+		return fmt.Errorf("%s responded %d which is not documented", op.ID, response.StatusCode)
+	}
+	content, _ := documented["content"].(map[string]any)
+	for _, mediaType := range keys(content) {
+		media, _ := content[mediaType].(map[string]any)
+		if !isJSON(mediaType) || media["schema"] == nil {
+			continue
+		}
+		var decoded any
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			return fmt.Errorf("%s responded with invalid JSON: %w", op.ID, err)
+		}
+		if err := v.schema.Sub(media["schema"]).Validate(decoded); err != nil {
+			return fmt.Errorf("%s: %w", op.ID, err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// response returns the documented response of the status code, falling
+// back to its range (e.g 2XX) and then the default.
+func (v *Validator) response(op *Operation, status int) map[string]any {
+	code := fmt.Sprint(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if r, ok := v.spec.deref(op.responses[key]).(map[string]any); ok {
+			return r
+		}
+	}
+	return nil
+}
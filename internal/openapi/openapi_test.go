@@ -0,0 +1,247 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const petstore = `{
+  "openapi": "3.0.3",
+  "info": {"title": "petstore", "version": "1"},
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "listPets",
+        "parameters": [
+          {"name": "limit", "in": "query", "required": true, "schema": {"type": "integer", "minimum": 10}},
+          {"name": "sort", "in": "query", "schema": {"type": "string"}},
+          {"name": "tag", "in": "query", "example": "dog", "schema": {"type": "string"}},
+          {"$ref": "#/components/parameters/Tenant"}
+        ],
+        "responses": {
+          "200": {"description": "pets", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "createPet",
+        "requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/NewPet"}}}},
+        "responses": {"2XX": {"description": "created"}}
+      }
+    },
+    "/pets/{petId}": {
+      "parameters": [{"name": "petId", "in": "path", "required": true, "schema": {"type": "string", "format": "uuid"}}],
+      "get": {
+        "operationId": "showPetById",
+        "responses": {"200": {"description": "pet", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}}}
+      },
+      "delete": {
+        "parameters": [{"name": "petId", "in": "path", "required": true, "examples": {"b": {"value": "b"}, "a": {"value": "a b"}}}],
+        "responses": {"204": {"description": "deleted"}}
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "Tenant": {"name": "X-Tenant", "in": "header", "required": true, "schema": {"type": "string", "enum": ["acme", "other"]}}
+    },
+    "responses": {
+      "Error": {"description": "error", "content": {"application/json": {"schema": {"type": "object", "required": ["message"], "properties": {"message": {"type": "string"}}}}}}
+    },
+    "schemas": {
+      "NewPet": {
+        "allOf": [
+          {"type": "object", "required": ["name", "kind"], "properties": {"name": {"type": "string", "minLength": 8}, "kind": {"type": "string", "enum": ["cat", "dog"]}}},
+          {"type": "object", "required": ["born"], "properties": {"born": {"type": "string", "format": "date"}, "tags": {"type": "array", "items": {"type": "string"}, "example": ["friendly"]}, "age": {"type": "integer"}}}
+        ]
+      },
+      "Pet": {"type": "object", "required": ["id", "name"], "properties": {"id": {"type": "integer"}, "name": {"type": "string"}}}
+    }
+  }
+}`
+
+func write(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "spec.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestOperations(t *testing.T) {
+	spec, err := ReadFile(write(t, petstore))
+	require.NoError(t, err)
+	operations, err := spec.Operations("http://localhost:8080/v1/")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		request string
+		header  http.Header
+		body    string
+	}{
+		"listPets": {
+			request: "GET http://localhost:8080/v1/pets?limit=10&tag=dog",
+			header:  http.Header{"X-Tenant": {"acme"}},
+		},
+		"createPet": {
+			request: "POST http://localhost:8080/v1/pets",
+			header:  http.Header{"Content-Type": {"application/json"}},
+			body:    `{"born":"2024-01-01","kind":"cat","name":"vesselxx","tags":["friendly"]}`,
+		},
+		"showPetById": {
+			request: "GET http://localhost:8080/v1/pets/00000000-0000-4000-8000-000000000000",
+			header:  http.Header{},
+		},
+		"DELETE /pets/{petId}": {
+			request: "DELETE http://localhost:8080/v1/pets/a%20b",
+			header:  http.Header{},
+		},
+	}
+	require.Len(t, operations, len(tests))
+	for _, op := range operations {
+		t.Run(op.ID, func(t *testing.T) {
+			tc, ok := tests[op.ID]
+			require.True(t, ok, "unexpected operation %s", op.ID)
+			assert.Equal(t, tc.request, op.Request.Name())
+			assert.Equal(t, tc.header, op.Request.Header)
+			assert.Equal(t, tc.body, string(op.Request.Body))
+			assert.Equal(t, 1.0, op.Request.Weight)
+		})
+	}
+}
+
+func TestReadFileYAML(t *testing.T) {
+	spec, err := ReadFile(write(t, `openapi: 3.0.3
+paths:
+  /pets:
+    post:
+      operationId: createPet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name, born, age]
+              properties:
+                name: &name {type: string, enum: [rex]}
+                born: {type: string, example: 2024-01-01}
+                age: {type: integer, minimum: 3}
+      responses:
+        201:
+          description: created
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  name: *name
+`))
+	require.NoError(t, err)
+	operations, err := spec.Operations("http://localhost")
+	require.NoError(t, err)
+	require.Len(t, operations, 1)
+	assert.Equal(t, "POST http://localhost/pets", operations[0].Request.Name())
+	assert.Equal(t, `{"age":3,"born":"2024-01-01","name":"rex"}`, string(operations[0].Request.Body))
+	assert.Contains(t, operations[0].responses, "201")
+}
+
+func TestReadFileErrors(t *testing.T) {
+	_, err := ReadFile(write(t, "openapi: [3.0.0"))
+	assert.ErrorContains(t, err, "unable to parse openapi specification")
+	_, err = ReadFile(write(t, "- openapi: 3.0.0"))
+	assert.ErrorContains(t, err, "not a mapping")
+	_, err = ReadFile(write(t, `{"swagger": "2.0"}`))
+	assert.ErrorContains(t, err, "not an OpenAPI 3 specification")
+
+	spec, err := ReadFile(write(t, `{"openapi": "3.1.0", "paths": {}}`))
+	require.NoError(t, err)
+	_, err = spec.Operations("http://localhost")
+	assert.ErrorContains(t, err, "no operations")
+
+	spec, err = ReadFile(write(t, `{"openapi": "3.1.0", "paths": {"/a/{b}": {"get": {}}}}`))
+	require.NoError(t, err)
+	_, err = spec.Operations("http://localhost")
+	assert.ErrorContains(t, err, "undocumented parameters")
+}
+
+func TestSelectAndWeigh(t *testing.T) {
+	spec, err := ReadFile(write(t, petstore))
+	require.NoError(t, err)
+	operations, err := spec.Operations("http://localhost")
+	require.NoError(t, err)
+
+	selected, err := Select(operations, []string{"showPetById", "listPets"})
+	require.NoError(t, err)
+	require.Len(t, selected, 2)
+	assert.Equal(t, "showPetById", selected[0].ID)
+	_, err = Select(operations, []string{"nope"})
+	assert.ErrorContains(t, err, "unknown operation")
+
+	require.NoError(t, Weigh(operations, []string{"listPets=10", "DELETE /pets/{petId}=0"}))
+	weights := make(map[string]float64)
+	for _, op := range operations {
+		weights[op.ID] = op.Request.Weight
+	}
+	assert.Equal(t, map[string]float64{"listPets": 10, "createPet": 1, "showPetById": 1, "DELETE /pets/{petId}": 0}, weights)
+	assert.ErrorContains(t, Weigh(operations, []string{"listPets"}), "operation=weight")
+	assert.ErrorContains(t, Weigh(operations, []string{"nope=1"}), "unknown operation")
+}
+
+func TestValidator(t *testing.T) {
+	spec, err := ReadFile(write(t, petstore))
+	require.NoError(t, err)
+	operations, err := spec.Operations("http://localhost")
+	require.NoError(t, err)
+	v := spec.NewValidator(operations)
+	assert.Equal(t, "openapi", v.Name())
+
+	tests := map[string]struct {
+		operation string
+		status    int
+		body      string
+		err       string
+	}{
+		"valid":                   {operation: "listPets", status: 200, body: `[{"id": 1, "name": "rex"}]`},
+		"invalid":                 {operation: "listPets", status: 200, body: `[{"id": "one", "name": "rex"}]`, err: "listPets: $[0].id"},
+		"not json":                {operation: "showPetById", status: 200, body: `<html>`, err: "invalid JSON"},
+		"default response":        {operation: "listPets", status: 500, body: `{"message": "oops"}`},
+		"invalid default":         {operation: "listPets", status: 500, body: `{}`, err: "message"},
+		"status range":            {operation: "createPet", status: 201},
+		"undocumented status":     {operation: "showPetById", status: 404, err: "404 which is not documented"},
+		"documented without body": {operation: "DELETE /pets/{petId}", status: 204},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var op *Operation
+			for _, o := range operations {
+				if o.ID == tc.operation {
+					op = o
+				}
+			}
+			require.NotNil(t, op)
+			request := op.Request.Build(httptest.NewRequest(http.MethodGet, "/", nil))
+			err = v.Check(&http.Response{StatusCode: tc.status, Request: request}, []byte(tc.body))
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	// Operations are identified by the context of their request, which
+	// a redirect elsewhere carries, rather than its url.
+	redirected := operations[0].Request.Build(httptest.NewRequest(http.MethodGet, "/", nil))
+	redirected.URL, _ = url.Parse("http://elsewhere/")
+	assert.ErrorContains(t, v.Check(&http.Response{StatusCode: 200, Request: redirected}, []byte(`{}`)), "listPets: $")
+
+	// Responses to requests which were not synthesised fail.
+	request, err := http.NewRequest(http.MethodGet, operations[0].Request.URL, nil)
+	require.NoError(t, err)
+	assert.ErrorContains(t, v.Check(&http.Response{StatusCode: 200, Request: request}, nil), "not a request of a known operation")
+}
@@ -0,0 +1,248 @@
+// Package protobuf encodes protocol buffer messages described by
+// descriptors loaded at runtime, such as those compiled by protoc into a
+// descriptor set or served by gRPC server reflection, from their JSON
+// form.  Descriptors are built and messages encoded by the protobuf
+// module, this package holds the files as they arrive, in any order, and
+// resolves the methods called.
+package protobuf
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	// The well known types are linked so that files importing them
+	// resolve without the server or descriptor set providing them.
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Method describes a method of a service.
+type Method struct {
+	// Name is the full name of the method, service/method, as in the
+	// path it is called with.
+	Name            string
+	Input           protoreflect.MessageDescriptor
+	Output          protoreflect.MessageDescriptor
+	ClientStreaming bool
+	ServerStreaming bool
+	// types resolves the messages embedded in google.protobuf.Any.
+	types *dynamicpb.Types
+}
+
+// Registry holds the files of a set.  Files may be added in any order,
+// their descriptors are built once a method is resolved.
+type Registry struct {
+	files map[string]*descriptorpb.FileDescriptorProto
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{files: make(map[string]*descriptorpb.FileDescriptorProto)}
+}
+
+// ReadDescriptorSet reads a FileDescriptorSet, as written by protoc with
+// --descriptor_set_out (and --include_imports), into a registry.
+func ReadDescriptorSet(path string) (*Registry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := new(descriptorpb.FileDescriptorSet)
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("unable to read descriptor set %s: %w", path, err)
+	}
+	r := NewRegistry()
+	for _, file := range set.File {
+		r.files[file.GetName()] = file
+	}
+	return r, nil
+}
+
+// Has reports whether the file has been added.
+func (r *Registry) Has(file string) bool {
+	_, ok := r.files[file]
+	return ok
+}
+
+// AddFile adds an encoded FileDescriptorProto, returning the files it
+// depends upon.
+func (r *Registry) AddFile(b []byte) ([]string, error) {
+	file := new(descriptorpb.FileDescriptorProto)
+	if err := proto.Unmarshal(b, file); err != nil {
+		return nil, err
+	}
+	r.files[file.GetName()] = file
+	return file.Dependency, nil
+}
+
+// Methods returns the full names of every method, sorted.
+func (r *Registry) Methods() []string {
+	var names []string
+	for _, file := range r.files {
+		for _, s := range file.Service {
+			service := qualify(file.GetPackage(), s.GetName())
+			for _, m := range s.Method {
+				names = append(names, service+"/"+m.GetName())
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Method returns the method with the full name, given as package.Service/Method
+// or package.Service.Method, with its types resolved.
+func (r *Registry) Method(name string) (*Method, error) {
+	service, method, ok := SplitMethod(name)
+	if !ok {
+		return nil, fmt.Errorf("method %q must be given as package.Service/Method", name)
+	}
+	files, err := r.build()
+	if err != nil {
+		return nil, err
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	s, ok := d.(protoreflect.ServiceDescriptor)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("unknown service %q", service)
+	}
+	m := s.Methods().ByName(protoreflect.Name(method))
+	if m == nil {
+		return nil, fmt.Errorf("unknown method %q of service %q", method, service)
+	}
+	for _, message := range []protoreflect.MessageDescriptor{m.Input(), m.Output()} {
+		if err := resolved(message, make(map[protoreflect.FullName]bool)); err != nil {
+			return nil, err
+		}
+	}
+	return &Method{
+		Name:            service + "/" + method,
+		Input:           m.Input(),
+		Output:          m.Output(),
+		ClientStreaming: m.IsStreamingClient(),
+		ServerStreaming: m.IsStreamingServer(),
+		types:           dynamicpb.NewTypes(files),
+	}, nil
+}
+
+// build builds the descriptors of every file, dependencies first.  A file
+// depending upon one which is missing is built regardless, the types it
+// would have provided are only a problem if used.
+func (r *Registry) build() (*protoregistry.Files, error) {
+	files := new(protoregistry.Files)
+	options := protodesc.FileOptions{AllowUnresolvable: true}
+	resolver := &resolver{local: files}
+	pending := make(map[string]bool, len(r.files))
+	var add func(name string) error
+	add = func(name string) error {
+		file, ok := r.files[name]
+		if !ok || pending[name] {
+			return nil
+		}
+		if _, err := files.FindFileByPath(name); err == nil {
+			return nil
+		}
+		pending[name] = true
+		for _, dependency := range file.Dependency {
+			if err := add(dependency); err != nil {
+				return err
+			}
+		}
+		fd, err := options.New(file, resolver)
+		if err != nil {
+			return fmt.Errorf("invalid file descriptor %s: %w", name, err)
+		}
+		return files.RegisterFile(fd)
+	}
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := add(name); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// resolver resolves the files of the registry, falling back to the well
+// known types linked into the binary.
+type resolver struct {
+	local *protoregistry.Files
+}
+
+// FindFileByPath implements protodesc.Resolver.
+func (r *resolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.local.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+// FindDescriptorByName implements protodesc.Resolver.
+func (r *resolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.local.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// resolved returns an error if the message, or that of any of its fields,
+// could not be resolved.
+func resolved(m protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) error {
+	if m.IsPlaceholder() {
+		return fmt.Errorf("unknown message type %q, is a dependency missing?", m.FullName())
+	}
+	if seen[m.FullName()] {
+		return nil
+	}
+	seen[m.FullName()] = true
+	fields := m.Fields()
+	for i := range fields.Len() {
+		f := fields.Get(i)
+		if e := f.Enum(); e != nil && e.IsPlaceholder() {
+			return fmt.Errorf("unknown enum type %q of field %s, is a dependency missing?", e.FullName(), f.FullName())
+		}
+		if nested := f.Message(); nested != nil {
+			if err := resolved(nested, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SplitMethod splits the full name of a method, package.Service/Method or
+// package.Service.Method, into the service and method.
+func SplitMethod(name string) (string, string, bool) {
+	name = strings.TrimPrefix(name, "/")
+	i := strings.LastIndexAny(name, "/.")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", false
+	}
+	return name[:i], name[i+1:], true
+}
+
+// qualify joins a name onto its scope.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
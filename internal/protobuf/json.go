@@ -0,0 +1,33 @@
+package protobuf
+
+import (
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Marshal encodes the JSON as the input message of the method, accepting
+// the canonical JSON mapping of protocol buffers: fields by their JSON or
+// original name, 64 bit integers as numbers or strings, bytes as base64,
+// enums by name or number, maps as objects and the well known types in
+// their special forms.
+func (m *Method) Marshal(data []byte) ([]byte, error) {
+	message := dynamicpb.NewMessage(m.Input)
+	options := protojson.UnmarshalOptions{Resolver: m.resolver()}
+	if err := options.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(message)
+}
+
+// resolver returns the types the messages of the method may embed.
+func (m *Method) resolver() interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+} {
+	if m.types == nil {
+		return protoregistry.GlobalTypes
+	}
+	return m.types
+}
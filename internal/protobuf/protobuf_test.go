@@ -0,0 +1,292 @@
+package protobuf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// field describes a singular or repeated field.
+func field(name string, number int32, repeated bool, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  label.Enum(),
+		Type:   typ.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+// testFile describes a proto3 file:
+//
+//	package test;
+//	import "google/protobuf/any.proto"; // and the others used below
+//	enum Mode { UNKNOWN = 0; FAST = 1; }
+//	message Request {
+//	  message Inner { string value = 1; }
+//	  string user_name = 1;
+//	  int32 count = 2;
+//	  repeated int64 ids = 3;
+//	  map<string, int32> labels = 4;
+//	  Mode mode = 5;
+//	  Inner inner = 6;
+//	  bytes data = 7;
+//	  sint32 delta = 8;
+//	  google.protobuf.Timestamp at = 9;
+//	  google.protobuf.Duration took = 10;
+//	  google.protobuf.StringValue note = 11;
+//	  google.protobuf.Struct extra = 12;
+//	  google.protobuf.Any detail = 13;
+//	}
+//	service Echo { rpc Say(Request) returns (Request); }
+func testFile() *descriptorpb.FileDescriptorProto {
+	const (
+		str     = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		message = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("test"),
+		Dependency: []string{
+			"google/protobuf/any.proto",
+			"google/protobuf/duration.proto",
+			"google/protobuf/struct.proto",
+			"google/protobuf/timestamp.proto",
+			"google/protobuf/wrappers.proto",
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Request"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("user_name", 1, false, str, ""),
+				field("count", 2, false, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+				field("ids", 3, true, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+				field("labels", 4, true, message, ".test.Request.LabelsEntry"),
+				field("mode", 5, false, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".test.Mode"),
+				field("inner", 6, false, message, ".test.Request.Inner"),
+				field("data", 7, false, descriptorpb.FieldDescriptorProto_TYPE_BYTES, ""),
+				field("delta", 8, false, descriptorpb.FieldDescriptorProto_TYPE_SINT32, ""),
+				field("at", 9, false, message, ".google.protobuf.Timestamp"),
+				field("took", 10, false, message, ".google.protobuf.Duration"),
+				field("note", 11, false, message, ".google.protobuf.StringValue"),
+				field("extra", 12, false, message, ".google.protobuf.Struct"),
+				field("detail", 13, false, message, ".google.protobuf.Any"),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{
+				{Name: proto.String("Inner"), Field: []*descriptorpb.FieldDescriptorProto{field("value", 1, false, str, "")}},
+				{
+					Name: proto.String("LabelsEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, false, str, ""),
+						field("value", 2, false, descriptorpb.FieldDescriptorProto_TYPE_INT32, ""),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				},
+			},
+		}},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Mode"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("FAST"), Number: proto.Int32(1)},
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Say"),
+				InputType:  proto.String(".test.Request"),
+				OutputType: proto.String(".test.Request"),
+			}},
+		}},
+		Syntax: proto.String("proto3"),
+	}
+}
+
+// testMethod returns test.Echo/Say.
+func testMethod(t *testing.T) *Method {
+	t.Helper()
+	b, err := proto.Marshal(testFile())
+	require.NoError(t, err)
+	r := NewRegistry()
+	_, err = r.AddFile(b)
+	require.NoError(t, err)
+	m, err := r.Method("test.Echo/Say")
+	require.NoError(t, err)
+	return m
+}
+
+func TestRegistry(t *testing.T) {
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{testFile()}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "test.protoset")
+	require.NoError(t, os.WriteFile(path, set, 0o600))
+	r, err := ReadDescriptorSet(path)
+	require.NoError(t, err)
+	assert.True(t, r.Has("test.proto"))
+	assert.Equal(t, []string{"test.Echo/Say"}, r.Methods())
+
+	m, err := r.Method("test.Echo.Say")
+	require.NoError(t, err)
+	assert.Equal(t, "test.Echo/Say", m.Name)
+	assert.Equal(t, "test.Request", string(m.Input.FullName()))
+	assert.False(t, m.ClientStreaming || m.ServerStreaming)
+	assert.True(t, m.Input.Fields().ByName("ids").IsPacked())
+	assert.True(t, m.Input.Fields().ByName("labels").IsMap())
+	assert.Equal(t, "userName", m.Input.Fields().ByName("user_name").JSONName())
+
+	_, err = r.Method("test.Echo/Shout")
+	assert.ErrorContains(t, err, `unknown method "Shout"`)
+	_, err = r.Method("test.Other/Say")
+	assert.ErrorContains(t, err, `unknown service "test.Other"`)
+	_, err = r.Method("Echo")
+	assert.Error(t, err)
+}
+
+func TestAddFileReturnsDependencies(t *testing.T) {
+	b, err := proto.Marshal(testFile())
+	require.NoError(t, err)
+	deps, err := NewRegistry().AddFile(b)
+	require.NoError(t, err)
+	assert.Equal(t, testFile().Dependency, deps)
+}
+
+func TestRegistryResolvesFilesAddedInAnyOrder(t *testing.T) {
+	shared := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("shared.proto"),
+		Package:     proto.String("shared"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Thing")}},
+		Syntax:      proto.String("proto3"),
+	}
+	service := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("service.proto"),
+		Package:    proto.String("service"),
+		Dependency: []string{"shared.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Things"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".shared.Thing"),
+				OutputType: proto.String(".shared.Thing"),
+			}},
+		}},
+		Syntax: proto.String("proto3"),
+	}
+	r := NewRegistry()
+	b, err := proto.Marshal(service)
+	require.NoError(t, err)
+	_, err = r.AddFile(b)
+	require.NoError(t, err)
+	_, err = r.Method("service.Things/Get")
+	assert.ErrorContains(t, err, `unknown message type "shared.Thing", is a dependency missing?`)
+
+	// The dependency arriving after the file which needs it resolves it.
+	b, err = proto.Marshal(shared)
+	require.NoError(t, err)
+	_, err = r.AddFile(b)
+	require.NoError(t, err)
+	m, err := r.Method("service.Things/Get")
+	require.NoError(t, err)
+	assert.Equal(t, "shared.Thing", string(m.Input.FullName()))
+}
+
+// TestMarshalRoundTrip encodes each document and decodes the encoding
+// again, which must give back the same message in its canonical form.
+func TestMarshalRoundTrip(t *testing.T) {
+	m := testMethod(t)
+	tests := map[string]struct {
+		json string
+		want string
+	}{
+		"json name":                         {json: `{"userName": "alice"}`, want: `{"userName": "alice"}`},
+		"original name":                     {json: `{"user_name": "alice"}`, want: `{"userName": "alice"}`},
+		"negative int32":                    {json: `{"count": -1}`, want: `{"count": -1}`},
+		"packed repeated with string int64": {json: `{"ids": [1, "300"]}`, want: `{"ids": ["1", "300"]}`},
+		"map":                               {json: `{"labels": {"a": 2, "b": 3}}`, want: `{"labels": {"a": 2, "b": 3}}`},
+		"enum by name":                      {json: `{"mode": "FAST"}`, want: `{"mode": "FAST"}`},
+		"enum by number":                    {json: `{"mode": 1}`, want: `{"mode": "FAST"}`},
+		"nested message":                    {json: `{"inner": {"value": "x"}}`, want: `{"inner": {"value": "x"}}`},
+		"bytes":                             {json: `{"data": "aGk="}`, want: `{"data": "aGk="}`},
+		"zigzag":                            {json: `{"delta": -2}`, want: `{"delta": -2}`},
+		"null is omitted":                   {json: `{"inner": null}`, want: `{}`},
+		"timestamp":                         {json: `{"at": "1970-01-01T00:00:10.500Z"}`, want: `{"at": "1970-01-01T00:00:10.500Z"}`},
+		"duration":                          {json: `{"took": "1.5s"}`, want: `{"took": "1.500s"}`},
+		"wrapper":                           {json: `{"note": "hi"}`, want: `{"note": "hi"}`},
+		"struct":                            {json: `{"extra": {"a": [1, "b", null]}}`, want: `{"extra": {"a": [1, "b", null]}}`},
+		"any":                               {json: `{"detail": {"@type": "type.googleapis.com/test.Request.Inner", "value": "x"}}`, want: `{"detail": {"@type": "type.googleapis.com/test.Request.Inner", "value": "x"}}`},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := m.Marshal([]byte(tc.json))
+			require.NoError(t, err)
+			decoded := dynamicpb.NewMessage(m.Input)
+			require.NoError(t, proto.UnmarshalOptions{Resolver: m.types}.Unmarshal(b, decoded))
+			got, err := protojson.MarshalOptions{Resolver: m.types}.Marshal(decoded)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestMarshalEncoding(t *testing.T) {
+	m := testMethod(t)
+	tag := func(number protowire.Number, typ protowire.Type) []byte {
+		return protowire.AppendTag(nil, number, typ)
+	}
+	tests := map[string]struct {
+		json string
+		want []byte
+	}{
+		"string":                          {json: `{"userName": "a"}`, want: protowire.AppendString(tag(1, protowire.BytesType), "a")},
+		"negative int32 is sign extended": {json: `{"count": -1}`, want: protowire.AppendVarint(tag(2, protowire.VarintType), 1<<64-1)},
+		"packed repeated":                 {json: `{"ids": [1, 300]}`, want: protowire.AppendBytes(tag(3, protowire.BytesType), []byte{1, 0xac, 0x02})},
+		"zigzag":                          {json: `{"delta": -2}`, want: protowire.AppendVarint(tag(8, protowire.VarintType), 3)},
+		"empty":                           {json: `{}`, want: []byte{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := m.Marshal([]byte(tc.json))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, append([]byte{}, b...))
+		})
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	m := testMethod(t)
+	tests := map[string]struct {
+		json string
+		want string
+	}{
+		"invalid json":    {json: `{`, want: "unexpected EOF"},
+		"not an object":   {json: `[]`, want: "unexpected token ["},
+		"unknown field":   {json: `{"nope": 1}`, want: `unknown field "nope"`},
+		"wrong type":      {json: `{"userName": 1}`, want: "invalid value for string field userName"},
+		"overflow":        {json: `{"count": 3000000000}`, want: "invalid value for int32 field count"},
+		"unknown enum":    {json: `{"mode": "SLOW"}`, want: "invalid value for enum field mode"},
+		"repeated scalar": {json: `{"ids": 1}`, want: "unexpected token 1"},
+		"invalid base64":  {json: `{"data": "!"}`, want: "invalid value for bytes field data"},
+		"nested field":    {json: `{"inner": {"nope": 1}}`, want: `unknown field "nope"`},
+		"unknown any":     {json: `{"detail": {"@type": "type.googleapis.com/test.Nope"}}`, want: "unable to resolve"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := m.Marshal([]byte(tc.json))
+			assert.ErrorContains(t, err, tc.want)
+		})
+	}
+}
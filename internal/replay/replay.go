@@ -0,0 +1,196 @@
+// Package replay reads recorded requests from access logs so that
+// production traffic can be replayed against another target, preserving
+// the original timing between requests if desired.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// combinedTime is the layout of timestamps in common and combined logs.
+const combinedTime = "02/Jan/2006:15:04:05 -0700"
+
+// combined matches the common log format, which the NGINX and Apache
+// combined formats extend, capturing the timestamp and request line.
+var combined = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*"`)
+
+// Entry is a single recorded request.
+type Entry struct {
+	Method string
+	// Target is the path (and query) requested.
+	Target string
+	At     time.Time
+}
+
+// ReadFile reads the entries of an access log, see Parse.
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// Parse reads the entries of an access log, each line being either in
+// the NGINX/Apache common or combined log format or a JSON object holding
+// the method, path (or uri/url) and timestamp (or time/ts) of a request.
+// JSON timestamps are RFC 3339 or seconds since the epoch.  Blank lines
+// are ignored and the entries are returned in the order they were
+// recorded.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		parse := parseCombined
+		if strings.HasPrefix(line, "{") {
+			parse = parseJSON
+		}
+		e, err := parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no requests recorded")
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+	return entries, nil
+}
+
+// parseCombined parses a line of the common or combined log format.
+func parseCombined(line string) (Entry, error) {
+	m := combined.FindStringSubmatch(line)
+	if m == nil {
+		return Entry{}, errors.New("not a common, combined or JSON log line")
+	}
+	at, err := time.Parse(combinedTime, m[1])
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid timestamp %q", m[1])
+	}
+	if _, err := url.ParseRequestURI(m[3]); err != nil {
+		return Entry{}, fmt.Errorf("invalid request target %q", m[3])
+	}
+	return Entry{Method: m[2], Target: m[3], At: at}, nil
+}
+
+// parseJSON parses a JSON log line.
+func parseJSON(line string) (Entry, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return Entry{}, err
+	}
+	e := Entry{Method: http.MethodGet}
+	if method, ok := first(fields, "method").(string); ok && method != "" {
+		e.Method = strings.ToUpper(method)
+	}
+	target, ok := first(fields, "path", "uri", "request_uri", "url").(string)
+	if !ok || target == "" {
+		return Entry{}, errors.New("no path, uri or url recorded")
+	}
+	if _, err := url.ParseRequestURI(target); err != nil {
+		return Entry{}, fmt.Errorf("invalid request target %q", target)
+	}
+	e.Target = target
+	switch at := first(fields, "timestamp", "time", "ts").(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, at)
+		if err != nil {
+			if seconds, ferr := strconv.ParseFloat(at, 64); ferr == nil {
+				t, err = epoch(seconds), nil
+			}
+		}
+		if err != nil {
+			return Entry{}, fmt.Errorf("invalid timestamp %q", at)
+		}
+		e.At = t
+	case float64:
+		e.At = epoch(at)
+	default:
+		return Entry{}, errors.New("no timestamp, time or ts recorded")
+	}
+	return e, nil
+}
+
+// first returns the value of the first of the keys present.
+func first(fields map[string]any, keys ...string) any {
+	for _, k := range keys {
+		if v, ok := fields[k]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// epoch converts seconds since the epoch to a time.
+func epoch(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// Source replays the entries against the host of the template request,
+// carrying its headers, repeating them from the beginning once exhausted.
+// Each request is due at its original offset from the first divided by the
+// speed, a zero speed replays them as fast as possible.
+//
+// Source is not safe for concurrent use.
+type Source struct {
+	entries []Entry
+	speed   float64
+	next    int
+	// loop is the offset of the current repetition of the entries.
+	loop time.Duration
+}
+
+// NewSource returns a Source replaying the entries at the given speed.
+func NewSource(entries []Entry, speed float64) *Source {
+	return &Source{entries: entries, speed: speed}
+}
+
+// Next implements coordinator.Source.
+func (s *Source) Next(template *http.Request) (*http.Request, time.Duration, bool) {
+	if s.next == len(s.entries) {
+		s.next = 0
+		// Repeat after the original span plus a typical gap between
+		// requests.
+		span := s.entries[len(s.entries)-1].At.Sub(s.entries[0].At)
+		s.loop += span + span/time.Duration(max(1, len(s.entries)-1))
+	}
+	e := s.entries[s.next]
+	s.next++
+
+	request := template.Clone(template.Context())
+	request.Method = e.Method
+	if target, err := url.Parse(e.Target); err == nil {
+		request.URL = template.URL.ResolveReference(&url.URL{Path: target.Path, RawPath: target.RawPath, RawQuery: target.RawQuery})
+	}
+	if s.speed <= 0 {
+		return request, 0, true
+	}
+	at := s.loop + e.At.Sub(s.entries[0].At)
+	return request, time.Duration(float64(at) / s.speed), true
+}
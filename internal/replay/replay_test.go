@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	base := time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+	tests := map[string]struct {
+		line string
+		want Entry
+		err  string
+	}{
+		"combined": {
+			line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`,
+			want: Entry{Method: "GET", Target: "/apache_pb.gif?a=1", At: base},
+		},
+		"common": {
+			line: `10.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "POST /login HTTP/1.1" 302 0`,
+			want: Entry{Method: "POST", Target: "/login", At: base},
+		},
+		"json": {
+			line: `{"method":"delete","path":"/users/1","timestamp":"2000-10-10T20:55:36Z"}`,
+			want: Entry{Method: "DELETE", Target: "/users/1", At: base.UTC()},
+		},
+		"json epoch and url": {
+			line: `{"url":"https://example.com/a?b=c","ts":971211336.5}`,
+			want: Entry{Method: "GET", Target: "https://example.com/a?b=c", At: base.Add(500 * time.Millisecond)},
+		},
+		"json epoch string": {
+			line: `{"uri":"/a","time":"971211336"}`,
+			want: Entry{Method: "GET", Target: "/a", At: base},
+		},
+		"unknown format":    {line: `GET /a`, err: "not a common, combined or JSON"},
+		"bad timestamp":     {line: `1.1.1.1 - - [yesterday] "GET / HTTP/1.1" 200 0`, err: "invalid timestamp"},
+		"json without path": {line: `{"method":"GET","ts":1}`, err: "no path"},
+		"json without time": {line: `{"path":"/"}`, err: "no timestamp"},
+		"json bad target":   {line: `{"path":"a b","ts":1}`, err: "invalid request target"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			entries, err := Parse(strings.NewReader("\n" + tc.line + "\n"))
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				assert.ErrorContains(t, err, "line 2")
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, tc.want.Method, entries[0].Method)
+			assert.Equal(t, tc.want.Target, entries[0].Target)
+			assert.True(t, tc.want.At.Equal(entries[0].At), "want %s got %s", tc.want.At, entries[0].At)
+		})
+	}
+}
+
+func TestParseRequiresRequests(t *testing.T) {
+	_, err := Parse(strings.NewReader("\n\n"))
+	assert.ErrorContains(t, err, "no requests recorded")
+}
+
+func TestSource(t *testing.T) {
+	start := time.Unix(1000, 0)
+	entries := []Entry{
+		{Method: "GET", Target: "/a?x=1", At: start},
+		{Method: "POST", Target: "/b", At: start.Add(time.Second)},
+		{Method: "GET", Target: "https://elsewhere.com/c", At: start.Add(2 * time.Second)},
+	}
+	template, err := http.NewRequest(http.MethodGet, "http://localhost:8080/ignored", nil)
+	require.NoError(t, err)
+	template.Header.Set("Authorization", "Bearer abc")
+
+	tests := map[string]struct {
+		speed float64
+		want  []time.Duration
+	}{
+		"original pace":       {speed: 1, want: []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}},
+		"twice as fast":       {speed: 2, want: []time.Duration{0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond, 2 * time.Second}},
+		"as fast as possible": {speed: 0, want: []time.Duration{0, 0, 0, 0, 0}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewSource(entries, tc.speed)
+			var urls []string
+			for _, want := range tc.want {
+				request, at, ok := s.Next(template)
+				require.True(t, ok)
+				assert.Equal(t, want, at)
+				assert.Equal(t, "Bearer abc", request.Header.Get("Authorization"))
+				urls = append(urls, request.Method+" "+request.URL.String())
+			}
+			assert.Equal(t, []string{
+				"GET http://localhost:8080/a?x=1",
+				"POST http://localhost:8080/b",
+				"GET http://localhost:8080/c",
+				"GET http://localhost:8080/a?x=1",
+				"POST http://localhost:8080/b",
+			}, urls)
+		})
+	}
+}
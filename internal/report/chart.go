@@ -0,0 +1,167 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+)
+
+const (
+	// chart dimensions (in svg user units).
+	chartWidth  = 860
+	chartHeight = 280
+	padLeft     = 64
+	padRight    = 16
+	padTop      = 16
+	padBottom   = 40
+	ticks       = 5
+)
+
+// Point is a single x/y coordinate on a chart.
+type Point struct {
+	X, Y float64
+}
+
+// Series is a named line plotted on a LineChart.
+type Series struct {
+	Name   string
+	Colour string
+	Points []Point
+}
+
+// LineChart plots one or more series against shared axes.
+type LineChart struct {
+	XLabel string
+	YLabel string
+	Series []Series
+	// XTick formats the x axis tick labels, defaults to %g.
+	XTick func(float64) string
+}
+
+// BarChart plots a single value per label.
+type BarChart struct {
+	YLabel string
+	Labels []string
+	Values []float64
+	Colour string
+}
+
+// SVG renders the chart as an inline svg element.
+func (c LineChart) SVG() template.HTML {
+	xMin, xMax, yMax := math.Inf(1), math.Inf(-1), 0.0
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			xMin, xMax, yMax = math.Min(xMin, p.X), math.Max(xMax, p.X), math.Max(yMax, p.Y)
+		}
+	}
+	if math.IsInf(xMin, 0) {
+		return empty()
+	}
+	if xMax == xMin {
+		xMax = xMin + 1
+	}
+	yMax = niceCeil(yMax)
+	xTick := c.XTick
+	if xTick == nil {
+		xTick = func(v float64) string { return fmt.Sprintf("%g", round(v)) }
+	}
+
+	x := func(v float64) float64 { return padLeft + (v-xMin)/(xMax-xMin)*(chartWidth-padLeft-padRight) }
+	y := func(v float64) float64 { return chartHeight - padBottom - v/yMax*(chartHeight-padTop-padBottom) }
+
+	var b strings.Builder
+	open(&b)
+	axes(&b, c.YLabel, c.XLabel, yMax)
+	for i := 0; i <= ticks; i++ {
+		v := xMin + (xMax-xMin)*float64(i)/ticks
+		fmt.Fprintf(&b, `<text class="tick" x="%.1f" y="%d" text-anchor="middle">%s</text>`, x(v), chartHeight-padBottom+16, template.HTMLEscapeString(xTick(v)))
+	}
+	for i, s := range c.Series {
+		var path strings.Builder
+		for j, p := range s.Points {
+			op := "L"
+			if j == 0 {
+				op = "M"
+			}
+			fmt.Fprintf(&path, "%s%.1f %.1f ", op, x(p.X), y(p.Y))
+		}
+		fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="%s" stroke-width="2"/>`, path.String(), s.Colour)
+		for _, p := range s.Points {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2" fill="%s"><title>%s: %s, %g</title></circle>`, x(p.X), y(p.Y), s.Colour, template.HTMLEscapeString(s.Name), template.HTMLEscapeString(xTick(p.X)), round(p.Y))
+		}
+		// legend
+		lx := float64(padLeft + 10 + i*150)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="4" width="10" height="10" fill="%s"/><text class="legend" x="%.1f" y="13">%s</text>`, lx, s.Colour, lx+14, template.HTMLEscapeString(s.Name))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// SVG renders the chart as an inline svg element.
+func (c BarChart) SVG() template.HTML {
+	if len(c.Values) == 0 {
+		return empty()
+	}
+	yMax := 0.0
+	for _, v := range c.Values {
+		yMax = math.Max(yMax, v)
+	}
+	yMax = niceCeil(yMax)
+	slot := float64(chartWidth-padLeft-padRight) / float64(len(c.Values))
+	var b strings.Builder
+	open(&b)
+	axes(&b, c.YLabel, "", yMax)
+	for i, v := range c.Values {
+		h := v / yMax * (chartHeight - padTop - padBottom)
+		x := padLeft + float64(i)*slot
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %g</title></rect>`,
+			x+slot*0.1, chartHeight-padBottom-h, slot*0.8, h, c.Colour, template.HTMLEscapeString(c.Labels[i]), round(v))
+		if len(c.Values) <= 20 || i%(len(c.Values)/10) == 0 {
+			fmt.Fprintf(&b, `<text class="tick" x="%.1f" y="%d" text-anchor="middle">%s</text>`, x+slot/2, chartHeight-padBottom+16, template.HTMLEscapeString(c.Labels[i]))
+		}
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// open writes the opening svg element.
+func open(b *strings.Builder) {
+	fmt.Fprintf(b, `<svg viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg" role="img">`, chartWidth, chartHeight)
+}
+
+// axes writes the axes, horizontal grid lines and their labels.
+func axes(b *strings.Builder, yLabel, xLabel string, yMax float64) {
+	for i := 0; i <= ticks; i++ {
+		v := yMax * float64(i) / ticks
+		py := chartHeight - padBottom - v/yMax*(chartHeight-padTop-padBottom)
+		fmt.Fprintf(b, `<line class="grid" x1="%d" x2="%d" y1="%.1f" y2="%.1f"/>`, padLeft, chartWidth-padRight, py, py)
+		fmt.Fprintf(b, `<text class="tick" x="%d" y="%.1f" text-anchor="end">%g</text>`, padLeft-6, py+4, round(v))
+	}
+	fmt.Fprintf(b, `<text class="label" transform="translate(14 %d) rotate(-90)" text-anchor="middle">%s</text>`, chartHeight/2, template.HTMLEscapeString(yLabel))
+	fmt.Fprintf(b, `<text class="label" x="%d" y="%d" text-anchor="middle">%s</text>`, chartWidth/2, chartHeight-4, template.HTMLEscapeString(xLabel))
+}
+
+// empty is rendered in place of a chart without any data.
+func empty() template.HTML {
+	return `<p class="muted">No data recorded.</p>`
+}
+
+// niceCeil rounds v up to a value that divides cleanly into axis ticks.
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 2.5, 5, 10} {
+		if step*magnitude >= v {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// round rounds v to two decimal places for display.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
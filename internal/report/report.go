@@ -0,0 +1,310 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/shape"
+)
+
+// page is the self contained report template, all styling is inline
+// and charts are pre-rendered svg so it can be viewed offline.
+const page = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>vessel report - {{.Result.Endpoint}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2rem auto; max-width: 960px; color: #1f2328; }
+h1 { font-size: 1.6rem; margin-bottom: 0; }
+h2 { font-size: 1.15rem; margin-top: 2rem; border-bottom: 1px solid #d0d7de; padding-bottom: .3rem; }
+table { border-collapse: collapse; width: 100%; font-size: .9rem; }
+th, td { text-align: left; padding: .3rem .6rem; border-bottom: 1px solid #eaeef2; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.muted { color: #656d76; }
+.cards { display: flex; flex-wrap: wrap; gap: .8rem; margin-top: 1rem; }
+.card { border: 1px solid #d0d7de; border-radius: 6px; padding: .6rem 1rem; min-width: 120px; }
+.card b { display: block; font-size: 1.3rem; }
+svg { width: 100%; height: auto; }
+svg .grid { stroke: #eaeef2; }
+svg .tick, svg .legend { font-size: 11px; fill: #656d76; }
+svg .label { font-size: 12px; fill: #1f2328; }
+</style>
+</head>
+<body>
+<h1>⚡ vessel report</h1>
+<p class="muted">{{.Result.Endpoint}} &middot; vessel-{{.Result.Version}} &middot; started {{.Result.Started.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<div class="cards">
+<div class="card">Requests<b>{{.Result.Requests}}</b></div>
+<div class="card">Throughput<b>{{printf "%.2f" .Result.RPS}}/s</b></div>
+<div class="card">Error rate<b>{{printf "%.2f" (percent .Result.ErrorRate)}}%</b></div>
+<div class="card">p50<b>{{ms .Result.Latency.P50}}ms</b></div>
+<div class="card">p99<b>{{ms .Result.Latency.P99}}ms</b></div>
+<div class="card">Wall time<b>{{.Result.Elapsed}}</b></div>
+</div>
+
+<h2>Latency over time</h2>
+{{.LatencyOverTime.SVG}}
+
+<h2>Throughput over time</h2>
+{{.ThroughputOverTime.SVG}}
+
+{{if .Shape.Series}}<h2>Target vs achieved throughput</h2>
+<p class="muted">{{.Result.Config.Shape}}</p>
+{{.Shape.SVG}}
+{{end}}
+<h2>Latency percentiles</h2>
+{{.Percentiles.SVG}}
+
+<h2>Latency distribution</h2>
+{{.Distribution.SVG}}
+
+<h2>Status codes</h2>
+{{.StatusCodes.SVG}}
+<table>
+<tr><th>Status</th><th>Count</th></tr>
+{{range $i, $label := .StatusCodes.Labels}}<tr><td>{{$label}}</td><td class="num">{{index $.StatusCodes.Values $i}}</td></tr>
+{{end}}</table>
+
+<h2>Errors</h2>
+<table>
+<tr><th>Group</th><th>Count</th></tr>
+{{range .Errors}}<tr><td>{{.Name}}</td><td class="num">{{.Value}}</td></tr>
+{{else}}<tr><td colspan="2" class="muted">No errors recorded.</td></tr>
+{{end}}</table>
+
+<h2>Endpoints</h2>
+<table>
+<tr><th>Endpoint</th><th>Requests</th><th>Errors</th><th>RPS</th><th>p50 (ms)</th><th>p90 (ms)</th><th>p99 (ms)</th><th>Max (ms)</th></tr>
+{{range .Endpoints}}<tr><td>{{.Name}}</td><td class="num">{{.Result.Requests}}</td><td class="num">{{.Result.Errors}}</td><td class="num">{{printf "%.2f" .Result.RPS}}</td><td class="num">{{ms .Result.Latency.P50}}</td><td class="num">{{ms .Result.Latency.P90}}</td><td class="num">{{ms .Result.Latency.P99}}</td><td class="num">{{ms .Result.Latency.Max}}</td></tr>
+{{end}}</table>
+
+<h2>Configuration</h2>
+<table>
+{{range .Config}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
+
+// entry is a named value rendered in a table.
+type entry struct {
+	Name  string
+	Value any
+}
+
+// endpoint is a named per endpoint result.
+type endpoint struct {
+	Name   string
+	Result *collector.EndpointResult
+}
+
+// view is the data rendered by the report template.
+type view struct {
+	Result             *collector.Result
+	LatencyOverTime    LineChart
+	ThroughputOverTime LineChart
+	Shape              LineChart
+	Percentiles        LineChart
+	Distribution       BarChart
+	StatusCodes        BarChart
+	Errors             []entry
+	Endpoints          []endpoint
+	Config             []entry
+}
+
+var tmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms":      func(us int64) string { return strconv.FormatFloat(float64(us)/1000, 'f', 2, 64) },
+	"percent": func(v float64) float64 { return v * 100 },
+}).Parse(page))
+
+// WriteFile writes the HTML report for the result to the given path.
+func WriteFile(path string, r *collector.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Write(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write renders a single, self contained HTML report for the result.
+func Write(w io.Writer, r *collector.Result) error {
+	v := &view{
+		Result:             r,
+		LatencyOverTime:    latencyOverTime(r),
+		ThroughputOverTime: throughputOverTime(r),
+		Shape:              targetThroughput(r),
+		StatusCodes:        statusCodes(r),
+		Endpoints:          endpoints(r),
+		Errors:             errorGroups(r),
+		Config:             configuration(r),
+	}
+	var err error
+	if v.Percentiles, v.Distribution, err = distribution(r); err != nil {
+		return err
+	}
+	return tmpl.Execute(w, v)
+}
+
+// latencyOverTime plots the per interval latency percentiles.
+func latencyOverTime(r *collector.Result) LineChart {
+	p50 := Series{Name: "p50", Colour: "#1f77b4"}
+	p90 := Series{Name: "p90", Colour: "#ff7f0e"}
+	p99 := Series{Name: "p99", Colour: "#d62728"}
+	for _, i := range r.Intervals {
+		x := i.Offset.Seconds()
+		p50.Points = append(p50.Points, Point{x, float64(i.P50) / 1000})
+		p90.Points = append(p90.Points, Point{x, float64(i.P90) / 1000})
+		p99.Points = append(p99.Points, Point{x, float64(i.P99) / 1000})
+	}
+	return LineChart{XLabel: "elapsed (s)", YLabel: "latency (ms)", Series: []Series{p50, p90, p99}}
+}
+
+// throughputOverTime plots the per interval request and error rates.
+func throughputOverTime(r *collector.Result) LineChart {
+	rps := Series{Name: "requests/s", Colour: "#2ca02c"}
+	errs := Series{Name: "errors/s", Colour: "#d62728"}
+	for _, i := range r.Intervals {
+		x := i.Offset.Seconds()
+		rps.Points = append(rps.Points, Point{x, i.RPS})
+		errs.Points = append(errs.Points, Point{x, i.RPS * float64(i.Errors) / math.Max(1, float64(i.Requests))})
+	}
+	return LineChart{XLabel: "elapsed (s)", YLabel: "rate (/s)", Series: []Series{rps, errs}}
+}
+
+// targetThroughput plots the target rate of the load shape, if the run had
+// one, against the throughput achieved in each interval.
+func targetThroughput(r *collector.Result) LineChart {
+	c := LineChart{XLabel: "elapsed (s)", YLabel: "rate (/s)"}
+	if r.Config == nil || r.Config.Shape == "" || len(r.Intervals) == 0 {
+		return c
+	}
+	s, err := shape.Parse(r.Config.Shape)
+	if err != nil {
+		return c
+	}
+	// sample the target finely enough that a short spike is visible.
+	const samples = 500
+	target := Series{Name: "target/s", Colour: "#7f7f7f"}
+	for i := range samples + 1 {
+		at := r.Elapsed * time.Duration(i) / samples
+		target.Points = append(target.Points, Point{at.Seconds(), s.Rate(at)})
+	}
+	achieved := Series{Name: "achieved/s", Colour: "#2ca02c"}
+	for _, i := range r.Intervals {
+		achieved.Points = append(achieved.Points, Point{i.Offset.Seconds(), i.RPS})
+	}
+	c.Series = []Series{target, achieved}
+	return c
+}
+
+// distribution plots the latency percentile curve, on the conventional
+// 1/(1-q) log scale, and a histogram of latencies.
+func distribution(r *collector.Result) (LineChart, BarChart, error) {
+	h, err := r.Latency.Decode()
+	if err != nil {
+		return LineChart{}, BarChart{}, fmt.Errorf("unable to decode latency histogram: %w", err)
+	}
+	curve := LineChart{
+		XLabel: "percentile",
+		YLabel: "latency (ms)",
+		XTick: func(x float64) string {
+			return strconv.FormatFloat(100-100/math.Pow(10, x), 'g', 6, 64) + "%"
+		},
+	}
+	bars := BarChart{YLabel: "requests", Colour: "#1f77b4"}
+	if h.TotalCount() == 0 {
+		return curve, bars, nil
+	}
+
+	percentiles := Series{Name: "latency", Colour: "#9467bd"}
+	for _, q := range []float64{0, 25, 50, 75, 90, 95, 97.5, 99, 99.5, 99.9, 99.95, 99.99, 99.999} {
+		x := math.Log10(100 / (100 - q))
+		percentiles.Points = append(percentiles.Points, Point{x, float64(h.ValueAtQuantile(q)) / 1000})
+	}
+	curve.Series = []Series{percentiles}
+
+	// bucket everything upto p99.9 linearly, the long tail is better
+	// represented by the percentile curve.
+	const buckets = 40
+	lo, hi := h.Min(), h.ValueAtQuantile(99.9)
+	width := max(1, (hi-lo+buckets-1)/buckets)
+	counts := make([]float64, buckets)
+	for _, bar := range h.Distribution() {
+		if bar.Count == 0 || bar.From > hi {
+			continue
+		}
+		idx := min(buckets-1, int((bar.From-lo)/width))
+		counts[max(0, idx)] += float64(bar.Count)
+	}
+	for i := range counts {
+		bars.Labels = append(bars.Labels, strconv.FormatFloat(float64(lo+int64(i)*width)/1000, 'f', 2, 64)+"ms")
+	}
+	bars.Values = counts
+	return curve, bars, nil
+}
+
+// statusCodes plots the number of responses for each status code.
+func statusCodes(r *collector.Result) BarChart {
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	c := BarChart{YLabel: "responses", Colour: "#17becf"}
+	for _, code := range codes {
+		c.Labels = append(c.Labels, strconv.Itoa(code))
+		c.Values = append(c.Values, float64(r.StatusCodes[code]))
+	}
+	return c
+}
+
+// errorGroups lists the non empty error groups.
+func errorGroups(r *collector.Result) []entry {
+	var groups []entry
+	for name, count := range r.ErrorGroups {
+		if count > 0 {
+			groups = append(groups, entry{name, count})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// endpoints lists the per endpoint results ordered by name.
+func endpoints(r *collector.Result) []endpoint {
+	out := make([]endpoint, 0, len(r.Endpoints))
+	for name, result := range r.Endpoints {
+		out = append(out, endpoint{name, result})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// configuration flattens the run configuration into a table, redacting
+// credentials so that the report is safe to share.
+func configuration(r *collector.Result) []entry {
+	if r.Config == nil {
+		return nil
+	}
+	// Results saved before configs were redacted may still hold them.
+	v := reflect.ValueOf(*r.Config.Redacted())
+	out := make([]entry, 0, v.NumField())
+	for i := range v.NumField() {
+		out = append(out, entry{v.Type().Field(i).Name, fmt.Sprint(v.Field(i).Interface())})
+	}
+	return out
+}
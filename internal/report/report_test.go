@@ -0,0 +1,73 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/config"
+)
+
+func TestReportIsSelfContained(t *testing.T) {
+	h := hdrhistogram.New(1, 60_000_000, 3)
+	for i := range int64(1000) {
+		require.NoError(t, h.RecordValue(1000+i*10))
+	}
+	latency, err := collector.NewLatencyResult(h)
+	require.NoError(t, err)
+	r := &collector.Result{
+		Version:     "v0.0.1",
+		Endpoint:    "http://localhost:8080",
+		Config:      &config.Config{BasicAuth: "user:secret", Headers: []string{"Authorization: Bearer secret"}, HMAC: "X-Signature:secret", Concurrency: 10},
+		Requests:    1001,
+		Errors:      1,
+		Latency:     latency,
+		StatusCodes: map[int]int64{200: 990, 503: 10},
+		ErrorGroups: map[string]int64{collector.Timeout: 1, collector.DNS: 0},
+		Intervals: []collector.Interval{
+			{Offset: 0, Requests: 500, RPS: 500, P50: 2000, P90: 4000, P99: 9000},
+			{Offset: time.Second, Requests: 501, Errors: 1, RPS: 501, P50: 3000, P90: 5000, P99: 10000},
+		},
+	}
+	var b bytes.Buffer
+	require.NoError(t, Write(&b, r))
+	out := b.String()
+	assert.Equal(t, 5, bytes.Count(b.Bytes(), []byte("<svg")))
+	assert.NotContains(t, out, "<script src")
+	assert.NotContains(t, out, "<link")
+	assert.NotContains(t, out, "secret")
+	assert.Contains(t, out, "<td>503</td>")
+	assert.Contains(t, out, "<td>Timeout</td>")
+	assert.NotContains(t, out, "<td>DNS</td>")
+}
+
+func TestReportPlotsShape(t *testing.T) {
+	r := &collector.Result{
+		Config:  &config.Config{Shape: "spike:base=100,peak=10x,at=1s,for=1s"},
+		Elapsed: 3 * time.Second,
+		Intervals: []collector.Interval{
+			{Offset: 0, RPS: 100},
+			{Offset: time.Second, RPS: 950},
+			{Offset: 2 * time.Second, RPS: 100},
+		},
+	}
+	var b bytes.Buffer
+	require.NoError(t, Write(&b, r))
+	assert.Contains(t, b.String(), "Target vs achieved throughput")
+	assert.Contains(t, b.String(), "target/s")
+
+	r.Config.Shape = ""
+	b.Reset()
+	require.NoError(t, Write(&b, r))
+	assert.NotContains(t, b.String(), "Target vs achieved throughput")
+}
+
+func TestEmptyReport(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, Write(&b, &collector.Result{}))
+	assert.Contains(t, b.String(), "No data recorded.")
+}
@@ -0,0 +1,19 @@
+package report
+
+import "github.com/symonk/vessel/internal/collector"
+
+// Sink renders the HTML report to a file once the run has finished.
+type Sink struct {
+	collector.NopSink
+	path string
+}
+
+// NewSink instantiates a new Sink writing to path and returns a ptr to it.
+func NewSink(path string) *Sink {
+	return &Sink{path: path}
+}
+
+// OnFinish implements collector.Sink.
+func (s *Sink) OnFinish(result *collector.Result) error {
+	return WriteFile(s.path, result)
+}
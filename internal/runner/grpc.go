@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/coordinator"
+	"github.com/symonk/vessel/internal/grpc"
+	"github.com/symonk/vessel/internal/hook"
+	"github.com/symonk/vessel/internal/protobuf"
+	"github.com/symonk/vessel/internal/validation"
+)
+
+// reflectTimeout bounds discovering a method through server reflection.
+const reflectTimeout = 30 * time.Second
+
+// GRPC configures cfg to call the gRPC method, package.Service/Method, of
+// the server at cfg.Endpoint rather than send it HTTP requests.  The method
+// is described by the descriptor set if given, otherwise by the server's
+// reflection service.
+//
+// The returned hook encodes the JSON body of each request as the call, it
+// must run after every hook which modifies the body.
+func GRPC(ctx context.Context, cfg *config.Config, name, descriptorSet string) (hook.RequestHook, error) {
+	service, _, ok := protobuf.SplitMethod(name)
+	if !ok {
+		return nil, fmt.Errorf("method %q must be given as package.Service/Method", name)
+	}
+	target := strings.TrimSuffix(cfg.Endpoint, "/")
+	cfg.GRPC = true
+	var (
+		registry *protobuf.Registry
+		err      error
+	)
+	if descriptorSet != "" {
+		registry, err = protobuf.ReadDescriptorSet(descriptorSet)
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, reflectTimeout)
+		defer cancel()
+		registry, err = grpc.Reflect(ctx, coordinator.NewClient(cfg), target, validation.ParseHTTPHeaders(cfg.Headers), service)
+	}
+	if err != nil {
+		return nil, err
+	}
+	method, err := registry.Method(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a feeder the body is constant, a malformed one would fail
+	// every call.
+	if cfg.Feeder == "" {
+		if _, err := grpc.Encode(method, []byte(cfg.Body)); err != nil {
+			return nil, fmt.Errorf("invalid request message: %w", err)
+		}
+	}
+	cfg.Endpoint = target + "/" + method.Name
+	cfg.Method = http.MethodPost
+	cfg.Headers = append(grpc.Header(), cfg.Headers...)
+	return grpc.Encoder(method), nil
+}
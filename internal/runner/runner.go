@@ -0,0 +1,302 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/symonk/vessel/internal/check"
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/coordinator"
+	"github.com/symonk/vessel/internal/distribution"
+	"github.com/symonk/vessel/internal/hook"
+	"github.com/symonk/vessel/internal/shape"
+	"github.com/symonk/vessel/internal/stats"
+	"github.com/symonk/vessel/internal/validation"
+)
+
+const (
+	// HTTP Headers
+	userAgentHeader = "User-Agent"
+)
+
+// Option is a functional option for a single run.
+type Option func(*runner)
+
+// runner holds the optional extensions of a single run.
+type runner struct {
+	out                io.Writer
+	collectorOptions   []collector.Option
+	coordinatorOptions []coordinator.Option
+	requestHooks       []hook.RequestHook
+	responseHooks      []hook.ResponseHook
+}
+
+// WithSummary writes the human readable summary to w once the run
+// has finished.
+func WithSummary(w io.Writer) Option {
+	return func(r *runner) {
+		r.out = w
+	}
+}
+
+// WithCollectorOptions applies the options to the EventCollector.
+func WithCollectorOptions(options ...collector.Option) Option {
+	return func(r *runner) {
+		r.collectorOptions = append(r.collectorOptions, options...)
+	}
+}
+
+// WithSinks registers sinks to consume the stream of results.
+func WithSinks(sinks ...collector.Sink) Option {
+	return func(r *runner) {
+		for _, s := range sinks {
+			r.collectorOptions = append(r.collectorOptions, collector.WithSink(s))
+		}
+	}
+}
+
+// WithRequestHooks runs the hooks on every request right before it is
+// dispatched, after any built in hooks enabled in the configuration.
+func WithRequestHooks(hooks ...hook.RequestHook) Option {
+	return func(r *runner) {
+		r.requestHooks = append(r.requestHooks, hooks...)
+	}
+}
+
+// WithResponseHooks runs the hooks on every response once its body has
+// been read.
+func WithResponseHooks(hooks ...hook.ResponseHook) Option {
+	return func(r *runner) {
+		r.responseHooks = append(r.responseHooks, hooks...)
+	}
+}
+
+// WithCoordinatorOptions applies the options to the RequestCoordinator.
+func WithCoordinatorOptions(options ...coordinator.Option) Option {
+	return func(r *runner) {
+		r.coordinatorOptions = append(r.coordinatorOptions, options...)
+	}
+}
+
+// Run executes a single load test described by cfg until either the
+// number of requests or the duration is reached, or ctx is cancelled
+// in which case in flight requests are finished and the work completed
+// prior is returned.
+//
+// The result is returned alongside any error encountered by the sinks.
+func Run(ctx context.Context, cfg *config.Config, options ...Option) (*collector.Result, error) {
+	r := new(runner)
+	for _, opt := range options {
+		opt(r)
+	}
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+	req, err := NewRequest(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	builtin, err := RequestHooks(cfg)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := check.ParseAll(cfg.Checks)
+	if err != nil {
+		return nil, err
+	}
+	requestHooks := append(builtin, r.requestHooks...)
+	coordinatorOptions := []coordinator.Option{
+		coordinator.WithRequestHooks(requestHooks...),
+		coordinator.WithResponseHooks(r.responseHooks...),
+		coordinator.WithChecks(checks...),
+		coordinator.WithPacing(cfg.Pacing),
+	}
+	schedule, err := schedule(cfg)
+	if err != nil {
+		return nil, err
+	}
+	coordinatorOptions = append(coordinatorOptions, schedule...)
+	if cfg.ThinkTime != "" {
+		think, err := distribution.Parse(cfg.ThinkTime)
+		if err != nil {
+			return nil, err
+		}
+		coordinatorOptions = append(coordinatorOptions, coordinator.WithThinkTime(think))
+	}
+	coordinatorOptions = append(coordinatorOptions, r.coordinatorOptions...)
+
+	out := r.out
+	if out == nil {
+		out = io.Discard
+	}
+	resultsChan := make(chan *stats.Stats, cfg.Concurrency)
+	collector := collector.New(resultsChan, out, cfg, r.collectorOptions...)
+	coordinator := coordinator.New(
+		ctx,
+		resultsChan,
+		cfg,
+		collector,
+		req,
+		coordinatorOptions...,
+	)
+	coordinator.Wait()
+	close(resultsChan)
+	if r.out != nil {
+		collector.Summarise()
+	}
+	return collector.Finish()
+}
+
+// schedule returns the options dispatching requests on the arrival schedule
+// of the configuration, if it has one.
+func schedule(cfg *config.Config) ([]coordinator.Option, error) {
+	if cfg.Shape != "" {
+		if cfg.Rate > 0 {
+			return nil, errors.New("rate and shape are mutually exclusive")
+		}
+		s, err := shape.Parse(cfg.Shape)
+		if err != nil {
+			return nil, err
+		}
+		// The gaps of a shape are scaled to its rate at the time.
+		arrivals, err := distribution.Arrivals(cfg.Arrival, 1)
+		if err != nil {
+			return nil, err
+		}
+		return []coordinator.Option{coordinator.WithArrivals(arrivals), coordinator.WithShape(s)}, nil
+	}
+	if cfg.Rate > 0 || (cfg.Arrival != "" && cfg.Arrival != "constant") {
+		arrivals, err := distribution.Arrivals(cfg.Arrival, cfg.Rate)
+		if err != nil {
+			return nil, err
+		}
+		return []coordinator.Option{coordinator.WithArrivals(arrivals)}, nil
+	}
+	return nil, nil
+}
+
+// Validate ensures the configuration describes a runnable load test,
+// normalising values where it is safe to do so.
+func Validate(cfg *config.Config) error {
+	if cfg.Amount == 0 && cfg.Duration == 0 {
+		return errors.New("-n or -d must not be zero when supplied")
+	}
+
+	// Ensure the endpoint is actual a valid URL
+	// TODO: Do we want to enforce host/scheme specifics?
+	if _, err := url.ParseRequestURI(cfg.Endpoint); err != nil {
+		return fmt.Errorf("bad endpoint provided: %v", err)
+	}
+
+	if _, err := coordinator.ParseResolve(cfg.Resolve); err != nil {
+		return err
+	}
+
+	switch cfg.Stream {
+	case "", config.StreamSSE, config.StreamChunks:
+	default:
+		return fmt.Errorf("stream must be %s or %s, got %q", config.StreamSSE, config.StreamChunks, cfg.Stream)
+	}
+
+	// Disallow negative MaxRPS, rate and concurrency.
+	cfg.MaxRPS = max(0, cfg.MaxRPS)
+	cfg.Rate = max(0, cfg.Rate)
+	cfg.Warmup = max(0, cfg.Warmup)
+	cfg.WarmupRequests = max(0, cfg.WarmupRequests)
+	cfg.Pacing = max(0, cfg.Pacing)
+	cfg.StreamTimeout = max(0, cfg.StreamTimeout)
+	cfg.StreamMaxEvents = max(0, cfg.StreamMaxEvents)
+	cfg.Concurrency = max(0, cfg.Concurrency)
+
+	// Do not allow spawning more workers than the number of requests
+	// to send for inefficiencies.
+	if cfg.Duration == 0 && cfg.Amount > 0 && cfg.Amount < int64(cfg.Concurrency) {
+		cfg.Concurrency = int(cfg.Amount)
+	}
+
+	if _, err := schedule(cfg); err != nil {
+		return err
+	}
+	if cfg.ThinkTime != "" {
+		if _, err := distribution.Parse(cfg.ThinkTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewRequest builds the template request, which is cloned for every
+// request sent, from the configuration.
+func NewRequest(cfg *config.Config) (*http.Request, error) {
+	// TODO: should not be the responsibility of a 'coordinator'.
+	req, err := coordinator.GenerateTemplateRequest(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %v", err)
+	}
+
+	// Append user provided HTTP headers if provided
+	// -H can be provided multiple times.
+	// Do this early so we can enforce the special case headers later.
+	if len(cfg.Headers) > 0 {
+		req.Header = validation.ParseHTTPHeaders(cfg.Headers)
+	}
+
+	// Handle basic auth if provided by the user
+	if cfg.BasicAuth != "" {
+		user, pass, err := validation.ParseBasicAuth(cfg.BasicAuth)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(user, pass)
+	}
+
+	// Handle custom host header if provided by the user
+	// Host header has special treatment and is not a traditional header
+	if cfg.Host != "" {
+		req.Host = cfg.Host
+	}
+
+	// Handle a custom user agent if provided by the user
+	// the tool user agent is always appended for server tracability.
+	req.Header.Set(userAgentHeader, strings.TrimSpace(cfg.UserAgent+" vessel/"+cfg.Version))
+	return req, nil
+}
+
+// RequestHooks builds the built in request hooks enabled in the
+// configuration.  The feeder runs first so that the hooks after it, such
+// as the HMAC signature, see the request as it is sent.
+func RequestHooks(cfg *config.Config) ([]hook.RequestHook, error) {
+	var hooks []hook.RequestHook
+	if cfg.Feeder != "" {
+		h, err := hook.Feeder(cfg.Feeder)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	if cfg.CorrelationID != "" {
+		hooks = append(hooks, hook.CorrelationID(cfg.CorrelationID))
+	}
+	if cfg.BearerFile != "" {
+		h, err := hook.BearerFile(cfg.BearerFile)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	if cfg.HMAC != "" {
+		header, secret, ok := strings.Cut(cfg.HMAC, ":")
+		if !ok || header == "" || secret == "" {
+			return nil, errors.New("hmac must be provided as header:secret")
+		}
+		hooks = append(hooks, hook.HMAC(header, secret))
+	}
+	return hooks, nil
+}
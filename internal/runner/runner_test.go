@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/hook"
+)
+
+func TestRequestHooksSignTheFedRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id\n42\n"), 0o600))
+	hooks, err := RequestHooks(&config.Config{Feeder: path, HMAC: "X-Signature:secret"})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost/users/${id}?id=${id}", nil)
+	for _, h := range hooks {
+		require.NoError(t, h.BeforeRequest(r, hook.Info{}))
+	}
+	require.Equal(t, "/users/42?id=42", r.URL.RequestURI())
+	ts, digest, ok := strings.Cut(strings.TrimPrefix(r.Header.Get("X-Signature"), "t="), ",v1=")
+	require.True(t, ok)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("GET\n/users/42?id=42\n" + ts))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), digest)
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		cfg config.Config
+		err string
+	}{
+		"valid":                {cfg: config.Config{Amount: 1}},
+		"no amount":            {cfg: config.Config{}, err: "must not be zero"},
+		"bad endpoint":         {cfg: config.Config{Amount: 1, Endpoint: "nope"}, err: "bad endpoint"},
+		"rate and shape":       {cfg: config.Config{Duration: 1, Rate: 10, Shape: "10:1s"}, err: "mutually exclusive"},
+		"bad arrival process":  {cfg: config.Config{Duration: 1, Rate: 10, Arrival: "nope"}, err: "nope"},
+		"bad think time":       {cfg: config.Config{Amount: 1, ThinkTime: "nope"}, err: "nope"},
+		"missing arrival file": {cfg: config.Config{Duration: 1, Rate: 10, Arrival: "empirical:missing.txt"}, err: "missing.txt"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.cfg.Endpoint == "" {
+				tc.cfg.Endpoint = "http://localhost"
+			}
+			err := Validate(&tc.cfg)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package scenario sends a fixed set of requests, imported from elsewhere
+// such as a HAR file or an OpenAPI specification, either in sequence or as
+// a weighted mix.
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Request describes a request of the scenario.
+type Request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+	// Weight is the relative likelihood of the request being picked by a
+	// Mix.
+	Weight float64
+	// ID identifies the request, such as the operation it exercises, to
+	// those handling its response.  It is carried by the context of every
+	// request built from it.
+	ID string
+}
+
+// idKey is the context key holding the ID of the scenario request a
+// request was built from.
+type idKey struct{}
+
+// ID returns the ID of the scenario request the request carrying the
+// context was built from, if it has one.
+func ID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(idKey{}).(string)
+	return id, ok
+}
+
+// Name identifies the request, matching the name of its endpoint in the
+// results.
+func (r Request) Name() string {
+	return r.Method + " " + r.URL
+}
+
+// Build returns a fresh request carrying the headers of the template,
+// which those of the scenario request take precedence over.  The method
+// and url are expected to have been validated beforehand.
+func (r Request) Build(template *http.Request) *http.Request {
+	ctx := template.Context()
+	if r.ID != "" {
+		ctx = context.WithValue(ctx, idKey{}, r.ID)
+	}
+	request, err := http.NewRequestWithContext(ctx, r.Method, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		panic(err)
+	}
+	request.Header = template.Header.Clone()
+	for name, values := range r.Header {
+		request.Header[name] = values
+	}
+	return request
+}
+
+// Sequence sends the requests in order, repeating them from the beginning
+// once exhausted.
+//
+// Sequence is not safe for concurrent use.
+type Sequence struct {
+	requests []Request
+	next     int
+}
+
+// NewSequence returns a Sequence of the requests.
+func NewSequence(requests []Request) *Sequence {
+	return &Sequence{requests: requests}
+}
+
+// Next implements coordinator.Source.
+func (s *Sequence) Next(template *http.Request) (*http.Request, time.Duration, bool) {
+	r := s.requests[s.next%len(s.requests)]
+	s.next++
+	return r.Build(template), 0, true
+}
+
+// Mix sends requests picked at random in proportion to their weight.
+//
+// Mix is not safe for concurrent use.
+type Mix struct {
+	requests   []Request
+	cumulative []float64
+	rng        *rand.Rand
+}
+
+// NewMix returns a Mix of the requests, at least one of which must have a
+// positive weight.
+func NewMix(requests []Request, rng *rand.Rand) (*Mix, error) {
+	m := &Mix{requests: requests, cumulative: make([]float64, len(requests)), rng: rng}
+	var total float64
+	for i, r := range requests {
+		total += r.Weight
+		m.cumulative[i] = total
+	}
+	if total <= 0 {
+		return nil, errors.New("the requests of a mix must not all weigh zero")
+	}
+	return m, nil
+}
+
+// Next implements coordinator.Source.
+func (m *Mix) Next(template *http.Request) (*http.Request, time.Duration, bool) {
+	target := m.rng.Float64() * m.cumulative[len(m.cumulative)-1]
+	i := sort.SearchFloat64s(m.cumulative, target)
+	// Skip over leading requests weighing zero, which share a cumulative
+	// weight of zero.
+	for m.requests[i].Weight == 0 {
+		i++
+	}
+	return m.requests[i].Build(template), 0, true
+}
@@ -0,0 +1,76 @@
+package scenario
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/distribution"
+)
+
+func TestSequence(t *testing.T) {
+	requests := []Request{
+		{Method: "GET", URL: "https://www.shop.com/", Header: http.Header{"Accept": {"text/html"}}, ID: "home"},
+		{Method: "POST", URL: "https://api.shop.com/basket", Body: []byte(`{"id": 1}`)},
+	}
+	template, err := http.NewRequest(http.MethodGet, "http://ignored", nil)
+	require.NoError(t, err)
+	template.Header.Set("User-Agent", "vessel")
+	template.Header.Set("Accept", "*/*")
+
+	s := NewSequence(requests)
+	var names []string
+	for range 5 {
+		request, at, ok := s.Next(template)
+		require.True(t, ok)
+		assert.Zero(t, at)
+		assert.Equal(t, "vessel", request.Header.Get("User-Agent"))
+		names = append(names, request.Method+" "+request.URL.String())
+		id, _ := ID(request.Context())
+		if request.Method == http.MethodPost {
+			// every request has its own body to read.
+			body, err := io.ReadAll(request.Body)
+			require.NoError(t, err)
+			assert.Equal(t, `{"id": 1}`, string(body))
+			assert.Empty(t, id)
+		} else {
+			assert.Equal(t, "home", id)
+			assert.Equal(t, "text/html", request.Header.Get("Accept"), "the scenario headers take precedence")
+		}
+	}
+	assert.Equal(t, []string{
+		"GET https://www.shop.com/",
+		"POST https://api.shop.com/basket",
+		"GET https://www.shop.com/",
+		"POST https://api.shop.com/basket",
+		"GET https://www.shop.com/",
+	}, names)
+}
+
+func TestMix(t *testing.T) {
+	requests := []Request{
+		{Method: "GET", URL: "https://a.com/never", Weight: 0},
+		{Method: "GET", URL: "https://a.com/often", Weight: 3},
+		{Method: "GET", URL: "https://a.com/rarely", Weight: 1},
+		{Method: "GET", URL: "https://a.com/never-either", Weight: 0},
+	}
+	template, err := http.NewRequest(http.MethodGet, "http://ignored", nil)
+	require.NoError(t, err)
+
+	m, err := NewMix(requests, distribution.NewRand(1, 0))
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	for range 4000 {
+		request, _, ok := m.Next(template)
+		require.True(t, ok)
+		counts[request.URL.Path]++
+	}
+	assert.Len(t, counts, 2)
+	assert.InDelta(t, 3000, counts["/often"], 150)
+	assert.InDelta(t, 1000, counts["/rarely"], 150)
+
+	_, err = NewMix(requests[:1], distribution.NewRand(1, 0))
+	assert.ErrorContains(t, err, "must not all weigh zero")
+}
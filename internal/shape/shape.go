@@ -0,0 +1,215 @@
+// Package shape describes how the target arrival rate of a run varies over
+// time, such as a sudden spike or a daily cycle compressed into minutes, in
+// order to exercise autoscaling.
+package shape
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shape is the target arrival rate (per second) at each point of a run.
+type Shape interface {
+	Rate(elapsed time.Duration) float64
+	// Scale returns the shape with its rates multiplied by the factor.
+	Scale(factor float64) Shape
+	// String returns the specification of the shape, see Parse.
+	String() string
+}
+
+// Spike holds the Base rate except for a burst to the Peak rate which
+// begins At into the run and lasts For.
+type Spike struct {
+	Base, Peak float64
+	At, For    time.Duration
+}
+
+// Rate implements Shape.
+func (s Spike) Rate(elapsed time.Duration) float64 {
+	if elapsed >= s.At && elapsed < s.At+s.For {
+		return s.Peak
+	}
+	return s.Base
+}
+
+// Scale implements Shape.
+func (s Spike) Scale(factor float64) Shape {
+	s.Base, s.Peak = s.Base*factor, s.Peak*factor
+	return s
+}
+
+// String implements Shape.
+func (s Spike) String() string {
+	return fmt.Sprintf("spike:base=%s,peak=%s,at=%s,for=%s", rate(s.Base), rate(s.Peak), s.At, s.For)
+}
+
+// Step begins at the Start rate and increases by Step every interval, up
+// to Max if it is set.
+type Step struct {
+	Start, Step, Max float64
+	Every            time.Duration
+}
+
+// Rate implements Shape.
+func (s Step) Rate(elapsed time.Duration) float64 {
+	rate := s.Start + s.Step*float64(elapsed/s.Every)
+	if s.Max > 0 {
+		rate = math.Min(rate, s.Max)
+	}
+	return rate
+}
+
+// Scale implements Shape.
+func (s Step) Scale(factor float64) Shape {
+	s.Start, s.Step, s.Max = s.Start*factor, s.Step*factor, s.Max*factor
+	return s
+}
+
+// String implements Shape.
+func (s Step) String() string {
+	spec := fmt.Sprintf("step:start=%s,step=%s,every=%s", rate(s.Start), rate(s.Step), s.Every)
+	if s.Max > 0 {
+		spec += ",max=" + rate(s.Max)
+	}
+	return spec
+}
+
+// Sine oscillates between the Min and Max rate once per Period, beginning
+// at the trough.
+type Sine struct {
+	Min, Max float64
+	Period   time.Duration
+}
+
+// Rate implements Shape.
+func (s Sine) Rate(elapsed time.Duration) float64 {
+	phase := 2 * math.Pi * elapsed.Seconds() / s.Period.Seconds()
+	return s.Min + (s.Max-s.Min)*(1-math.Cos(phase))/2
+}
+
+// Scale implements Shape.
+func (s Sine) Scale(factor float64) Shape {
+	s.Min, s.Max = s.Min*factor, s.Max*factor
+	return s
+}
+
+// String implements Shape.
+func (s Sine) String() string {
+	return fmt.Sprintf("sine:min=%s,max=%s,period=%s", rate(s.Min), rate(s.Max), s.Period)
+}
+
+// rate formats a rate in its shortest form.
+func rate(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Parse parses a shape from its specification, the kind followed by its
+// comma separated parameters:
+//
+//	spike:base=100,peak=1000,at=30s,for=10s  peak may be a multiple of base (e.g 10x)
+//	step:start=100,step=50,every=30s,max=500 max is optional
+//	sine:min=100,max=1000,period=2m
+func Parse(spec string) (Shape, error) {
+	kind, args, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "spike":
+		p, err := parameters(spec, args, "base", "peak", "at", "for")
+		if err != nil {
+			return nil, err
+		}
+		s := Spike{Base: p.rate("base"), At: p.duration("at"), For: p.duration("for")}
+		if multiple, ok := strings.CutSuffix(p.values["peak"], "x"); ok {
+			p.values["peak"] = multiple
+			s.Peak = s.Base * p.rate("peak")
+		} else {
+			s.Peak = p.rate("peak")
+		}
+		if err := p.check(s.Base > 0 && s.Peak > 0 && s.For > 0, "base, peak and for must be positive"); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "step":
+		p, err := parameters(spec, args, "start", "step", "every", "max")
+		if err != nil {
+			return nil, err
+		}
+		s := Step{Start: p.rate("start"), Step: p.rate("step"), Every: p.duration("every")}
+		if _, ok := p.values["max"]; ok {
+			s.Max = p.rate("max")
+		}
+		if err := p.check(s.Start > 0 && s.Every > 0, "start and every must be positive"); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "sine":
+		p, err := parameters(spec, args, "min", "max", "period")
+		if err != nil {
+			return nil, err
+		}
+		s := Sine{Min: p.rate("min"), Max: p.rate("max"), Period: p.duration("period")}
+		if err := p.check(s.Max > 0 && s.Max >= s.Min && s.Period > 0, "max and period must be positive and max at least min"); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown shape %q, must be spike, step or sine", kind)
+}
+
+// params are the named parameters of a shape, accumulating the first
+// error encountered whilst reading them.
+type params struct {
+	spec   string
+	values map[string]string
+	err    error
+}
+
+// parameters splits the comma separated key=value parameters, each of
+// which must be one of the given keys.
+func parameters(spec, args string, keys ...string) (*params, error) {
+	p := &params{spec: spec, values: make(map[string]string)}
+	for _, arg := range strings.Split(args, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(arg), "=")
+		if !ok {
+			return nil, fmt.Errorf("shape %q parameter %q must be key=value", spec, arg)
+		}
+		if !slices.Contains(keys, key) {
+			return nil, fmt.Errorf("shape %q has unknown parameter %q, expected %s", spec, key, strings.Join(keys, ", "))
+		}
+		p.values[key] = value
+	}
+	return p, nil
+}
+
+// rate reads a non negative rate.
+func (p *params) rate(key string) float64 {
+	v, err := strconv.ParseFloat(p.values[key], 64)
+	if (err != nil || v < 0) && p.err == nil {
+		p.err = fmt.Errorf("shape %q requires %s to be a non negative rate", p.spec, key)
+	}
+	return v
+}
+
+// duration reads a non negative duration.
+func (p *params) duration(key string) time.Duration {
+	d, err := time.ParseDuration(p.values[key])
+	if (err != nil || d < 0) && p.err == nil {
+		p.err = fmt.Errorf("shape %q requires %s to be a non negative duration", p.spec, key)
+	}
+	return d
+}
+
+// check returns the first error reading the parameters, otherwise an
+// error describing the constraint if it does not hold.
+func (p *params) check(ok bool, constraint string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if !ok {
+		return fmt.Errorf("shape %q is invalid, %s", p.spec, constraint)
+	}
+	return nil
+}
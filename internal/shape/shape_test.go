@@ -0,0 +1,90 @@
+package shape
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		spec string
+		want Shape
+		err  string
+	}{
+		"spike":              {spec: "spike:base=100,peak=1000,at=30s,for=10s", want: Spike{Base: 100, Peak: 1000, At: 30 * time.Second, For: 10 * time.Second}},
+		"spike multiple":     {spec: "spike:base=50, peak=10x, at=1m, for=5s", want: Spike{Base: 50, Peak: 500, At: time.Minute, For: 5 * time.Second}},
+		"step":               {spec: "step:start=100,step=50,every=30s", want: Step{Start: 100, Step: 50, Every: 30 * time.Second}},
+		"step with max":      {spec: "step:start=100,step=50,every=30s,max=300", want: Step{Start: 100, Step: 50, Every: 30 * time.Second, Max: 300}},
+		"sine":               {spec: "sine:min=10,max=100,period=2m", want: Sine{Min: 10, Max: 100, Period: 2 * time.Minute}},
+		"unknown shape":      {spec: "ramp:from=1,to=2", err: "unknown shape"},
+		"unknown parameter":  {spec: "sine:min=1,max=2,period=1m,phase=1", err: `unknown parameter "phase"`},
+		"missing parameter":  {spec: "spike:base=100,peak=1000,at=30s", err: "for to be a non negative duration"},
+		"not key value":      {spec: "sine:1,2,1m", err: "must be key=value"},
+		"bad rate":           {spec: "step:start=lots,step=1,every=1s", err: "start to be a non negative rate"},
+		"zero base":          {spec: "spike:base=0,peak=10,at=1s,for=1s", err: "must be positive"},
+		"zero step interval": {spec: "step:start=1,step=1,every=0s", err: "must be positive"},
+		"inverted sine":      {spec: "sine:min=10,max=5,period=1m", err: "max at least min"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(tc.spec)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				assert.Nil(t, got)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRate(t *testing.T) {
+	spike := Spike{Base: 100, Peak: 1000, At: 30 * time.Second, For: 10 * time.Second}
+	step := Step{Start: 100, Step: 50, Every: 30 * time.Second, Max: 180}
+	sine := Sine{Min: 10, Max: 110, Period: time.Minute}
+
+	tests := map[string]struct {
+		shape   Shape
+		elapsed time.Duration
+		want    float64
+	}{
+		"spike before":          {shape: spike, elapsed: 29 * time.Second, want: 100},
+		"spike during":          {shape: spike, elapsed: 30 * time.Second, want: 1000},
+		"spike after":           {shape: spike, elapsed: 40 * time.Second, want: 100},
+		"step first":            {shape: step, elapsed: 29 * time.Second, want: 100},
+		"step second":           {shape: step, elapsed: 30 * time.Second, want: 150},
+		"step capped":           {shape: step, elapsed: time.Hour, want: 180},
+		"sine trough":           {shape: sine, elapsed: 0, want: 10},
+		"sine midway":           {shape: sine, elapsed: 15 * time.Second, want: 60},
+		"sine peak":             {shape: sine, elapsed: 30 * time.Second, want: 110},
+		"sine next period":      {shape: sine, elapsed: time.Minute, want: 10},
+		"step uncapped":         {shape: Step{Start: 1, Step: 1, Every: time.Second}, elapsed: time.Minute, want: 61},
+		"spike at the very end": {shape: spike, elapsed: 40*time.Second - 1, want: 1000},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.InDelta(t, tc.want, tc.shape.Rate(tc.elapsed), 1e-9)
+		})
+	}
+}
+
+func TestScaleRoundTrips(t *testing.T) {
+	for _, spec := range []string{
+		"spike:base=100,peak=1000,at=30s,for=10s",
+		"step:start=100,step=50,every=30s,max=300",
+		"step:start=100,step=50,every=30s",
+		"sine:min=10,max=100,period=2m0s",
+	} {
+		s, err := Parse(spec)
+		require.NoError(t, err)
+		assert.Equal(t, spec, s.String())
+		scaled, err := Parse(s.Scale(0.5).String())
+		require.NoError(t, err)
+		assert.Equal(t, s.Scale(0.5), scaled)
+		assert.InDelta(t, s.Rate(time.Second)/2, scaled.Rate(time.Second), 1e-9)
+	}
+}
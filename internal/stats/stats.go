@@ -1,6 +1,11 @@
 package stats
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
 
 type ReusedState = int64
 
@@ -14,13 +19,84 @@ const (
 // collector implementation.
 type Stats struct {
 	Err           error
+	Worker        int
+	Endpoint      string
+	Began         time.Time
 	Latency       time.Duration
 	StatusCode    int
 	TimeOnDns     time.Duration
 	TimeOnTls     time.Duration
 	TimeOnConnect time.Duration
 	TimeOnConn    time.Duration
-	BytesSent     int64
-	BytesReceived int64
-	ReusedConn    ReusedState
+	// TimeToFirstByte is measured from the request being dispatched.
+	TimeToFirstByte time.Duration
+	BytesSent       int64
+	BytesReceived   int64
+	ReusedConn      ReusedState
+	// FailedChecks holds the names of the response checks that the
+	// response failed, if any.
+	FailedChecks []string
+	// Warmup is set for requests dispatched during the warm-up, which are
+	// excluded from the results.
+	Warmup bool
+	// Delay is how long after it was due a scheduled arrival was sent,
+	// having waited for a free worker.  Began is when it was due, so that
+	// the wait is part of its latency.
+	Delay time.Duration
+	// Dropped is the number of scheduled arrivals dropped, rather than
+	// sent, as every worker was busy.  It is set on a record of its own
+	// in place of a request.
+	Dropped int64
+	// Handshake is the duration of the WebSocket opening handshake, set
+	// on the message which opened the connection.
+	Handshake time.Duration
+	// Received is the number of WebSocket messages received alongside
+	// the message, including its reply.
+	Received int64
+	// CloseCode is the code of a WebSocket connection closed by the
+	// server, set on the message which observed the close.
+	CloseCode int
+	// Events is the number of events of a streamed response.
+	Events int64
+	// FirstEvent is the time to the first event of a streamed response,
+	// measured from the request being dispatched.
+	FirstEvent time.Duration
+	// EventGaps holds the time between consecutive events of a streamed
+	// response in microseconds, nil for fewer than two events.
+	EventGaps *hdrhistogram.Histogram
+	// StreamTimedOut and StreamTruncated are set on a streamed response
+	// cut short by the stream timeout (or the end of the run) or maximum
+	// number of events.
+	StreamTimedOut  bool
+	StreamTruncated bool
+}
+
+// warmupKey is the context key marking warm-up requests.
+type warmupKey struct{}
+
+// WithWarmup marks requests carrying the returned context as warm-up.
+func WithWarmup(ctx context.Context) context.Context {
+	return context.WithValue(ctx, warmupKey{}, true)
+}
+
+// IsWarmup reports whether the context marks a warm-up request.
+func IsWarmup(ctx context.Context) bool {
+	warmup, _ := ctx.Value(warmupKey{}).(bool)
+	return warmup
+}
+
+// dueKey is the context key holding when a scheduled arrival is due.
+type dueKey struct{}
+
+// WithDue marks requests carrying the returned context as scheduled
+// arrivals due at the given time.
+func WithDue(ctx context.Context, due time.Time) context.Context {
+	return context.WithValue(ctx, dueKey{}, due)
+}
+
+// Due returns when the request carrying the context is due, if it is a
+// scheduled arrival.
+func Due(ctx context.Context) (time.Time, bool) {
+	due, ok := ctx.Value(dueKey{}).(time.Time)
+	return due, ok
 }
@@ -0,0 +1,153 @@
+package sweep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// achievedRatio is the fraction of the target rate a trial must achieve
+// for the rate to be considered sustained.  Falling short indicates the
+// target (or too few workers) could not keep up regardless of latency.
+const achievedRatio = 0.95
+
+// WithConfirmations sets how many times the highest passing value is
+// repeated, each of which must also pass, before a seek concludes.
+// Defaults to one.
+func WithConfirmations(n int) Option {
+	return func(s *Sweeper) {
+		s.confirmations = max(0, n)
+	}
+}
+
+// Seek is the outcome of seeking the highest value which meets the SLO.
+type Seek struct {
+	Mode string
+	// Value is the highest value which met the SLO in every trial, zero if
+	// even the lower bound did not.
+	Value int
+	// Point is the first trial at Value.
+	Point Point
+	// Trials holds every trial in the order they ran.
+	Trials []Point
+	// Capped reports whether the upper bound met the SLO, in which case
+	// the true limit is higher still.
+	Capped bool
+}
+
+// Seek binary searches between low and high for the highest value which
+// meets the SLO, concluding once the remaining range is within precision.
+// The highest passing value is then confirmed by repeating it; should a
+// confirmation fail the search continues beneath it.
+//
+// For rate sweeps a trial must also achieve (nearly) the requested rate.
+// If ctx is cancelled the trials completed prior are returned alongside
+// the error.
+func (s *Sweeper) Seek(ctx context.Context, low, high, precision int) (*Seek, error) {
+	if len(s.slo) == 0 {
+		return nil, errors.New("seeking requires an SLO")
+	}
+	if low < 1 || high <= low {
+		return nil, fmt.Errorf("invalid seek range %d..%d", low, high)
+	}
+	precision = max(1, precision)
+
+	seek := &Seek{Mode: s.mode}
+	passed := make(map[int]Point)
+	trial := func(value int) (bool, error) {
+		p, err := s.run(ctx, value)
+		if err != nil {
+			return false, err
+		}
+		// A trial cut short by cancellation is not representative.
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		if s.mode == Rate {
+			p.Shortfall = p.RPS < achievedRatio*float64(p.Value)
+		}
+		seek.Trials = append(seek.Trials, p)
+		if !p.Passed() {
+			return false, nil
+		}
+		if _, ok := passed[value]; !ok {
+			passed[value] = p
+		}
+		return true, nil
+	}
+
+	ok, err := trial(low)
+	if err != nil || !ok {
+		return seek, err
+	}
+	lo, hi := low, high
+	if ok, err = trial(high); err != nil {
+		return seek, err
+	}
+	if ok {
+		lo, seek.Capped = high, true
+	}
+	for {
+		for hi-lo > precision {
+			mid := lo + (hi-lo)/2
+			ok, err := trial(mid)
+			if err != nil {
+				return seek, err
+			}
+			if ok {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		confirmed := true
+		for range s.confirmations {
+			if ok, err := trial(lo); err != nil {
+				return seek, err
+			} else if !ok {
+				confirmed = false
+				break
+			}
+		}
+		if confirmed {
+			seek.Value, seek.Point = lo, passed[lo]
+			return seek, nil
+		}
+		// The value is not sustainable, search again beneath it.
+		delete(passed, lo)
+		seek.Capped = false
+		if lo == low {
+			return seek, nil
+		}
+		hi, lo = lo, low
+	}
+}
+
+// WriteSeek writes every trial and the outcome of the seek.
+func WriteSeek(w io.Writer, s *Seek) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "trial\t%s\trequests\trps\terrors\tp50\tp90\tp99\tmax\tslo\n", s.Mode)
+	for i, p := range s.Trials {
+		slo := "✓"
+		if !p.Passed() {
+			slo = "✗ " + p.failure()
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%.2f\t%.2f%%\t%s\t%s\t%s\t%s\t%s\n",
+			i+1, p.Value, p.Requests, p.RPS, p.ErrorRate*100, millis(p.P50), millis(p.P90), millis(p.P99), millis(p.Max), slo)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	switch {
+	case s.Value == 0:
+		_, err := fmt.Fprintf(w, "\nNo %s within the range met the SLO\n", s.Mode)
+		return err
+	case s.Capped:
+		_, err := fmt.Fprintf(w, "\nThe upper bound %s=%d met the SLO, the limit is higher still\n", s.Mode, s.Value)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nMaximum sustainable %s: %d (%.2f requests/second, p99 %s)\n", s.Mode, s.Value, s.Point.RPS, millis(s.Point.P99))
+	return err
+}
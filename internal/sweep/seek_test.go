@@ -0,0 +1,109 @@
+package sweep
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/threshold"
+)
+
+// fakeRun simulates a target which sustains up to limit, other than the
+// unstable values which pass only their first trial.
+func fakeRun(limit int, unstable map[int]bool, values *[]int) func(context.Context, int) (Point, error) {
+	seen := make(map[int]bool)
+	return func(_ context.Context, value int) (Point, error) {
+		*values = append(*values, value)
+		p := Point{Value: value, RPS: float64(value)}
+		if value > limit || (unstable[value] && seen[value]) {
+			p.Violations = []threshold.Violation{{Actual: "250ms"}}
+		}
+		seen[value] = true
+		return p, nil
+	}
+}
+
+func TestSeek(t *testing.T) {
+	slo, err := threshold.Parse("p99<200ms")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		limit     int
+		unstable  map[int]bool
+		low, high int
+		precision int
+		value     int
+		capped    bool
+	}{
+		"finds the limit within the precision": {
+			limit: 730, low: 10, high: 1000, precision: 10, value: 728,
+		},
+		"exact with a precision of one": {
+			limit: 730, low: 10, high: 1000, precision: 1, value: 730,
+		},
+		"upper bound is sustainable": {
+			limit: 5000, low: 10, high: 1000, precision: 10, value: 1000, capped: true,
+		},
+		"lower bound is not sustainable": {
+			limit: 5, low: 10, high: 1000, precision: 10, value: 0,
+		},
+		"failed confirmation searches beneath": {
+			limit: 730, unstable: map[int]bool{728: true}, low: 10, high: 1000, precision: 10, value: 722,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := New(&config.Config{}, Rate, WithSLO(slo))
+			require.NoError(t, err)
+			var values []int
+			s.run = fakeRun(tc.limit, tc.unstable, &values)
+
+			seek, err := s.Seek(context.Background(), tc.low, tc.high, tc.precision)
+			require.NoError(t, err)
+			assert.Equal(t, tc.value, seek.Value)
+			assert.Equal(t, tc.capped, seek.Capped)
+			assert.Len(t, seek.Trials, len(values))
+			if tc.value > 0 {
+				assert.Equal(t, tc.value, values[len(values)-1], "the result must be confirmed")
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, WriteSeek(&buf, seek))
+		})
+	}
+}
+
+func TestSeekRequiresAchievedRate(t *testing.T) {
+	slo, err := threshold.Parse("p99<200ms")
+	require.NoError(t, err)
+	s, err := New(&config.Config{}, Rate, WithSLO(slo), WithConfirmations(0))
+	require.NoError(t, err)
+	// The target never exceeds 500 requests/second but latency is fine.
+	s.run = func(_ context.Context, value int) (Point, error) {
+		return Point{Value: value, RPS: min(500, float64(value))}, nil
+	}
+	seek, err := s.Seek(context.Background(), 100, 1000, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 526, seek.Value)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSeek(&buf, seek))
+	assert.Contains(t, buf.String(), "✗ 500.00 of 1000 requests/second achieved")
+}
+
+func TestSeekValidates(t *testing.T) {
+	s, err := New(&config.Config{}, Rate)
+	require.NoError(t, err)
+	_, err = s.Seek(context.Background(), 1, 10, 1)
+	assert.ErrorContains(t, err, "requires an SLO")
+
+	slo, err := threshold.Parse("p99<200ms")
+	require.NoError(t, err)
+	s, err = New(&config.Config{}, Rate, WithSLO(slo))
+	require.NoError(t, err)
+	_, err = s.Seek(context.Background(), 10, 10, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,312 @@
+// Package sweep runs a load test repeatedly, varying the concurrency or the
+// arrival rate, either stepping across a range to chart how throughput and
+// latency respond to load and find the point at which the target saturates,
+// or seeking the highest value which sustainably meets an SLO.
+package sweep
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/runner"
+	"github.com/symonk/vessel/internal/threshold"
+)
+
+// Modes which can be swept.
+const (
+	// Concurrency steps the number of workers.
+	Concurrency = "concurrency"
+	// Rate steps the arrival rate in requests per second.
+	Rate = "rate"
+)
+
+// Point is the outcome of a single step of the sweep.
+type Point struct {
+	// Value is the concurrency or rate of the step.
+	Value     int
+	Requests  int64
+	RPS       float64
+	ErrorRate float64
+	P50       int64
+	P90       int64
+	P99       int64
+	Max       int64
+	// Violations holds the conditions of the SLO the step breached.
+	Violations []threshold.Violation
+	// Shortfall is set when a seek trial achieved too little of the
+	// requested rate.
+	Shortfall bool
+}
+
+// Passed reports whether the step met the SLO.
+func (p Point) Passed() bool {
+	return len(p.Violations) == 0 && !p.Shortfall
+}
+
+// failure describes why the step did not pass.
+func (p Point) failure() string {
+	if len(p.Violations) > 0 {
+		return p.Violations[0].String()
+	}
+	return fmt.Sprintf("%.2f of %d requests/second achieved", p.RPS, p.Value)
+}
+
+// Sweep is the outcome of every step and the saturation point.
+type Sweep struct {
+	Mode   string
+	Points []Point
+	// Knee is the index of the last point before throughput stopped
+	// increasing or the SLO was breached, -1 if even the first step
+	// breached the SLO.
+	Knee int
+	// Reason describes why the knee was identified, empty when every step
+	// improved throughput and met the SLO.
+	Reason string
+}
+
+// Option is a functional option for the Sweeper.
+type Option func(*Sweeper)
+
+// WithSLO evaluates every step against the expression, the first step to
+// breach it marks the knee.
+func WithSLO(slo threshold.Expression) Option {
+	return func(s *Sweeper) {
+		s.slo = slo
+	}
+}
+
+// WithMinGain sets the relative increase in throughput, as a fraction,
+// below which a step is considered to no longer improve throughput.
+// Defaults to 0.05 (5%).
+func WithMinGain(gain float64) Option {
+	return func(s *Sweeper) {
+		s.minGain = gain
+	}
+}
+
+// WithRunnerOptions applies the options to the run of every step.
+func WithRunnerOptions(options ...runner.Option) Option {
+	return func(s *Sweeper) {
+		s.options = append(s.options, options...)
+	}
+}
+
+// WithStepFunc calls fn with every point as soon as its step finishes.
+func WithStepFunc(fn func(Point)) Option {
+	return func(s *Sweeper) {
+		s.onStep = fn
+	}
+}
+
+// Sweeper runs a load test once per step.
+type Sweeper struct {
+	cfg           *config.Config
+	mode          string
+	slo           threshold.Expression
+	minGain       float64
+	confirmations int
+	options       []runner.Option
+	onStep        func(Point)
+	// run runs a single step, replaceable for testing.
+	run func(context.Context, int) (Point, error)
+}
+
+// New instantiates a new Sweeper which runs the load test described by cfg,
+// applying the value of each step as the concurrency or rate depending on
+// the mode.
+func New(cfg *config.Config, mode string, options ...Option) (*Sweeper, error) {
+	if mode != Concurrency && mode != Rate {
+		return nil, fmt.Errorf("unknown sweep mode %q, must be %s or %s", mode, Concurrency, Rate)
+	}
+	s := &Sweeper{cfg: cfg, mode: mode, minGain: 0.05, confirmations: 1}
+	s.run = s.step
+	for _, opt := range options {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Run runs every step in order.  If ctx is cancelled, or a step fails, the
+// steps completed prior are returned alongside the error.  A step cut short
+// by cancellation is not representative and is discarded.
+func (s *Sweeper) Run(ctx context.Context, steps []int) (*Sweep, error) {
+	if len(steps) == 0 {
+		return nil, errors.New("a sweep requires at least one step")
+	}
+	for _, step := range steps {
+		if step < 1 {
+			return nil, fmt.Errorf("sweep step %d must be positive", step)
+		}
+	}
+	sweep := &Sweep{Mode: s.mode}
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			sweep.Knee, sweep.Reason = Knee(sweep.Points, s.minGain)
+			return sweep, err
+		}
+		point, err := s.run(ctx, step)
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			sweep.Knee, sweep.Reason = Knee(sweep.Points, s.minGain)
+			return sweep, err
+		}
+		if s.onStep != nil {
+			s.onStep(point)
+		}
+		sweep.Points = append(sweep.Points, point)
+	}
+	sweep.Knee, sweep.Reason = Knee(sweep.Points, s.minGain)
+	return sweep, nil
+}
+
+// step runs the load test once at the given value.
+func (s *Sweeper) step(ctx context.Context, value int) (Point, error) {
+	cfg := *s.cfg
+	switch s.mode {
+	case Concurrency:
+		cfg.Concurrency = value
+	case Rate:
+		cfg.Rate = float64(value)
+	}
+	result, err := runner.Run(ctx, &cfg, s.options...)
+	if err != nil {
+		return Point{}, fmt.Errorf("step %s=%d: %w", s.mode, value, err)
+	}
+	point := Point{
+		Value:     value,
+		Requests:  result.Requests,
+		RPS:       result.RPS,
+		ErrorRate: result.ErrorRate,
+		P50:       result.Latency.P50,
+		P90:       result.Latency.P90,
+		P99:       result.Latency.P99,
+		Max:       result.Latency.Max,
+	}
+	if point.Violations, err = s.slo.Evaluate(result); err != nil {
+		return Point{}, err
+	}
+	return point, nil
+}
+
+// Knee returns the index of the saturation point: the last point before
+// either the SLO was breached or throughput increased by less than
+// minGain over the best throughput so far.  The final index is returned
+// with no reason if neither occurred.
+func Knee(points []Point, minGain float64) (int, string) {
+	best := 0.0
+	for i, p := range points {
+		if !p.Passed() {
+			return i - 1, fmt.Sprintf("SLO breached at %d: %s", p.Value, p.failure())
+		}
+		if i > 0 && p.RPS < best*(1+minGain) {
+			return i - 1, fmt.Sprintf("throughput gained %.1f%% at %d", gain(best, p.RPS)*100, p.Value)
+		}
+		best = max(best, p.RPS)
+	}
+	return len(points) - 1, ""
+}
+
+// gain returns the relative increase from prev to next.
+func gain(prev, next float64) float64 {
+	if prev == 0 {
+		return 0
+	}
+	return (next - prev) / prev
+}
+
+// Range returns the steps from start to end (inclusive) in increments of
+// step.
+func Range(start, end, step int) ([]int, error) {
+	if start < 1 || end < start || step < 1 {
+		return nil, fmt.Errorf("invalid sweep range %d..%d in steps of %d", start, end, step)
+	}
+	var steps []int
+	for v := start; v <= end; v += step {
+		steps = append(steps, v)
+	}
+	return steps, nil
+}
+
+// WriteTable writes the sweep as a human readable table, marking the knee.
+func WriteTable(w io.Writer, s *Sweep) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\trequests\trps\tgain\terrors\tp50\tp90\tp99\tmax\tslo\t\n", s.Mode)
+	prev := 0.0
+	for i, p := range s.Points {
+		slo := "✓"
+		if !p.Passed() {
+			slo = "✗"
+		}
+		knee := ""
+		if i == s.Knee && s.Reason != "" {
+			knee = "← knee"
+		}
+		growth := "-"
+		if i > 0 {
+			growth = fmt.Sprintf("%+.1f%%", gain(prev, p.RPS)*100)
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%.2f\t%s\t%.2f%%\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			p.Value, p.Requests, p.RPS, growth, p.ErrorRate*100, millis(p.P50), millis(p.P90), millis(p.P99), millis(p.Max), slo, knee)
+		prev = max(prev, p.RPS)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	switch {
+	case len(s.Points) == 0:
+		return nil
+	case s.Knee < 0:
+		_, err := fmt.Fprintf(w, "\nNo step met the SLO (%s)\n", s.Reason)
+		return err
+	case s.Reason != "":
+		p := s.Points[s.Knee]
+		_, err := fmt.Fprintf(w, "\nSaturation at %s=%d: %.2f requests/second, p99 %s (%s)\n", s.Mode, p.Value, p.RPS, millis(p.P99), s.Reason)
+		return err
+	}
+	_, err := fmt.Fprintln(w, "\nNo saturation found, throughput increased at every step")
+	return err
+}
+
+// WriteCSV writes the latency and throughput curve as CSV to path.
+func WriteCSV(path string, s *Sweep) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{s.Mode, "requests", "rps", "error_rate", "p50_ms", "p90_ms", "p99_ms", "max_ms", "slo_passed", "knee"})
+	for i, p := range s.Points {
+		_ = w.Write([]string{
+			strconv.Itoa(p.Value),
+			strconv.FormatInt(p.Requests, 10),
+			strconv.FormatFloat(p.RPS, 'f', 2, 64),
+			strconv.FormatFloat(p.ErrorRate, 'f', 4, 64),
+			strconv.FormatFloat(float64(p.P50)/1000, 'f', 3, 64),
+			strconv.FormatFloat(float64(p.P90)/1000, 'f', 3, 64),
+			strconv.FormatFloat(float64(p.P99)/1000, 'f', 3, 64),
+			strconv.FormatFloat(float64(p.Max)/1000, 'f', 3, 64),
+			strconv.FormatBool(p.Passed()),
+			strconv.FormatBool(i == s.Knee && s.Reason != ""),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// millis formats a microsecond value as milliseconds.
+func millis(us int64) string {
+	return strconv.FormatFloat(float64(us)/1000, 'f', 2, 64) + "ms"
+}
@@ -0,0 +1,166 @@
+package sweep
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/threshold"
+)
+
+func TestKnee(t *testing.T) {
+	breach := []threshold.Violation{{Actual: "300ms"}}
+	tests := map[string]struct {
+		points []Point
+		knee   int
+		reason string
+	}{
+		"throughput increases throughout": {
+			points: []Point{{Value: 1, RPS: 100}, {Value: 2, RPS: 190}, {Value: 4, RPS: 350}},
+			knee:   2,
+		},
+		"throughput plateaus": {
+			points: []Point{{Value: 1, RPS: 100}, {Value: 2, RPS: 190}, {Value: 4, RPS: 195}, {Value: 8, RPS: 400}},
+			knee:   1,
+			reason: "throughput gained 2.6% at 4",
+		},
+		"throughput drops": {
+			points: []Point{{Value: 1, RPS: 100}, {Value: 2, RPS: 50}},
+			knee:   0,
+			reason: "throughput gained -50.0% at 2",
+		},
+		"slo breached": {
+			points: []Point{{Value: 1, RPS: 100}, {Value: 2, RPS: 200}, {Value: 4, RPS: 400, Violations: breach}},
+			knee:   1,
+			reason: "SLO breached at 4",
+		},
+		"slo breached from the first step": {
+			points: []Point{{Value: 1, RPS: 100, Violations: breach}},
+			knee:   -1,
+			reason: "SLO breached at 1",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			knee, reason := Knee(tc.points, 0.05)
+			assert.Equal(t, tc.knee, knee)
+			if tc.reason == "" {
+				assert.Empty(t, reason)
+			} else {
+				assert.True(t, strings.HasPrefix(reason, tc.reason), reason)
+			}
+		})
+	}
+}
+
+func TestRange(t *testing.T) {
+	steps, err := Range(10, 45, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int{10, 20, 30, 40}, steps)
+
+	_, err = Range(10, 5, 1)
+	assert.Error(t, err)
+	_, err = Range(0, 5, 1)
+	assert.Error(t, err)
+}
+
+func TestNewValidates(t *testing.T) {
+	_, err := New(&config.Config{}, "users")
+	assert.ErrorContains(t, err, "unknown sweep mode")
+	s, err := New(&config.Config{}, Rate)
+	require.NoError(t, err)
+	_, err = s.Run(context.Background(), nil)
+	assert.Error(t, err)
+	_, err = s.Run(context.Background(), []int{0})
+	assert.Error(t, err)
+}
+
+func TestSweepRunsEveryStep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cfg := &config.Config{Endpoint: server.URL, Method: http.MethodGet, Amount: 20, Concurrency: 1, MaxConnections: 10}
+	var seen []int
+	s, err := New(cfg, Concurrency,
+		WithSLO(threshold.Expression{}),
+		WithStepFunc(func(p Point) { seen = append(seen, p.Value) }),
+	)
+	require.NoError(t, err)
+	result, err := s.Run(context.Background(), []int{1, 2, 4})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 4}, seen)
+	require.Len(t, result.Points, 3)
+	for _, p := range result.Points {
+		assert.Equal(t, int64(20), p.Requests)
+		assert.True(t, p.Passed())
+	}
+	assert.Equal(t, 1, cfg.Concurrency, "the configuration must not be modified")
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteTable(&buf, result))
+	assert.Contains(t, buf.String(), "concurrency")
+
+	path := filepath.Join(t.TempDir(), "sweep.csv")
+	require.NoError(t, WriteCSV(path, result))
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(b)), "\n"), 4)
+}
+
+func TestSweepKeepsTheStepsCompletedPrior(t *testing.T) {
+	tests := map[string]struct {
+		run func(cancel context.CancelFunc) func(context.Context, int) (Point, error)
+		err string
+	}{
+		"cancelled during a step": {
+			run: func(cancel context.CancelFunc) func(context.Context, int) (Point, error) {
+				return func(_ context.Context, value int) (Point, error) {
+					if value == 3 {
+						// Cut short, the step saw a fraction of the load.
+						cancel()
+						return Point{Value: value, RPS: 1}, nil
+					}
+					return Point{Value: value, RPS: float64(value * 100)}, nil
+				}
+			},
+			err: context.Canceled.Error(),
+		},
+		"step failing": {
+			run: func(context.CancelFunc) func(context.Context, int) (Point, error) {
+				return func(_ context.Context, value int) (Point, error) {
+					if value == 3 {
+						return Point{}, errors.New("step failed")
+					}
+					return Point{Value: value, RPS: float64(value * 100)}, nil
+				}
+			},
+			err: "step failed",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var seen []int
+			s, err := New(&config.Config{}, Concurrency, WithStepFunc(func(p Point) { seen = append(seen, p.Value) }))
+			require.NoError(t, err)
+			s.run = tc.run(cancel)
+			result, err := s.Run(ctx, []int{1, 2, 3, 4})
+			assert.ErrorContains(t, err, tc.err)
+			require.NotNil(t, result)
+			require.Len(t, result.Points, 2)
+			assert.Equal(t, []int{1, 2}, seen)
+			assert.Equal(t, 1, result.Knee)
+			assert.Empty(t, result.Reason)
+		})
+	}
+}
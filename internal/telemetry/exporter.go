@@ -0,0 +1,253 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// batchSize is the maximum number of spans sent in a single export.
+	batchSize = 512
+	// flushInterval is the maximum time a span waits before being exported.
+	flushInterval = 2 * time.Second
+	// queueSize is the number of spans buffered before new spans are dropped
+	// rather than slowing down the workers.
+	queueSize = 8192
+)
+
+// Exporter batches spans and exports them to an OTLP/HTTP collector using
+// the JSON encoding.  Spans are dropped, rather than blocking the caller,
+// if the collector cannot keep up.
+//
+// Exporter is safe for parallel use.
+type Exporter struct {
+	endpoint string
+	service  string
+	version  string
+	client   *http.Client
+	queue    chan *Span
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+	dropped  int64
+	err      error
+}
+
+// NewExporter instantiates a new Exporter sending spans to the traces
+// endpoint of the collector (e.g http://localhost:4318/v1/traces) and
+// returns a ptr to it.  The caller is responsible for calling Shutdown.
+func NewExporter(endpoint, version string) *Exporter {
+	e := &Exporter{
+		endpoint: endpoint,
+		service:  "vessel",
+		version:  version,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		queue:    make(chan *Span, queueSize),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Export enqueues a span for export.
+func (e *Exporter) Export(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		e.mu.Lock()
+		e.dropped++
+		e.mu.Unlock()
+	}
+}
+
+// Shutdown flushes any buffered spans and stops the exporter.  The first
+// error encountered exporting spans (if any) is returned.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.queue) })
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil && e.dropped > 0 {
+		return fmt.Errorf("dropped %d spans, the collector could not keep up", e.dropped)
+	}
+	return e.err
+}
+
+// run batches spans from the queue until it is closed.
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			e.mu.Lock()
+			if e.err == nil {
+				e.err = err
+			}
+			e.mu.Unlock()
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) == batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// send posts a batch of spans to the collector.
+func (e *Exporter) send(spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to export spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unable to export spans: collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON wire types, see opentelemetry-proto's trace.proto.  Ids are
+// hex encoded and 64 bit integers are strings as required by the spec.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []jsonSpan `json:"spans"`
+	}
+	scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	jsonSpan struct {
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		Name              string      `json:"name"`
+		Kind              int         `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []keyValue  `json:"attributes,omitempty"`
+		Events            []jsonEvent `json:"events,omitempty"`
+		Status            jsonStatus  `json:"status"`
+	}
+	jsonEvent struct {
+		TimeUnixNano string `json:"timeUnixNano"`
+		Name         string `json:"name"`
+	}
+	jsonStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// encode converts spans into an OTLP export request.
+func (e *Exporter) encode(spans []*Span) exportRequest {
+	out := make([]jsonSpan, 0, len(spans))
+	for _, s := range spans {
+		js := jsonSpan{
+			TraceID:           hex.EncodeToString(s.Context.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.Context.SpanID[:]),
+			Name:              s.Name,
+			Kind:              SpanKindClient,
+			StartTimeUnixNano: nanos(s.Start),
+			EndTimeUnixNano:   nanos(s.End),
+			Attributes:        attributes(s.Attributes),
+			Status:            jsonStatus{Code: s.Status, Message: s.Message},
+		}
+		for _, ev := range s.Events {
+			js.Events = append(js.Events, jsonEvent{TimeUnixNano: nanos(ev.Time), Name: ev.Name})
+		}
+		out = append(out, js)
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: attributes([]Attribute{
+			{Key: "service.name", Value: e.service},
+			{Key: "service.version", Value: e.version},
+		})},
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: e.service, Version: e.version},
+			Spans: out,
+		}},
+	}}}
+}
+
+// attributes converts attributes into their OTLP representation.
+func attributes(attrs []Attribute) []keyValue {
+	out := make([]keyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v anyValue
+		switch value := a.Value.(type) {
+		case string:
+			v.StringValue = &value
+		case bool:
+			v.BoolValue = &value
+		case int:
+			s := strconv.Itoa(value)
+			v.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(value, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+		out = append(out, keyValue{Key: a.Key, Value: v})
+	}
+	return out
+}
+
+// nanos formats t as unix nanoseconds.
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
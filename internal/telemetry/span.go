@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"encoding/hex"
+	"math/rand/v2"
+	"time"
+)
+
+// TraceparentHeader is the W3C trace context propagation header.
+const TraceparentHeader = "traceparent"
+
+// SpanKindClient is the OTLP span kind of an outgoing request.
+const SpanKindClient = 3
+
+// OTLP status codes.
+const (
+	StatusUnset = 0
+	StatusOk    = 1
+	StatusError = 2
+)
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// NewSpanContext generates a new root span context with random ids.
+func NewSpanContext(sampled bool) SpanContext {
+	var sc SpanContext
+	fill(sc.TraceID[:])
+	fill(sc.SpanID[:])
+	sc.Sampled = sampled
+	return sc
+}
+
+// Traceparent returns the W3C traceparent header value for the span.
+func (s SpanContext) Traceparent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(s.TraceID[:]) + "-" + hex.EncodeToString(s.SpanID[:]) + "-" + flags
+}
+
+// Attribute is a key value pair attached to a span or event.  Value must
+// be a string, bool, int, int64 or float64.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Event is a timestamped annotation on a span.
+type Event struct {
+	Name string
+	Time time.Time
+}
+
+// Span is a single client request.
+type Span struct {
+	Context    SpanContext
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Events     []Event
+	Status     int
+	Message    string
+}
+
+// AddEvent annotates the span with a named event, zero times are ignored
+// so that phases which did not occur (e.g. DNS on a reused connection) are
+// omitted.
+func (s *Span) AddEvent(name string, at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	s.Events = append(s.Events, Event{Name: name, Time: at})
+}
+
+// SetAttributes appends attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	s.Attributes = append(s.Attributes, attrs...)
+}
+
+// fill populates b with random bytes, ensuring it is never all zeros
+// which is an invalid trace/span id.
+func fill(b []byte) {
+	for {
+		for i := range b {
+			b[i] = byte(rand.Uint32())
+		}
+		for _, v := range b {
+			if v != 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Tracer decides which requests are sampled, propagates the W3C trace
+// context to the server and hands finished spans to the exporter.
+//
+// Tracer is safe for parallel use.
+type Tracer struct {
+	exporter *Exporter
+	rate     float64
+}
+
+// NewTracer instantiates a new Tracer and returns a ptr to it.  Requests
+// are sampled at the given rate (0.0 - 1.0).  When exporter is nil the
+// trace context is propagated but spans are not exported.
+func NewTracer(exporter *Exporter, rate float64) *Tracer {
+	return &Tracer{
+		exporter: exporter,
+		rate:     min(max(rate, 0), 1),
+	}
+}
+
+// Start sets the traceparent header on the request and returns the span
+// for the request.  The returned span is nil if the request was not
+// sampled, or there is no exporter to export it to.
+func (t *Tracer) Start(request *http.Request) *Span {
+	if t == nil {
+		return nil
+	}
+	sc := NewSpanContext(t.rate >= 1 || rand.Float64() < t.rate)
+	request.Header.Set(TraceparentHeader, sc.Traceparent())
+	if !sc.Sampled || t.exporter == nil {
+		return nil
+	}
+	return &Span{
+		Context: sc,
+		Name:    request.Method,
+		Start:   time.Now(),
+		Attributes: []Attribute{
+			{Key: "http.request.method", Value: request.Method},
+			{Key: "url.full", Value: request.URL.Redacted()},
+			{Key: "server.address", Value: request.URL.Hostname()},
+		},
+	}
+}
+
+// Finish ends the span and exports it.
+func (t *Tracer) Finish(span *Span) {
+	if t == nil || span == nil {
+		return
+	}
+	if span.End.IsZero() {
+		span.End = time.Now()
+	}
+	t.exporter.Export(span)
+}
@@ -0,0 +1,33 @@
+package mockserver
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// WithArrivalsHandler registers a handler listening on /arrivals which
+// records when each request arrived, see Arrivals, and responds after
+// ?delay=duration (immediately by default).
+func WithArrivalsHandler() ServerOption {
+	return func(m *MockServer) {
+		m.mux.HandleFunc("/arrivals", func(w http.ResponseWriter, r *http.Request) {
+			m.mu.Lock()
+			m.arrivals = append(m.arrivals, time.Now())
+			m.mu.Unlock()
+			m.Seen.Add(1)
+			delay, _ := time.ParseDuration(r.URL.Query().Get("delay"))
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+			}
+		})
+	}
+}
+
+// Arrivals returns when each request to /arrivals arrived, in order.
+func (m *MockServer) Arrivals() []time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.arrivals)
+}
@@ -0,0 +1,244 @@
+package mockserver
+
+import (
+	"encoding/binary"
+	"io"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// WithGRPCEchoHandler serves HTTP/2 without TLS (h2c) alongside HTTP/1.1
+// and registers the echo.Echo service, described by GRPCDescriptorSet,
+// together with the v1alpha server reflection service:
+//
+//	service Echo {
+//	  // Say replies with the message, or NOT_FOUND if its text is "fail".
+//	  rpc Say(Message) returns (Message);
+//	  // Repeat replies with the message count times.
+//	  rpc Repeat(Message) returns (stream Message);
+//	  // Collect replies with the texts joined and the number received.
+//	  rpc Collect(stream Message) returns (Message);
+//	  rpc Ping(google.protobuf.Empty) returns (google.protobuf.Empty);
+//	}
+//	message Message { string text = 1; int32 count = 2; }
+func WithGRPCEchoHandler() ServerOption {
+	return func(m *MockServer) {
+		m.Server.Config.Protocols = new(http.Protocols)
+		m.Server.Config.Protocols.SetHTTP1(true)
+		m.Server.Config.Protocols.SetUnencryptedHTTP2(true)
+		m.mux.HandleFunc("/echo.Echo/{method}", func(w http.ResponseWriter, r *http.Request) {
+			messages, ok := m.readGRPC(w, r)
+			if !ok {
+				return
+			}
+			var replies [][]byte
+			code, message := 0, ""
+			switch r.PathValue("method") {
+			case "Say":
+				if text, _ := echoMessage(messages[0]); text == "fail" {
+					code, message = 5, "no such thing"
+					break
+				}
+				replies = messages[:1]
+			case "Repeat":
+				_, count := echoMessage(messages[0])
+				for range count {
+					replies = append(replies, messages[0])
+				}
+			case "Collect":
+				texts := make([]string, len(messages))
+				for i, msg := range messages {
+					texts[i], _ = echoMessage(msg)
+				}
+				reply := protowire.AppendTag(nil, 1, protowire.BytesType)
+				reply = protowire.AppendString(reply, strings.Join(texts, " "))
+				reply = protowire.AppendTag(reply, 2, protowire.VarintType)
+				replies = append(replies, protowire.AppendVarint(reply, uint64(len(messages))))
+			case "Ping":
+				replies = [][]byte{nil}
+			default:
+				code, message = 12, "unknown method"
+			}
+			m.Seen.Add(1)
+			writeGRPC(w, code, message, replies...)
+		})
+		m.mux.HandleFunc("/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", func(w http.ResponseWriter, r *http.Request) {
+			requests, ok := m.readGRPC(w, r)
+			if !ok {
+				return
+			}
+			files := make(map[string][]byte)
+			for _, file := range grpcFiles() {
+				files[file.GetName()], _ = proto.Marshal(file)
+			}
+			var replies [][]byte
+			for _, request := range requests {
+				var file []byte
+				for number, value := range fields(request) {
+					switch {
+					case number == 3:
+						file = files[string(value)]
+					case number == 4 && strings.HasPrefix(string(value), "echo.Echo"):
+						file = files["echo.proto"]
+					}
+				}
+				if file == nil {
+					status := protowire.AppendTag(nil, 1, protowire.VarintType)
+					status = protowire.AppendVarint(status, 5)
+					status = protowire.AppendTag(status, 2, protowire.BytesType)
+					status = protowire.AppendString(status, "not found")
+					reply := protowire.AppendTag(nil, 7, protowire.BytesType)
+					replies = append(replies, protowire.AppendBytes(reply, status))
+					continue
+				}
+				response := protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), file)
+				reply := protowire.AppendTag(nil, 4, protowire.BytesType)
+				replies = append(replies, protowire.AppendBytes(reply, response))
+			}
+			writeGRPC(w, 0, "", replies...)
+		})
+	}
+}
+
+// GRPCDescriptorSet returns the FileDescriptorSet describing the service
+// of WithGRPCEchoHandler, as protoc writes with --include_imports.
+func GRPCDescriptorSet() []byte {
+	b, _ := proto.Marshal(&descriptorpb.FileDescriptorSet{File: grpcFiles()})
+	return b
+}
+
+// readGRPC reads the messages of a call, rejecting anything else.
+func (m *MockServer) readGRPC(w http.ResponseWriter, r *http.Request) ([][]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" || err != nil {
+		m.Errors.Add(1)
+		http.Error(w, "expected a grpc call", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	var messages [][]byte
+	for len(body) >= 5 {
+		size := int(binary.BigEndian.Uint32(body[1:5]))
+		if len(body)-5 < size {
+			break
+		}
+		messages = append(messages, body[5:5+size])
+		body = body[5+size:]
+	}
+	if len(messages) == 0 || len(body) > 0 {
+		m.Errors.Add(1)
+		writeGRPC(w, 13, "malformed request")
+		return nil, false
+	}
+	return messages, true
+}
+
+// writeGRPC replies with the messages followed by the status trailers.
+func writeGRPC(w http.ResponseWriter, code int, message string, replies ...[]byte) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	for _, reply := range replies {
+		frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(reply)))
+		_, _ = w.Write(append(frame, reply...))
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", message)
+	}
+}
+
+// echoMessage decodes an echo.Message.
+func echoMessage(b []byte) (string, int) {
+	var (
+		text  string
+		count int
+	)
+	for number, value := range fields(b) {
+		switch number {
+		case 1:
+			text = string(value)
+		case 2:
+			v, _ := protowire.ConsumeVarint(value)
+			count = int(int32(v))
+		}
+	}
+	return text, count
+}
+
+// fields yields the number and value of each varint or length delimited
+// field of the encoded message, varints still encoded.
+func fields(b []byte) iter.Seq2[protowire.Number, []byte] {
+	return func(yield func(protowire.Number, []byte) bool) {
+		for len(b) > 0 {
+			number, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				return
+			}
+			b = b[n:]
+			value := b
+			if n = protowire.ConsumeFieldValue(number, typ, b); n < 0 {
+				return
+			}
+			value, b = value[:n], b[n:]
+			if typ == protowire.BytesType {
+				value, _ = protowire.ConsumeBytes(value)
+			}
+			if !yield(number, value) {
+				return
+			}
+		}
+	}
+}
+
+// grpcFiles returns the files describing the echo.Echo service, its
+// dependencies first.
+func grpcFiles() []*descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+	}
+	method := func(name, input, output string, clientStreaming, serverStreaming bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(input),
+			OutputType:      proto.String(output),
+			ClientStreaming: proto.Bool(clientStreaming),
+			ServerStreaming: proto.Bool(serverStreaming),
+		}
+	}
+	echo := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("echo.proto"),
+		Package:    proto.String("echo"),
+		Dependency: []string{"google/protobuf/empty.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Message"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("text", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("Say", ".echo.Message", ".echo.Message", false, false),
+				method("Repeat", ".echo.Message", ".echo.Message", false, true),
+				method("Collect", ".echo.Message", ".echo.Message", true, false),
+				method("Ping", ".google.protobuf.Empty", ".google.protobuf.Empty", false, false),
+			},
+		}},
+		Syntax: proto.String("proto3"),
+	}
+	return []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(emptypb.File_google_protobuf_empty_proto), echo}
+}
@@ -5,7 +5,9 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // ServerOption defines a functional option for the MockServer
@@ -20,14 +22,17 @@ type MockServer struct {
 	mux    *http.ServeMux
 	Seen   atomic.Int64
 	Errors atomic.Int64
+
+	mu       sync.Mutex
+	arrivals []time.Time
 }
 
 // New instantiates a new MockServer and returns a ptr to it.
-// Functional options are applied and the caller is responsible
-// for calling .Close() when finished with the server.
+// Functional options are applied before the server is started and the
+// caller is responsible for calling .Close() when finished with the server.
 func New(options ...ServerOption) *MockServer {
 	mux := http.NewServeMux()
-	s := httptest.NewServer(mux)
+	s := httptest.NewUnstartedServer(mux)
 	m := &MockServer{
 		Server: s,
 		mux:    mux,
@@ -35,6 +40,7 @@ func New(options ...ServerOption) *MockServer {
 	for _, opt := range options {
 		opt(m)
 	}
+	s.Start()
 	return m
 }
 
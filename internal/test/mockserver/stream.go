@@ -0,0 +1,58 @@
+package mockserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithStreamHandler registers handlers listening on /sse and /chunks which
+// stream ?events=n events (10 by default) separated by ?gap=duration (1ms
+// by default).  /sse sends server-sent events, preceded by a keep alive
+// comment which is not an event, and /chunks sends each event as a chunk
+// of a chunked response.
+func WithStreamHandler() ServerOption {
+	return func(m *MockServer) {
+		stream := func(w http.ResponseWriter, r *http.Request, contentType string, event func(i int) string) {
+			events, gap := 10, time.Millisecond
+			if v := r.URL.Query().Get("events"); v != "" {
+				events, _ = strconv.Atoi(v)
+			}
+			if v := r.URL.Query().Get("gap"); v != "" {
+				gap, _ = time.ParseDuration(v)
+			}
+			m.Seen.Add(1)
+			w.Header().Set("Content-Type", contentType)
+			rc := http.NewResponseController(w)
+			for i := range events {
+				if i > 0 {
+					select {
+					case <-time.After(gap):
+					case <-r.Context().Done():
+						return
+					}
+				}
+				if _, err := fmt.Fprint(w, event(i)); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			}
+		}
+		m.mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+			stream(w, r, "text/event-stream", func(i int) string {
+				if i == 0 {
+					return ": keep alive\n\nevent: token\ndata: 0\n\n"
+				}
+				return fmt.Sprintf("id: %d\ndata: %d\r\n\r\n", i, i)
+			})
+		})
+		m.mux.HandleFunc("/chunks", func(w http.ResponseWriter, r *http.Request) {
+			stream(w, r, "text/plain", func(i int) string {
+				return fmt.Sprintf("chunk %d\n", i)
+			})
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package mockserver
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+)
+
+// WithWebSocketEchoHandler registers a handler listening on /ws which
+// accepts WebSocket connections and echoes every text message.  Each echo
+// is preceded by an unrelated {"push":true} message, and the message
+// "close" closes the connection with the code 1011.
+func WithWebSocketEchoHandler() ServerOption {
+	return func(m *MockServer) {
+		m.mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Upgrade") != "websocket" {
+				m.Errors.Add(1)
+				http.Error(w, "expected a websocket upgrade", http.StatusBadRequest)
+				return
+			}
+			conn, rw, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				m.Errors.Add(1)
+				return
+			}
+			defer conn.Close()
+			h := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+			_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+				"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(h[:]) + "\r\n\r\n")
+			if rw.Flush() != nil {
+				return
+			}
+			for {
+				op, payload, err := readFrame(rw.Reader)
+				if err != nil || op == 0x8 {
+					return
+				}
+				m.Seen.Add(1)
+				if string(payload) == "close" {
+					writeFrame(rw.Writer, 0x8, binary.BigEndian.AppendUint16(nil, 1011))
+					return
+				}
+				writeFrame(rw.Writer, 0x1, []byte(`{"push":true}`))
+				writeFrame(rw.Writer, op, payload)
+			}
+		})
+	}
+}
+
+// readFrame reads a single, masked, client frame.
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := uint64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return header[0] & 0x0f, payload, nil
+}
+
+// writeFrame writes a single, unmasked, server frame.
+func writeFrame(w *bufio.Writer, op byte, payload []byte) {
+	header := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	_, _ = w.Write(header)
+	_, _ = w.Write(payload)
+	_ = w.Flush()
+}
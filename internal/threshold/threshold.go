@@ -0,0 +1,242 @@
+package threshold
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/symonk/vessel/internal/collector"
+)
+
+// Metrics which are not latencies.  Latencies are referred to by min, max,
+// mean or a percentile such as p99 or p99.9.
+const (
+	Errors   = "errors"
+	Checks   = "checks"
+	RPS      = "rps"
+	Requests = "requests"
+)
+
+// operators in the order they must be matched, longest first.
+var operators = []string{"<=", ">=", "<", ">"}
+
+// Condition is a single comparison of a metric of a run against a limit,
+// such as p99<200ms.
+type Condition struct {
+	Metric   string
+	Operator string
+	// Limit is in microseconds for latencies, a fraction for the errors
+	// and checks rates and as is otherwise.
+	Limit float64
+	raw   string
+}
+
+// String returns the condition as it was written.
+func (c Condition) String() string {
+	return c.raw
+}
+
+// Expression is a set of conditions which must all hold.
+type Expression []Condition
+
+// Violation describes a condition which did not hold for a run.
+type Violation struct {
+	Condition Condition
+	Actual    string
+}
+
+// String implements fmt.Stringer.
+func (v Violation) String() string {
+	return fmt.Sprintf("%s (actual %s)", v.Condition, v.Actual)
+}
+
+// Parse parses an expression of conditions joined with &&, for example:
+//
+//	p99<200ms && errors<1% && checks<0.5%
+//
+// Latency limits are durations (bare numbers are milliseconds), the errors
+// and checks rates are percentages (the % is required) and the rps and
+// requests limits are plain numbers.
+func Parse(expr string) (Expression, error) {
+	var e Expression
+	for _, term := range strings.Split(expr, "&&") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("threshold %q has an empty condition", expr)
+		}
+		c, err := parseCondition(term)
+		if err != nil {
+			return nil, err
+		}
+		e = append(e, c)
+	}
+	return e, nil
+}
+
+// ParseAll parses every expression, all of which must hold.
+func ParseAll(exprs []string) (Expression, error) {
+	var all Expression
+	for _, expr := range exprs {
+		e, err := Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, e...)
+	}
+	return all, nil
+}
+
+// parseCondition parses a single metric<operator><limit> condition.
+func parseCondition(term string) (Condition, error) {
+	for _, op := range operators {
+		metric, value, ok := strings.Cut(term, op)
+		if !ok {
+			continue
+		}
+		c := Condition{
+			Metric:   strings.ToLower(strings.TrimSpace(metric)),
+			Operator: op,
+			raw:      strings.Join(strings.Fields(term), ""),
+		}
+		limit, err := parseLimit(c.Metric, strings.TrimSpace(value))
+		if err != nil {
+			return Condition{}, fmt.Errorf("invalid threshold %q: %w", term, err)
+		}
+		c.Limit = limit
+		return c, nil
+	}
+	return Condition{}, fmt.Errorf("threshold %q must be of the form metric<limit", term)
+}
+
+// parseLimit parses the limit according to the kind of metric.
+func parseLimit(metric, value string) (float64, error) {
+	switch {
+	case metric == Errors || metric == Checks:
+		pct, ok := strings.CutSuffix(value, "%")
+		if !ok {
+			return 0, fmt.Errorf("the %s rate must be a percentage such as 1%%", metric)
+		}
+		f, err := strconv.ParseFloat(pct, 64)
+		return f / 100, err
+	case metric == RPS || metric == Requests:
+		return strconv.ParseFloat(value, 64)
+	case isLatency(metric):
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f * 1000, nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+		return float64(d.Microseconds()), nil
+	default:
+		return 0, fmt.Errorf("unknown metric %q", metric)
+	}
+}
+
+// isLatency reports whether the metric refers to latency.
+func isLatency(metric string) bool {
+	switch metric {
+	case "min", "max", "mean":
+		return true
+	}
+	_, ok := percentile(metric)
+	return ok
+}
+
+// percentile parses a percentile metric such as p99.9.
+func percentile(metric string) (float64, bool) {
+	q, ok := strings.CutPrefix(metric, "p")
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(q, 64)
+	if err != nil || f <= 0 || f > 100 {
+		return 0, false
+	}
+	return f, true
+}
+
+// errNoSamples is returned by value for a latency metric of a result
+// without any successful requests.
+var errNoSamples = errors.New("no successful requests")
+
+// Evaluate returns the conditions which do not hold for the result.  A
+// latency condition never holds for a result without successful requests.
+func (e Expression) Evaluate(r *collector.Result) ([]Violation, error) {
+	var violations []Violation
+	for _, c := range e {
+		actual, err := value(c.Metric, r)
+		if errors.Is(err, errNoSamples) {
+			violations = append(violations, Violation{Condition: c, Actual: err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !c.holds(actual) {
+			violations = append(violations, Violation{Condition: c, Actual: format(c.Metric, actual)})
+		}
+	}
+	return violations, nil
+}
+
+// holds reports whether the condition holds for the actual value.
+func (c Condition) holds(actual float64) bool {
+	switch c.Operator {
+	case "<":
+		return actual < c.Limit
+	case "<=":
+		return actual <= c.Limit
+	case ">":
+		return actual > c.Limit
+	default:
+		return actual >= c.Limit
+	}
+}
+
+// value returns the value of the metric for the result.
+func value(metric string, r *collector.Result) (float64, error) {
+	switch metric {
+	case Errors:
+		return r.ErrorRate, nil
+	case Checks:
+		return r.FailureRate, nil
+	case RPS:
+		return r.RPS, nil
+	case Requests:
+		return float64(r.Requests), nil
+	}
+	h, err := r.Latency.Decode()
+	if err != nil {
+		return 0, fmt.Errorf("unable to evaluate %s: %w", metric, err)
+	}
+	if h.TotalCount() == 0 {
+		return 0, errNoSamples
+	}
+	switch metric {
+	case "min":
+		return float64(r.Latency.Min), nil
+	case "max":
+		return float64(r.Latency.Max), nil
+	case "mean":
+		return r.Latency.Mean, nil
+	}
+	q, _ := percentile(metric)
+	return float64(h.ValueAtQuantile(q)), nil
+}
+
+// format formats the value of the metric in the units it is written in.
+func format(metric string, v float64) string {
+	switch metric {
+	case Errors, Checks:
+		return fmt.Sprintf("%.2f%%", v*100)
+	case RPS:
+		return fmt.Sprintf("%.2f", v)
+	case Requests:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return fmt.Sprintf("%.2fms", v/1000)
+}
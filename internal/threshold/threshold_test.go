@@ -0,0 +1,82 @@
+package threshold
+
+import (
+	"testing"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/collector"
+)
+
+func result(t *testing.T) *collector.Result {
+	t.Helper()
+	h := hdrhistogram.New(1, 60_000_000, 3)
+	for i := int64(1); i <= 100; i++ {
+		require.NoError(t, h.RecordValue(i*1000))
+	}
+	latency, err := collector.NewLatencyResult(h)
+	require.NoError(t, err)
+	return &collector.Result{
+		Requests:    100,
+		RPS:         250,
+		ErrorRate:   0.02,
+		FailureRate: 0.001,
+		Latency:     latency,
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := map[string]struct {
+		expr     string
+		violated []string
+	}{
+		"all_hold":          {expr: "p99<200ms && errors<5% && checks<0.5%"},
+		"latency_breached":  {expr: "p50 < 20ms && p99<200ms", violated: []string{"p50<20ms"}},
+		"bare_milliseconds": {expr: "max<=101", violated: nil},
+		"fractional_pct":    {expr: "p99.9<50ms", violated: []string{"p99.9<50ms"}},
+		"errors_breached":   {expr: "errors<1%", violated: []string{"errors<1%"}},
+		"throughput":        {expr: "rps>=300 && requests>10", violated: []string{"rps>=300"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.expr)
+			require.NoError(t, err)
+			violations, err := e.Evaluate(result(t))
+			require.NoError(t, err)
+			var got []string
+			for _, v := range violations {
+				got = append(got, v.Condition.String())
+			}
+			assert.Equal(t, tc.violated, got)
+		})
+	}
+}
+
+func TestParseRejectsBadExpressions(t *testing.T) {
+	for _, expr := range []string{"", "p99", "p99<fast", "latency<1s", "p99<1s &&", "errors<abc%", "errors<0.01", "checks<1"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestLatencyWithoutSamples(t *testing.T) {
+	latency, err := collector.NewLatencyResult(hdrhistogram.New(1, 60_000_000, 3))
+	require.NoError(t, err)
+	e, err := Parse("p99<200ms && max<1s && errors<101% && requests>=0")
+	require.NoError(t, err)
+	violations, err := e.Evaluate(&collector.Result{Requests: 10, ErrorRate: 1, Latency: latency})
+	require.NoError(t, err)
+	require.Len(t, violations, 2)
+	assert.Equal(t, "p99<200ms (actual no successful requests)", violations[0].String())
+	assert.Equal(t, "max<1s", violations[1].Condition.String())
+}
+
+func TestViolationString(t *testing.T) {
+	e, err := Parse("p50<10ms")
+	require.NoError(t, err)
+	violations, err := e.Evaluate(result(t))
+	require.NoError(t, err)
+	require.Len(t, violations, 1)
+	assert.Equal(t, "p50<10ms (actual 50.02ms)", violations[0].String())
+}
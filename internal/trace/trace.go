@@ -1,8 +1,15 @@
 package trace
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
+// Trace holds the timings of a single request lifecycle.  The embedded
+// mutex must be held when accessing fields, dials initiated by a request
+// can outlive it and continue to report in the background.
 type Trace struct {
+	sync.Mutex
 	DnsStart          time.Time
 	DnsDone           time.Duration
 	ConnectStart      time.Time
@@ -12,4 +19,6 @@ type Trace struct {
 	GettingConnection time.Time
 	GotConnection     time.Duration
 	ReusedConnection  bool
+	WroteRequest      time.Time
+	FirstByte         time.Time
 }
@@ -22,7 +22,7 @@ func ParseBasicAuth(input string) (user, pass string, err error) {
 // headers and splits them into appropriate headers for a
 // http.Request to utilise.
 func ParseHTTPHeaders(input []string) http.Header {
-	var headers http.Header
+	headers := make(http.Header)
 	for _, h := range input {
 		split := strings.SplitN(h, ":", 2)
 		if len(split) != 2 {
@@ -30,7 +30,7 @@ func ParseHTTPHeaders(input []string) http.Header {
 			// TODO: Might want to write to stderr tho?
 			continue
 		}
-		k, v := split[0], split[1]
+		k, v := strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
 		if k == "" || v == "" {
 			// Throw away the bad header
 			// TODO: Might want to write to stderr tho?
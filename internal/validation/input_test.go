@@ -59,3 +59,19 @@ func TestParsingBasicAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestParsingHTTPHeaders(t *testing.T) {
+	headers := ParseHTTPHeaders([]string{
+		"Authorization: Bearer TOKEN",
+		"Content-Type:application/json",
+		"X-Multi: a",
+		"X-Multi: b",
+		"novalue:",
+		":nokey",
+		"nocolon",
+	})
+	assert.Equal(t, "Bearer TOKEN", headers.Get("Authorization"))
+	assert.Equal(t, "application/json", headers.Get("Content-Type"))
+	assert.Equal(t, []string{"a", "b"}, headers.Values("X-Multi"))
+	assert.Len(t, headers, 3)
+}
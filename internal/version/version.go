@@ -0,0 +1,9 @@
+// Package version holds the version of vessel, shared by the command and
+// the library so that both report the same one.
+package version
+
+// Version is the version of vessel, reported in the User-Agent header and
+// the results.  It is overridden at build time using -ldflags:
+//
+//	go build -ldflags "-X github.com/symonk/vessel/internal/version.Version=v1.2.3"
+var Version = "v0.0.1"
@@ -0,0 +1,296 @@
+// Package websocket implements the client side of the WebSocket protocol
+// (RFC 6455) on top of an http.Client, so that connections are opened with
+// the same transport, TLS configuration and headers as any other request.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Opcode is the type of a frame.
+type Opcode byte
+
+// The opcodes of RFC 6455 section 5.2.
+const (
+	Continuation Opcode = 0x0
+	Text         Opcode = 0x1
+	Binary       Opcode = 0x2
+	Close        Opcode = 0x8
+	Ping         Opcode = 0x9
+	Pong         Opcode = 0xa
+)
+
+// Close codes of RFC 6455 section 7.4.1.
+const (
+	NormalClosure   = 1000
+	NoStatusPresent = 1005
+)
+
+// MaxMessageSize is the largest message read before the connection is
+// failed, guarding against unbounded allocations.
+const MaxMessageSize = 32 << 20
+
+// accept is the GUID the server's accept key is derived with.
+const accept = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrMessageTooLarge is returned reading a message over MaxMessageSize.
+var ErrMessageTooLarge = errors.New("websocket: message too large")
+
+// CloseError is returned reading from a connection the server closed,
+// holding the close code and reason it gave.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+// Error implements error.
+func (c *CloseError) Error() string {
+	if c.Reason == "" {
+		return fmt.Sprintf("websocket: closed by server with code %d", c.Code)
+	}
+	return fmt.Sprintf("websocket: closed by server with code %d: %s", c.Code, c.Reason)
+}
+
+// HandshakeError is returned when the server does not switch protocols.
+type HandshakeError struct {
+	StatusCode int
+}
+
+// Error implements error.
+func (h *HandshakeError) Error() string {
+	return fmt.Sprintf("websocket: handshake failed with status %d", h.StatusCode)
+}
+
+// IsWebSocket reports whether the url has the ws or wss scheme.
+func IsWebSocket(url string) bool {
+	scheme, _, _ := strings.Cut(url, "://")
+	scheme = strings.ToLower(scheme)
+	return scheme == "ws" || scheme == "wss"
+}
+
+// Conn is a client WebSocket connection.  Messages may be written whilst
+// another goroutine reads, but only a single goroutine may read.
+type Conn struct {
+	rwc    io.ReadWriteCloser
+	reader *bufio.Reader
+	mu     sync.Mutex // Serialises writes, pongs are written by the reader.
+	closed bool
+}
+
+// Dial opens a connection with the opening handshake, sent as a GET of the
+// request (without its body) through the client.  The scheme of a ws or
+// wss url is swapped for http or https.  The response of the handshake is
+// returned alongside the connection.
+func Dial(ctx context.Context, client *http.Client, request *http.Request) (*Conn, *http.Response, error) {
+	request = request.Clone(ctx)
+	request.Method = http.MethodGet
+	request.Body, request.GetBody, request.ContentLength = nil, nil, 0
+	switch strings.ToLower(request.URL.Scheme) {
+	case "ws":
+		request.URL.Scheme = "http"
+	case "wss":
+		request.URL.Scheme = "https"
+	}
+	key := make([]byte, 16)
+	_, _ = rand.Read(key)
+	nonce := base64.StdEncoding.EncodeToString(key)
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", nonce)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+		response.Body.Close()
+		return nil, response, &HandshakeError{StatusCode: response.StatusCode}
+	}
+	rwc, ok := response.Body.(io.ReadWriteCloser)
+	if !ok {
+		response.Body.Close()
+		return nil, response, errors.New("websocket: the transport does not support upgrades")
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != acceptKey(nonce) {
+		rwc.Close()
+		return nil, response, errors.New("websocket: the server returned an invalid accept key")
+	}
+	return &Conn{rwc: rwc, reader: bufio.NewReader(rwc)}, response, nil
+}
+
+// acceptKey returns the accept key the server must reply with.
+func acceptKey(nonce string) string {
+	h := sha1.Sum([]byte(nonce + accept))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// WriteMessage writes a single, unfragmented, message.
+func (c *Conn) WriteMessage(op Opcode, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeFrame(op, payload)
+}
+
+// writeFrame writes a masked frame, as all frames sent by a client must
+// be.  The caller holds the write lock.
+func (c *Conn) writeFrame(op Opcode, payload []byte) error {
+	if c.closed {
+		return net.ErrClosed
+	}
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | byte(op)
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	header[1] |= 0x80
+	var mask [4]byte
+	_, _ = rand.Read(mask[:])
+	header = append(header, mask[:]...)
+	frame := append(header, payload...)
+	for i := range payload {
+		frame[len(header)+i] ^= mask[i%4]
+	}
+	_, err := c.rwc.Write(frame)
+	return err
+}
+
+// ReadMessage reads the next data message, reassembling fragments.  Pings
+// are answered and pongs discarded meanwhile.  A close from the server is
+// acknowledged and returned as a *CloseError.
+func (c *Conn) ReadMessage() (Opcode, []byte, error) {
+	var (
+		op      Opcode
+		message []byte
+	)
+	for {
+		fin, frameOp, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch frameOp {
+		case Ping:
+			if err := c.WriteMessage(Pong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case Pong:
+			continue
+		case Close:
+			return 0, nil, c.acknowledge(payload)
+		case Continuation:
+			if op == 0 {
+				return 0, nil, errors.New("websocket: unexpected continuation frame")
+			}
+		case Text, Binary:
+			if op != 0 {
+				return 0, nil, errors.New("websocket: expected a continuation frame")
+			}
+			op = frameOp
+		default:
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %d", frameOp)
+		}
+		if len(message)+len(payload) > MaxMessageSize {
+			return 0, nil, ErrMessageTooLarge
+		}
+		message = append(message, payload...)
+		if fin {
+			if op == Text && !utf8.Valid(message) {
+				return 0, nil, errors.New("websocket: text message is not valid utf-8")
+			}
+			return op, message, nil
+		}
+	}
+}
+
+// acknowledge acknowledges a close frame from the server, returning the error
+// describing it.
+func (c *Conn) acknowledge(payload []byte) error {
+	e := &CloseError{Code: NoStatusPresent}
+	if len(payload) >= 2 {
+		e.Code = int(binary.BigEndian.Uint16(payload))
+		e.Reason = string(payload[2:])
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		_ = c.writeFrame(Close, payload[:min(2, len(payload))])
+		c.closed = true
+	}
+	return e
+}
+
+// readFrame reads a single frame, server frames must not be masked.
+func (c *Conn) readFrame() (bool, Opcode, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	op := Opcode(header[0] & 0x0f)
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("websocket: reserved bits set without an extension")
+	}
+	if header[1]&0x80 != 0 {
+		return false, 0, nil, errors.New("websocket: server frames must not be masked")
+	}
+	n := uint64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.reader, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.reader, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if op >= Close && (n > 125 || !fin) {
+		return false, 0, nil, errors.New("websocket: invalid control frame")
+	}
+	if n > MaxMessageSize {
+		return false, 0, nil, ErrMessageTooLarge
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	return fin, op, payload, nil
+}
+
+// Close starts the closing handshake with the code and closes the
+// connection, without waiting for the server to acknowledge it.
+func (c *Conn) Close(code int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		_ = c.writeFrame(Close, binary.BigEndian.AppendUint16(nil, uint16(code)))
+		c.closed = true
+	}
+	return c.rwc.Close()
+}
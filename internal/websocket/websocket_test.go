@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// frame encodes an unmasked server frame.
+func frame(fin bool, op Opcode, payload []byte) []byte {
+	b := []byte{byte(op), 0}
+	if fin {
+		b[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		b[1] = byte(n)
+	case n <= 0xffff:
+		b[1] = 126
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b[1] = 127
+		b = binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+	return append(b, payload...)
+}
+
+// unmask reads a masked client frame.
+func unmask(t *testing.T, r io.Reader) (Opcode, []byte) {
+	t.Helper()
+	header := make([]byte, 2)
+	_, err := io.ReadFull(r, header)
+	require.NoError(t, err)
+	require.NotZero(t, header[1]&0x80, "client frames must be masked")
+	payload := make([]byte, 4+int(header[1]&0x7f))
+	_, err = io.ReadFull(r, payload)
+	require.NoError(t, err)
+	mask, payload := payload[:4], payload[4:]
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return Opcode(header[0] & 0x0f), payload
+}
+
+func TestReadMessage(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &Conn{rwc: client, reader: bufio.NewReader(client)}
+	large := bytes.Repeat([]byte("a"), 70_000)
+	go func() {
+		for _, f := range [][]byte{
+			frame(false, Text, []byte("hel")),
+			frame(true, Ping, []byte("p")),
+			frame(true, Continuation, []byte("lo")),
+			frame(true, Binary, large),
+			frame(true, Close, append(binary.BigEndian.AppendUint16(nil, 1001), "bye"...)),
+		} {
+			if _, err := server.Write(f); err != nil {
+				return
+			}
+		}
+	}()
+
+	read := make(chan error, 1)
+	var (
+		op      Opcode
+		message []byte
+	)
+	go func() {
+		var err error
+		op, message, err = c.ReadMessage()
+		read <- err
+	}()
+	// The ping is answered whilst the message is being read.
+	pong, payload := unmask(t, server)
+	assert.Equal(t, Pong, pong)
+	assert.Equal(t, []byte("p"), payload)
+	require.NoError(t, <-read)
+	assert.Equal(t, Text, op)
+	assert.Equal(t, "hello", string(message))
+
+	op, message, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, Binary, op)
+	assert.Equal(t, large, message)
+
+	go func() {
+		_, _, err := c.ReadMessage()
+		read <- err
+	}()
+	closing, payload := unmask(t, server)
+	assert.Equal(t, Close, closing)
+	assert.Equal(t, uint16(1001), binary.BigEndian.Uint16(payload))
+	err = <-read
+	var closed *CloseError
+	require.ErrorAs(t, err, &closed)
+	assert.Equal(t, 1001, closed.Code)
+	assert.Equal(t, "bye", closed.Reason)
+	assert.ErrorIs(t, c.WriteMessage(Text, nil), net.ErrClosed)
+}
+
+func TestWriteMessage(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &Conn{rwc: client, reader: bufio.NewReader(client)}
+	go func() {
+		_ = c.WriteMessage(Text, []byte("hello"))
+	}()
+	op, payload := unmask(t, server)
+	assert.Equal(t, Text, op)
+	assert.Equal(t, "hello", string(payload))
+}
+
+func TestIsWebSocket(t *testing.T) {
+	assert.True(t, IsWebSocket("ws://localhost/chat"))
+	assert.True(t, IsWebSocket("WSS://localhost"))
+	assert.False(t, IsWebSocket("https://localhost"))
+	assert.False(t, IsWebSocket("localhost"))
+}
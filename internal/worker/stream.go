@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/stats"
+)
+
+// maxGap is the longest gap between events recorded, in microseconds.
+const maxGap = 3_600_000_000
+
+// stream reads a streamed response, timing each event as it arrives, until
+// it ends, the stream timeout elapses, the maximum number of events is
+// reached or the run ends.  None of the latter is an error, the stream is
+// cut short and reported as such.  The number of bytes read is returned
+// along with the body, which is only held onto if checks or response hooks
+// need it.
+func (w *Worker) stream(response *http.Response, began time.Time, s *stats.Stats) ([]byte, int64, error) {
+	var timeout <-chan time.Time
+	if w.cfg.StreamTimeout > 0 {
+		timer := time.NewTimer(w.cfg.StreamTimeout - time.Since(began))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var cut bool
+	done, watched := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(watched)
+		select {
+		case <-timeout:
+		case <-w.end:
+		case <-done:
+			return
+		}
+		// Closing the body aborts the read in progress.
+		cut = true
+		_ = response.Body.Close()
+	}()
+	var last time.Time
+	event := func() bool {
+		now := time.Now()
+		if s.Events == 0 {
+			s.FirstEvent = now.Sub(began)
+		} else {
+			// The gaps of a long stream are many, a histogram keeps them
+			// in bounded memory.
+			if s.EventGaps == nil {
+				s.EventGaps = hdrhistogram.New(1, maxGap, 2)
+			}
+			_ = s.EventGaps.RecordValue(now.Sub(last).Microseconds())
+		}
+		last = now
+		s.Events++
+		if limit := w.cfg.StreamMaxEvents; limit > 0 && s.Events >= limit {
+			s.StreamTruncated = true
+			return false
+		}
+		return true
+	}
+	var body bytes.Buffer
+	counter := &countingReader{r: response.Body}
+	var reader io.Reader = counter
+	if len(w.checks) > 0 || len(w.respHooks) > 0 {
+		reader = io.TeeReader(counter, &body)
+	}
+	var err error
+	if w.cfg.Stream == config.StreamSSE {
+		err = readEvents(reader, event)
+	} else {
+		err = readChunks(reader, event)
+	}
+	close(done)
+	<-watched
+	if cut {
+		s.StreamTimedOut = true
+		err = nil
+	}
+	return body.Bytes(), counter.n, err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readEvents calls event as each server-sent event is dispatched, on the
+// blank line following its data.  Comments, such as those sent to keep the
+// connection alive, and blocks without data are not events.  It stops
+// early if event returns false.
+func readEvents(r io.Reader, event func() bool) error {
+	reader := bufio.NewReader(r)
+	data := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// An event which is not terminated by a blank line is discarded.
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if data && !event() {
+				return nil
+			}
+			data = false
+			continue
+		}
+		field, _, _ := strings.Cut(line, ":")
+		if field == "data" {
+			data = true
+		}
+	}
+}
+
+// readChunks calls event as each read of data arrives.  It stops early if
+// event returns false.
+func readChunks(r io.Reader, event func() bool) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 && !event() {
+			return nil
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
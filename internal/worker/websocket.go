@@ -0,0 +1,261 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+
+	"github.com/symonk/vessel/internal/stats"
+	"github.com/symonk/vessel/internal/trace"
+	"github.com/symonk/vessel/internal/websocket"
+)
+
+// session is the WebSocket connection held open by a worker across its
+// iterations, with the messages received over it.
+type session struct {
+	conn      *websocket.Conn
+	handshake *http.Response
+	messages  chan []byte
+	err       error // Why messages was closed, safe to read once it is.
+	done      chan struct{}
+}
+
+// read receives messages until the connection fails or is closed.
+func (s *session) read() {
+	defer close(s.messages)
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			s.err = err
+			return
+		}
+		select {
+		case s.messages <- message:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// message sends the body of the request as a message over the worker's
+// WebSocket connection, opening it first if necessary, and measures the
+// round trip to its reply.  Without a correlation field the next message
+// received is taken as the reply, messages received in between iterations
+// are counted and discarded.
+func (w *Worker) message(trace *trace.Trace, request *http.Request, warmup bool) {
+	s := &stats.Stats{
+		Endpoint:   endpoint(request),
+		Worker:     w.id,
+		Warmup:     warmup,
+		Began:      time.Now(),
+		StatusCode: http.StatusSwitchingProtocols,
+	}
+	ctx, cancel := w.timeout()
+	defer cancel()
+	// Messages reuse the connection, unless this one opens it.
+	s.ReusedConn = stats.WasReused
+	if w.session == nil {
+		s.ReusedConn = stats.NotReused
+		if err := w.connect(ctx, trace, request, s); err != nil {
+			s.Err = err
+			s.Latency = time.Since(s.Began)
+			w.publish(s)
+			return
+		}
+	}
+	// A connection closed in between iterations is reported against the
+	// message which would have been sent over it.
+	if err := w.drain(s); err != nil {
+		w.fail(s, err)
+		return
+	}
+
+	payload, id, err := w.payload(request)
+	if err != nil {
+		w.fail(s, err)
+		return
+	}
+	s.Began = time.Now()
+	if err := w.session.conn.WriteMessage(websocket.Text, payload); err != nil {
+		w.fail(s, err)
+		return
+	}
+	s.BytesSent = int64(len(payload))
+	if w.cfg.WSNoReply {
+		s.Latency = time.Since(s.Began)
+		w.publish(s)
+		return
+	}
+
+	reply, err := w.await(ctx, id, s)
+	s.Latency = time.Since(s.Began)
+	if err != nil {
+		w.fail(s, err)
+		return
+	}
+	for _, h := range w.respHooks {
+		if err := h.AfterResponse(w.session.handshake, reply, w.info()); err != nil {
+			s.Err = errors.Join(s.Err, fmt.Errorf("response hook: %w", err))
+		}
+	}
+	if s.Err == nil {
+		for _, c := range w.checks {
+			if err := c.Check(w.session.handshake, reply); err != nil {
+				s.FailedChecks = append(s.FailedChecks, c.Name())
+			}
+		}
+	}
+	w.publish(s)
+}
+
+// connect opens the WebSocket connection of the worker, recording the
+// duration of the handshake and its lifecycle on the stats.
+func (w *Worker) connect(ctx context.Context, trace *trace.Trace, request *http.Request, s *stats.Stats) error {
+	request = request.WithContext(httptrace.WithClientTrace(ctx, w.trace))
+	for _, h := range w.reqHooks {
+		if err := h.BeforeRequest(request, w.info()); err != nil {
+			return fmt.Errorf("request hook: %w", err)
+		}
+	}
+	began := time.Now()
+	conn, response, err := websocket.Dial(request.Context(), w.client, request)
+	if err != nil {
+		if response != nil {
+			s.StatusCode = response.StatusCode
+		}
+		return err
+	}
+	s.Handshake = time.Since(began)
+	trace.Lock()
+	s.TimeOnDns = trace.DnsDone
+	s.TimeOnTls = trace.TlsDone
+	s.TimeOnConn = trace.GotConnection
+	s.TimeOnConnect = trace.ConnectDone
+	trace.Unlock()
+	w.session = &session{conn: conn, handshake: response, messages: make(chan []byte, 64), done: make(chan struct{})}
+	go w.session.read()
+	return nil
+}
+
+// drain discards the messages received since the previous iteration,
+// returning why the connection was closed if it has been.
+func (w *Worker) drain(s *stats.Stats) error {
+	for {
+		select {
+		case message, ok := <-w.session.messages:
+			if !ok {
+				return w.session.err
+			}
+			s.Received++
+			s.BytesReceived += int64(len(message))
+		default:
+			return nil
+		}
+	}
+}
+
+// await waits for the reply carrying the correlation id, or the next
+// message if there is none.
+func (w *Worker) await(ctx context.Context, id string, s *stats.Stats) ([]byte, error) {
+	for {
+		select {
+		case message, ok := <-w.session.messages:
+			if !ok {
+				return nil, w.session.err
+			}
+			s.Received++
+			s.BytesReceived += int64(len(message))
+			if id == "" || correlates(message, w.cfg.WSCorrelate, id) {
+				return message, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// payload returns the message to send for the request, with a unique
+// correlation id set on it if configured.
+func (w *Worker) payload(request *http.Request) ([]byte, string, error) {
+	var body []byte
+	if request.GetBody != nil {
+		r, err := request.GetBody()
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to read request body: %w", err)
+		}
+		if body, err = io.ReadAll(r); err != nil {
+			return nil, "", fmt.Errorf("unable to read request body: %w", err)
+		}
+	}
+	field := w.cfg.WSCorrelate
+	if field == "" {
+		return body, "", nil
+	}
+	message := make(map[string]any)
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &message); err != nil {
+			return nil, "", fmt.Errorf("correlated messages must be json objects: %w", err)
+		}
+	}
+	id := strconv.Itoa(w.id) + "-" + strconv.FormatInt(w.iteration, 10)
+	message[field] = id
+	b, err := json.Marshal(message)
+	return b, id, err
+}
+
+// correlates reports whether the message is a json object holding the id
+// in the field.
+func correlates(message []byte, field, id string) bool {
+	var reply map[string]any
+	if json.Unmarshal(message, &reply) != nil {
+		return false
+	}
+	switch v := reply[field].(type) {
+	case string:
+		return v == id
+	case nil:
+		return false
+	default:
+		return fmt.Sprint(v) == id
+	}
+}
+
+// fail publishes the stats of a message which failed, the connection is
+// closed and reopened by the next iteration.
+func (w *Worker) fail(s *stats.Stats, err error) {
+	if s.Latency == 0 {
+		s.Latency = time.Since(s.Began)
+	}
+	s.Err = err
+	var closed *websocket.CloseError
+	if errors.As(err, &closed) {
+		s.CloseCode = closed.Code
+	}
+	w.hangUp()
+	w.publish(s)
+}
+
+// hangUp closes the WebSocket connection of the worker, if it has one.
+func (w *Worker) hangUp() {
+	if w.session == nil {
+		return
+	}
+	close(w.session.done)
+	_ = w.session.conn.Close(websocket.NormalClosure)
+	w.session = nil
+}
+
+// timeout returns a context honouring the per request timeout, which in
+// the case of a WebSocket applies to each message.
+func (w *Worker) timeout() (context.Context, context.CancelFunc) {
+	if w.cfg.Timeout <= 0 {
+		return context.WithCancel(w.root)
+	}
+	return context.WithTimeout(w.root, w.cfg.Timeout)
+}
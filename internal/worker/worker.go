@@ -3,25 +3,32 @@ package worker
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"net/http/httptrace"
 	"sync"
 	"time"
 
+	"github.com/symonk/vessel/internal/check"
 	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/distribution"
+	"github.com/symonk/vessel/internal/grpc"
+	"github.com/symonk/vessel/internal/hook"
 	"github.com/symonk/vessel/internal/stats"
+	"github.com/symonk/vessel/internal/telemetry"
 	"github.com/symonk/vessel/internal/trace"
+	"github.com/symonk/vessel/internal/websocket"
 )
 
 // Worker is a struct that can accept requests to dispatch
 // on it's input channel and forward results on for collection
 // via it's outbound channel.
 //
-// Worker keeps an instance of TraceData for setting granularity
-// on the request lifecycle, this avoids even utilising a sync.Pool
-// as a worker can only be processing a single request at a time
-// and all fields on the TraceData are set on a per request basis.
+// Worker prepares a fresh trace for every request it dispatches in order
+// to capture granular timings of the request lifecycle.
 type Worker struct {
 	client     *http.Client
 	requestsCh <-chan *http.Request
@@ -30,20 +37,110 @@ type Worker struct {
 	wg         *sync.WaitGroup
 	root       context.Context // Avoid many heap allocs, use a shared root.
 	cfg        *config.Config
+	tracer     *telemetry.Tracer
+	span       *telemetry.Span // Span of the in flight request, if sampled.
+	delay      time.Duration   // How late the in flight request was sent.
+	id         int
+	iteration  int64
+	reqHooks   []hook.RequestHook
+	respHooks  []hook.ResponseHook
+	checks     []check.Check
+	think      distribution.Distribution
+	pacing     time.Duration
+	rng        *rand.Rand
+	last       time.Time // When the previous iteration began.
+	websocket  bool
+	session    *session        // The open WebSocket connection, if any.
+	end        <-chan struct{} // Closed at the end of the run.
+}
+
+// Option is a functional option for the Worker.
+type Option func(*Worker)
+
+// WithTracer propagates trace context on each request and exports
+// the sampled client spans.
+func WithTracer(t *telemetry.Tracer) Option {
+	return func(w *Worker) {
+		w.tracer = t
+	}
+}
+
+// WithID sets the id of the worker, exposed to hooks.
+func WithID(id int) Option {
+	return func(w *Worker) {
+		w.id = id
+	}
+}
+
+// WithRequestHooks runs the hooks (in order) on every request right
+// before it is dispatched.
+func WithRequestHooks(hooks ...hook.RequestHook) Option {
+	return func(w *Worker) {
+		w.reqHooks = append(w.reqHooks, hooks...)
+	}
+}
+
+// WithResponseHooks runs the hooks (in order) on every response once
+// its body has been read.
+func WithResponseHooks(hooks ...hook.ResponseHook) Option {
+	return func(w *Worker) {
+		w.respHooks = append(w.respHooks, hooks...)
+	}
+}
+
+// WithChecks validates every response (which was received without error)
+// against the checks.
+func WithChecks(checks ...check.Check) Option {
+	return func(w *Worker) {
+		w.checks = append(w.checks, checks...)
+	}
+}
+
+// WithThinkTime pauses for a duration sampled from the distribution
+// between the end of one iteration and the start of the next, as a user
+// would between actions.  The pause is not part of the latency.
+func WithThinkTime(d distribution.Distribution) Option {
+	return func(w *Worker) {
+		w.think = d
+	}
+}
+
+// WithPacing begins an iteration at most once every interval regardless
+// of how long the previous one took, an iteration which overruns the
+// interval is followed immediately by the next.
+func WithPacing(interval time.Duration) Option {
+	return func(w *Worker) {
+		w.pacing = interval
+	}
+}
+
+// WithEnd stops reading streamed responses once the channel is closed, at
+// the end of the run.
+func WithEnd(end <-chan struct{}) Option {
+	return func(w *Worker) {
+		w.end = end
+	}
 }
 
 // New instantiates a new worker and returns a ptr to
 // the instance of it.
-func New(client *http.Client, in <-chan *http.Request, out chan<- *stats.Stats, wg *sync.WaitGroup, root context.Context, cfg *config.Config) *Worker {
-	return &Worker{
+func New(client *http.Client, in <-chan *http.Request, out chan<- *stats.Stats, wg *sync.WaitGroup, root context.Context, cfg *config.Config, options ...Option) *Worker {
+	w := &Worker{
 		client:     client,
 		requestsCh: in,
 		resultsCh:  out,
-		trace:      new(httptrace.ClientTrace),
 		wg:         wg,
 		root:       root,
 		cfg:        cfg,
 	}
+	for _, opt := range options {
+		opt(w)
+	}
+	if w.think != nil {
+		w.rng = distribution.NewRand(w.cfg.Seed, uint64(w.id))
+	}
+	w.websocket = websocket.IsWebSocket(cfg.Endpoint)
+	return w
 }
 
 // Accept begins accepting requests until the internal request
@@ -53,39 +150,130 @@ func (w *Worker) Accept() {
 		return
 	}
 	defer w.wg.Done()
+	defer w.hangUp()
 	for {
 		select {
 		case request, ok := <-w.requestsCh:
 			if !ok {
 				return
 			}
+			if !w.pause() {
+				return
+			}
 			trace := w.prepareTracer()
+			warmup := stats.IsWarmup(request.Context())
+			if w.websocket {
+				w.message(trace, request, warmup)
+				w.iteration++
+				continue
+			}
 			response, began, err := w.send(request)
-			w.report(trace, response, began, err)
+			w.report(trace, endpoint(request), warmup, response, began, err)
+			w.iteration++
 		case <-w.root.Done():
 			// signal interrupt
 		}
 	}
 }
 
+// endpoint names the endpoint a request targets, its method and URL without
+// the query so that requests differing only in their query, such as those
+// replayed, are counted together, and with any password masked.
+func endpoint(request *http.Request) string {
+	target := *request.URL
+	target.RawQuery, target.ForceQuery, target.Fragment, target.RawFragment = "", false, "", ""
+	return request.Method + " " + target.Redacted()
+}
+
+// pause waits out the think time and pacing between the previous iteration
+// and the next, returning false if the run was interrupted or ended
+// meanwhile.
+func (w *Worker) pause() bool {
+	last := w.last
+	w.last = time.Now()
+	if last.IsZero() {
+		return true
+	}
+	var wait time.Duration
+	if w.think != nil {
+		wait = w.think.Sample(w.rng)
+	}
+	if w.pacing > 0 {
+		wait = max(wait, w.pacing-w.last.Sub(last))
+	}
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		w.last = time.Now()
+		return true
+	case <-w.root.Done():
+		return false
+	case <-w.end:
+		return false
+	}
+}
+
 // send dispatches the request to the client.  This allows granular control
 // of the context cancellation without having to handle stacking deferrals
 // of cancel funcs in a loop elsewhere leading to a potential memory leak.
 func (w *Worker) send(request *http.Request) (*http.Response, time.Time, error) {
+	values := request.Context()
+	due, scheduled := stats.Due(values)
+	w.delay = 0
 	ctx, cancel := w.context(w.cfg.Duration)
 	defer cancel()
 	request = request.Clone(ctx)
+	// Clones share the body of the template, each needs its own.
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, time.Now(), fmt.Errorf("unable to read request body: %w", err)
+		}
+		request.Body = body
+	}
 	// TODO: Does this play nice with timing out ctx?
-	request = request.WithContext(httptrace.WithClientTrace(w.root, w.trace))
+	request = request.WithContext(httptrace.WithClientTrace(valuesOf{Context: w.root, values: values}, w.trace))
+	w.span = w.tracer.Start(request)
+	for _, h := range w.reqHooks {
+		if err := h.BeforeRequest(request, w.info()); err != nil {
+			return nil, time.Now(), fmt.Errorf("request hook: %w", err)
+		}
+	}
 	when := time.Now()
 	response, err := w.client.Do(request)
+	// A scheduled arrival sent late, having waited for a free worker, is
+	// measured from when it was due so that the wait is not omitted.
+	if scheduled && due.Before(when) {
+		w.delay = when.Sub(due)
+		when = due
+	}
 	return response, when, err
 }
 
+// valuesOf is a context carrying the values of another, such as those a
+// request was built with, in addition to its own.
+type valuesOf struct {
+	context.Context
+	values context.Context
+}
+
+// Value implements context.Context.
+func (c valuesOf) Value(key any) any {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
 // report publishes appropriate data for a downstream system to consume
 // in order to make sense of results.
-func (w *Worker) report(trace *trace.Trace, response *http.Response, began time.Time, err error) {
+func (w *Worker) report(trace *trace.Trace, endpoint string, warmup bool, response *http.Response, began time.Time, err error) {
 	s := new(stats.Stats)
+	defer w.finishSpan(trace, s)
 
 	// TODO: Implement actual bytes capturing of the sent request.  The actual action
 	// of sending it via the client however will drain the stream, so a copy is required
@@ -95,26 +283,68 @@ func (w *Worker) report(trace *trace.Trace, response *http.Response, began time.
 	// capture pre-body read latency, it will be overwritten if a response
 	// body read occurs later.
 	s.Latency = time.Since(began)
-	// The request error'd, there likely is no response body.
+	s.Endpoint = endpoint
+	s.Began = began
+	s.Worker = w.id
+	s.Warmup = warmup
+	s.Delay = w.delay
+	// The request error'd, there likely is no response body.  It is still
+	// published so the collector can account for it.
 	if err != nil {
 		s.Err = err
+		w.publish(s)
 		return
 	}
 	defer response.Body.Close()
-	bytes, err := io.ReadAll(response.Body)
+	var bytes []byte
+	if w.cfg.Stream != "" {
+		bytes, s.BytesReceived, err = w.stream(response, began, s)
+	} else {
+		bytes, err = io.ReadAll(response.Body)
+		s.BytesReceived = int64(len(bytes))
+	}
 	if err != nil {
 		s.Err = err
 	}
 	s.Latency = time.Since(began)
-	s.BytesReceived = int64(len(bytes))
+	for _, h := range w.respHooks {
+		if err := h.AfterResponse(response, bytes, w.info()); err != nil {
+			s.Err = errors.Join(s.Err, fmt.Errorf("response hook: %w", err))
+		}
+	}
+	if s.Err == nil {
+		for _, c := range w.checks {
+			if err := c.Check(response, bytes); err != nil {
+				s.FailedChecks = append(s.FailedChecks, c.Name())
+			}
+		}
+	}
 
 	// Bolt on trace analytics from the lifecycle
+	trace.Lock()
 	s.TimeOnDns = trace.DnsDone
 	s.TimeOnTls = trace.TlsDone
 	s.TimeOnConn = trace.GotConnection
 	s.TimeOnConnect = trace.ConnectDone
+	if !trace.FirstByte.IsZero() {
+		s.TimeToFirstByte = trace.FirstByte.Sub(began.Add(s.Delay))
+	}
+	if trace.ReusedConnection {
+		s.ReusedConn = stats.WasReused
+	}
+	trace.Unlock()
 	s.StatusCode = response.StatusCode
+	// The outcome of a gRPC call is its status, held in the trailers read
+	// along with the body.
+	if w.cfg.GRPC {
+		s.StatusCode, _ = grpc.Status(response)
+	}
+	w.publish(s)
+}
 
+// publish forwards the stats onto the collector, unless the run has
+// been interrupted in the meantime.
+func (w *Worker) publish(s *stats.Stats) {
 	select {
 	case w.resultsCh <- s:
 	case <-w.root.Done():
@@ -122,6 +352,54 @@ func (w *Worker) report(trace *trace.Trace, response *http.Response, began time.
 	}
 }
 
+// info describes the in flight request for hooks.
+func (w *Worker) info() hook.Info {
+	return hook.Info{Worker: w.id, Iteration: w.iteration}
+}
+
+// finishSpan annotates the span of the in flight request (if sampled) with
+// the request lifecycle and outcome before exporting it.
+func (w *Worker) finishSpan(trace *trace.Trace, s *stats.Stats) {
+	span := w.span
+	if span == nil {
+		return
+	}
+	w.span = nil
+	trace.Lock()
+	defer trace.Unlock()
+	span.End = s.Began.Add(s.Latency)
+	span.AddEvent("dns.start", trace.DnsStart)
+	span.AddEvent("dns.done", after(trace.DnsStart, trace.DnsDone))
+	span.AddEvent("connect.start", trace.ConnectStart)
+	span.AddEvent("connect.done", after(trace.ConnectStart, trace.ConnectDone))
+	span.AddEvent("tls.start", trace.TlsStart)
+	span.AddEvent("tls.done", after(trace.TlsStart, trace.TlsDone))
+	span.AddEvent("request.written", trace.WroteRequest)
+	span.AddEvent("response.first_byte", trace.FirstByte)
+	span.SetAttributes(telemetry.Attribute{Key: "vessel.connection.reused", Value: trace.ReusedConnection})
+	switch {
+	case s.Err != nil:
+		span.Status, span.Message = telemetry.StatusError, s.Err.Error()
+	case w.cfg.GRPC && s.StatusCode != grpc.OK, !w.cfg.GRPC && s.StatusCode >= http.StatusBadRequest:
+		span.Status = telemetry.StatusError
+	}
+	switch {
+	case w.cfg.GRPC && s.Err == nil:
+		span.SetAttributes(telemetry.Attribute{Key: "rpc.grpc.status_code", Value: s.StatusCode})
+	case s.StatusCode != 0:
+		span.SetAttributes(telemetry.Attribute{Key: "http.response.status_code", Value: s.StatusCode})
+	}
+	w.tracer.Finish(span)
+}
+
+// after returns the end of a phase, or the zero time if it never began.
+func after(start time.Time, took time.Duration) time.Time {
+	if start.IsZero() {
+		return start
+	}
+	return start.Add(took)
+}
+
 // context returns a sensible context that honours the users timeout specific flags
 func (w *Worker) context(duration time.Duration) (context.Context, context.CancelFunc) {
 	if duration == 0 {
@@ -135,31 +413,60 @@ func (w *Worker) context(duration time.Duration) (context.Context, context.Cance
 // prepareTracer sets up the shared internal trace instance with fresh
 // values for a particular request.
 func (w *Worker) prepareTracer() *trace.Trace {
+	// A fresh client trace is required, the transport may still invoke the
+	// hooks of a previous request from a background dial.
 	trace := new(trace.Trace)
+	w.trace = new(httptrace.ClientTrace)
 	w.trace.DNSStart = func(info httptrace.DNSStartInfo) {
+		trace.Lock()
+		defer trace.Unlock()
 		trace.DnsStart = time.Now()
 	}
 	w.trace.DNSDone = func(info httptrace.DNSDoneInfo) {
+		trace.Lock()
+		defer trace.Unlock()
 		trace.DnsDone = time.Since(trace.DnsStart)
 	}
 	w.trace.ConnectStart = func(network string, addr string) {
+		trace.Lock()
+		defer trace.Unlock()
 		trace.ConnectStart = time.Now()
 	}
 	w.trace.ConnectDone = func(network string, addr string, err error) {
+		trace.Lock()
+		defer trace.Unlock()
 		trace.ConnectDone = time.Since(trace.ConnectStart)
 	}
 	w.trace.TLSHandshakeStart = func() {
+		trace.Lock()
+		defer trace.Unlock()
 		trace.TlsStart = time.Now()
 	}
 	w.trace.TLSHandshakeDone = func(state tls.ConnectionState, err error) {
+		trace.Lock()
+		defer trace.Unlock()
 		trace.TlsDone = time.Since(trace.TlsStart)
 	}
 	w.trace.GetConn = func(hostPort string) {
+		trace.Lock()
+		defer trace.Unlock()
 		trace.GettingConnection = time.Now()
 	}
 	w.trace.GotConn = func(conn httptrace.GotConnInfo) {
+		trace.Lock()
+		defer trace.Unlock()
 		trace.GotConnection = time.Since(trace.GettingConnection)
 		trace.ReusedConnection = conn.Reused
 	}
+	w.trace.WroteRequest = func(info httptrace.WroteRequestInfo) {
+		trace.Lock()
+		defer trace.Unlock()
+		trace.WroteRequest = time.Now()
+	}
+	w.trace.GotFirstResponseByte = func() {
+		trace.Lock()
+		defer trace.Unlock()
+		trace.FirstByte = time.Now()
+	}
 	return trace
 }
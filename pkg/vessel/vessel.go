@@ -0,0 +1,475 @@
+// Package vessel exposes vessel's HTTP load generation as a library so that
+// it can be driven from Go code, for example to assert performance budgets
+// directly in integration tests:
+//
+//	result, err := vessel.Run(ctx, vessel.Options{
+//		URL:         server.URL,
+//		Concurrency: 10,
+//		Duration:    5 * time.Second,
+//	})
+//	if result.Latency.P99 > 50*time.Millisecond {
+//		t.Errorf("p99 exceeded budget: %s", result.Latency.P99)
+//	}
+package vessel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/symonk/vessel/internal/collector"
+	"github.com/symonk/vessel/internal/config"
+	"github.com/symonk/vessel/internal/hook"
+	"github.com/symonk/vessel/internal/runner"
+	"github.com/symonk/vessel/internal/stats"
+	"github.com/symonk/vessel/internal/version"
+)
+
+type (
+	// Sink consumes the stream of results throughout a run, see
+	// Options.Sinks.  Each sink is driven from its own goroutine.
+	Sink = collector.Sink
+	// NopSink can be embedded by sinks only interested in a subset of
+	// the Sink events.
+	NopSink = collector.NopSink
+	// Sample is the outcome of a single request, delivered to Sink.OnResult.
+	Sample = stats.Stats
+	// Interval summarises the requests completed in a fixed window of the
+	// run, delivered to Sink.OnInterval.
+	Interval = collector.Interval
+	// Report is the complete, serialisable outcome of a run delivered to
+	// Sink.OnFinish.  It is the same document the CLI writes with --output.
+	Report = collector.Result
+
+	// RequestHook mutates each request right before it is dispatched,
+	// see Options.RequestHooks.
+	RequestHook = hook.RequestHook
+	// RequestHookFunc is an adapter to allow ordinary functions as
+	// RequestHooks.
+	RequestHookFunc = hook.RequestHookFunc
+	// ResponseHook inspects each response once its body has been read,
+	// see Options.ResponseHooks.
+	ResponseHook = hook.ResponseHook
+	// ResponseHookFunc is an adapter to allow ordinary functions as
+	// ResponseHooks.
+	ResponseHookFunc = hook.ResponseHookFunc
+	// HookInfo describes the request a hook is invoked for.
+	HookInfo = hook.Info
+)
+
+// Options configures a single load test.  The zero value of each field
+// matches the default of the equivalent command line flag.
+type Options struct {
+	// URL is the endpoint to send requests to.
+	URL string
+	// Method is the HTTP verb to perform, defaults to GET.
+	Method string
+	// Header holds arbitrary HTTP headers sent with each request.
+	Header http.Header
+	// Body is sent with every request.
+	Body string
+	// Host overrides the Host header.
+	Host string
+	// UserAgent is prefixed onto vessel's own user agent.
+	UserAgent string
+	// BasicAuth holds colon separated user:pass credentials.
+	BasicAuth string
+	// Concurrency is the number of concurrent workers, defaults to 10.
+	Concurrency int
+	// Requests is the total number of requests to send.  Requests and
+	// Duration are mutually exclusive, if neither are set 50 requests
+	// are sent.
+	Requests int64
+	// Duration is how long to send requests for.
+	Duration time.Duration
+	// Warmup generates load for the duration before Requests or Duration
+	// begin, the warm-up requests are excluded from the Result but the
+	// connections they establish are reused.
+	Warmup time.Duration
+	// WarmupRequests sends the number of requests as a warm-up, as with
+	// Warmup.
+	WarmupRequests int64
+	// Timeout is the per request timeout, zero means no timeout.
+	Timeout time.Duration
+	// MaxRPS limits the requests in flight, zero means no limit.
+	MaxRPS int
+	// Rate dispatches requests at a fixed number per second regardless of
+	// how quickly they complete, zero sends them as fast as possible.  An
+	// arrival finding every worker busy waits for one, and is counted as
+	// late, or is dropped once Concurrency arrivals are waiting.
+	Rate float64
+	// Arrival is the process the gaps between arrivals at Rate follow, in
+	// the same form as the --arrival flag (e.g "poisson").  Defaults to
+	// evenly spaced arrivals.
+	Arrival string
+	// Shape varies the arrival rate over the run, in the same form as the
+	// --shape flag (e.g "sine:min=10,max=100,period=1m").  Shape and Rate
+	// are mutually exclusive.
+	Shape string
+	// Seed makes random arrivals and think times reproducible, zero picks
+	// a seed at random.
+	Seed uint64
+	// MaxConnections is the maximum number of connections per host,
+	// defaults to 1024.
+	MaxConnections int
+	// Insecure skips server certificate and host name verification.
+	Insecure bool
+	// Sessions gives every worker its own cookie jar so that each acts as
+	// a distinct virtual user with its own session.
+	Sessions bool
+	// UserPool gives every worker its own pool of connections.
+	UserPool bool
+	// ThinkTime pauses each worker between its iterations, in the same
+	// form as the --think flag (e.g "2s", "uniform:1s-3s").  The pause is
+	// excluded from the latency.
+	ThinkTime string
+	// Pacing begins each worker's iterations at most once per interval
+	// regardless of how long they take.
+	Pacing time.Duration
+	// Sinks consume the stream of results alongside the Result being
+	// built, for example to export them elsewhere.  An error returned by
+	// a sink's OnFinish is returned by Run together with the Result.
+	Sinks []Sink
+	// RequestHooks run (in order) on every request right before it is
+	// dispatched, returning an error fails the request.
+	RequestHooks []RequestHook
+	// ResponseHooks run (in order) on every response once its body has
+	// been read, returning an error fails the request.
+	ResponseHooks []ResponseHook
+	// Checks validate every response, in the same form as the --check
+	// flag (e.g "status=200", "jsonpath=$.status==ok").  Responses failing
+	// a check are counted in Result.Failed rather than Result.Errors.
+	Checks []string
+	// WebSocketCorrelate is the field of the JSON object messages sent to a
+	// ws:// or wss:// URL which is set to a unique id, the reply is the
+	// message received holding the same id.  Without it the reply is the
+	// next message received.
+	WebSocketCorrelate string
+	// WebSocketNoReply sends messages to a ws:// or wss:// URL without
+	// waiting for a reply, the latency is the time taken to write each.
+	WebSocketNoReply bool
+	// GRPCMethod is the gRPC method, package.Service/Method, to call on
+	// the server at URL rather than sending it HTTP requests.  Body holds
+	// the JSON form of the request message (or an array of them for methods
+	// streaming requests) and StatusCodes of the result are keyed by gRPC
+	// status.  The method is described by server reflection unless
+	// GRPCDescriptorSet is given.
+	GRPCMethod string
+	// GRPCDescriptorSet is a FileDescriptorSet describing GRPCMethod, as
+	// written by protoc with --descriptor_set_out and --include_imports.
+	GRPCDescriptorSet string
+	// Stream measures responses event by event rather than reading them
+	// whole, "sse" splits them into server-sent events and "chunks" takes
+	// each read as it arrives as an event.  The outcome is reported in
+	// Result.Stream.  Timeout only bounds the wait for a stream to begin,
+	// the streams still being read once Duration elapses are cut short.
+	Stream string
+	// StreamTimeout stops reading a streamed response once it has lasted
+	// the duration, it is counted as timed out rather than an error.
+	StreamTimeout time.Duration
+	// StreamMaxEvents stops reading a streamed response after the number
+	// of events, it is counted as truncated rather than an error.
+	StreamMaxEvents int64
+}
+
+// Result is the structured outcome of a load test.
+type Result struct {
+	Requests  int64
+	Errors    int64
+	Elapsed   time.Duration
+	RPS       float64
+	ErrorRate float64
+	// Failed is the number of responses which failed at least one check.
+	Failed      int64
+	FailureRate float64
+	// CheckFailures counts the failures of each check, keyed by its name.
+	CheckFailures map[string]int64
+	// Late is the number of scheduled arrivals (see Options.Rate) sent late
+	// having waited for a free worker, their latency includes the wait.
+	// Dropped is the number not sent at all as every worker was busy.
+	Late        int64
+	Dropped     int64
+	Latency     Latency
+	StatusCodes map[int]int64
+	// ErrorGroups counts the errors in each error category (e.g Timeout).
+	ErrorGroups map[string]int64
+	// Endpoints holds per endpoint results, keyed by "METHOD URL" without
+	// the query.  Endpoints beyond the first hundred are counted together
+	// under "other".
+	Endpoints map[string]*EndpointResult
+	// WarmupRequests is the number of requests sent during the warm-up,
+	// which are excluded from everything else.
+	WarmupRequests int64
+	// WebSocket describes the connections of a run against a ws:// or
+	// wss:// URL, whose messages are reported as requests.
+	WebSocket *WebSocketResult
+	// Stream describes the streamed responses of a run with
+	// Options.Stream, each of which is reported as a request lasting the
+	// stream.
+	Stream *StreamResult
+}
+
+// WebSocketResult is the outcome of the connections of a WebSocket run.
+type WebSocketResult struct {
+	// Connections is the number of connections opened.
+	Connections int64
+	// Handshake is the latency distribution of the opening handshakes.
+	Handshake Latency
+	// Received is the number of messages received, including replies.
+	Received          int64
+	ReceivedPerSecond float64
+	// CloseCodes counts the connections closed by the server by their
+	// close code.
+	CloseCodes map[int]int64
+}
+
+// StreamResult is the outcome of the streamed responses of a run.
+type StreamResult struct {
+	// Streams is the number of streamed responses received.
+	Streams         int64
+	Events          int64
+	EventsPerSecond float64
+	// FirstEvent is the distribution of the time to the first event,
+	// measured from each request being dispatched.
+	FirstEvent Latency
+	// Gap is the distribution of the time between consecutive events.
+	Gap Latency
+	// Duration is the distribution of the time taken by the streams which
+	// completed without error.
+	Duration Latency
+	// TimedOut and Truncated count the streams cut short by
+	// Options.StreamTimeout (or Options.Duration elapsing) and
+	// Options.StreamMaxEvents respectively.
+	TimedOut  int64
+	Truncated int64
+}
+
+// EndpointResult is the outcome of requests to a single endpoint.
+type EndpointResult struct {
+	Requests int64
+	Errors   int64
+	Failed   int64
+	RPS      float64
+	Latency  Latency
+}
+
+// Latency is the latency distribution of successful requests.
+type Latency struct {
+	Min  time.Duration
+	Max  time.Duration
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+
+	histogram *hdrhistogram.Histogram
+}
+
+// Percentile returns the latency at the given percentile (0 - 100).
+func (l Latency) Percentile(p float64) time.Duration {
+	if l.histogram == nil {
+		return 0
+	}
+	return micros(l.histogram.ValueAtQuantile(p))
+}
+
+// Histogram returns the full latency distribution, recorded in
+// microseconds.
+func (l Latency) Histogram() *hdrhistogram.Histogram {
+	return l.histogram
+}
+
+// Run executes a load test and returns its result once either the number
+// of requests or the duration is reached.  Cancelling ctx stops the test
+// early, finishing in flight requests, and returns the work completed.
+//
+// A sink failing does not lose the run, the result is returned alongside
+// the error.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	if opts.Requests > 0 && opts.Duration > 0 {
+		return nil, errors.New("requests and duration are mutually exclusive")
+	}
+	cfg := opts.config()
+	requestHooks := opts.RequestHooks
+	if opts.GRPCMethod != "" {
+		encoder, err := runner.GRPC(ctx, cfg, opts.GRPCMethod, opts.GRPCDescriptorSet)
+		if err != nil {
+			return nil, err
+		}
+		requestHooks = append(slices.Clip(requestHooks), encoder)
+	}
+	result, err := runner.Run(ctx, cfg,
+		runner.WithSinks(opts.Sinks...),
+		runner.WithRequestHooks(requestHooks...),
+		runner.WithResponseHooks(opts.ResponseHooks...),
+	)
+	if result == nil {
+		return nil, err
+	}
+	out, convertErr := convert(result)
+	if convertErr != nil {
+		return nil, errors.Join(err, convertErr)
+	}
+	return out, err
+}
+
+// config translates the options into the internal configuration applying
+// the command line defaults.
+func (o Options) config() *config.Config {
+	cfg := &config.Config{
+		Endpoint:        o.URL,
+		Method:          o.Method,
+		Host:            o.Host,
+		Body:            o.Body,
+		UserAgent:       o.UserAgent,
+		BasicAuth:       o.BasicAuth,
+		Concurrency:     o.Concurrency,
+		Amount:          o.Requests,
+		Duration:        o.Duration,
+		Warmup:          o.Warmup,
+		WarmupRequests:  o.WarmupRequests,
+		Timeout:         o.Timeout,
+		MaxRPS:          o.MaxRPS,
+		Rate:            o.Rate,
+		Arrival:         o.Arrival,
+		Seed:            o.Seed,
+		Shape:           o.Shape,
+		MaxConnections:  o.MaxConnections,
+		Insecure:        o.Insecure,
+		Sessions:        o.Sessions,
+		UserPool:        o.UserPool,
+		ThinkTime:       o.ThinkTime,
+		Pacing:          o.Pacing,
+		Checks:          o.Checks,
+		WSCorrelate:     o.WebSocketCorrelate,
+		WSNoReply:       o.WebSocketNoReply,
+		Stream:          o.Stream,
+		StreamTimeout:   o.StreamTimeout,
+		StreamMaxEvents: o.StreamMaxEvents,
+		FollowRedirects: true,
+		Version:         version.Version,
+		QuietSet:        true,
+	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodGet
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = 10
+	}
+	if cfg.MaxConnections == 0 {
+		cfg.MaxConnections = 1024
+	}
+	if cfg.Amount == 0 && cfg.Duration == 0 {
+		cfg.Amount = 50
+	}
+	for k, values := range o.Header {
+		for _, v := range values {
+			cfg.Headers = append(cfg.Headers, k+":"+v)
+		}
+	}
+	return cfg
+}
+
+// convert translates the internal result into the public Result.
+func convert(r *collector.Result) (*Result, error) {
+	latency, err := convertLatency(r.Latency)
+	if err != nil {
+		return nil, err
+	}
+	out := &Result{
+		Requests:      r.Requests,
+		Errors:        r.Errors,
+		Elapsed:       r.Elapsed,
+		RPS:           r.RPS,
+		ErrorRate:     r.ErrorRate,
+		Failed:        r.Failed,
+		FailureRate:   r.FailureRate,
+		CheckFailures: r.CheckFailures,
+		Late:          r.Late,
+		Dropped:       r.Dropped,
+		Latency:       latency,
+		StatusCodes:   r.StatusCodes,
+		ErrorGroups:   r.ErrorGroups,
+		Endpoints:     make(map[string]*EndpointResult, len(r.Endpoints)),
+	}
+	if r.Warmup != nil {
+		out.WarmupRequests = r.Warmup.Requests
+	}
+	if ws := r.WebSocket; ws != nil {
+		handshake, err := convertLatency(ws.Handshake)
+		if err != nil {
+			return nil, err
+		}
+		out.WebSocket = &WebSocketResult{
+			Connections:       ws.Connections,
+			Handshake:         handshake,
+			Received:          ws.Received,
+			ReceivedPerSecond: ws.ReceivedPerSecond,
+			CloseCodes:        ws.CloseCodes,
+		}
+	}
+	if s := r.Stream; s != nil {
+		stream := &StreamResult{
+			Streams:         s.Streams,
+			Events:          s.Events,
+			EventsPerSecond: s.EventsPerSecond,
+			TimedOut:        s.TimedOut,
+			Truncated:       s.Truncated,
+		}
+		for _, l := range []struct {
+			into *Latency
+			from collector.LatencyResult
+		}{
+			{into: &stream.FirstEvent, from: s.FirstEvent},
+			{into: &stream.Gap, from: s.Gap},
+			{into: &stream.Duration, from: s.Duration},
+		} {
+			if *l.into, err = convertLatency(l.from); err != nil {
+				return nil, err
+			}
+		}
+		out.Stream = stream
+	}
+	for name, e := range r.Endpoints {
+		latency, err := convertLatency(e.Latency)
+		if err != nil {
+			return nil, err
+		}
+		out.Endpoints[name] = &EndpointResult{
+			Requests: e.Requests,
+			Errors:   e.Errors,
+			Failed:   e.Failed,
+			RPS:      e.RPS,
+			Latency:  latency,
+		}
+	}
+	return out, nil
+}
+
+// convertLatency translates the internal microsecond latencies into
+// durations.
+func convertLatency(l collector.LatencyResult) (Latency, error) {
+	h, err := l.Decode()
+	if err != nil {
+		return Latency{}, err
+	}
+	return Latency{
+		Min:       micros(l.Min),
+		Max:       micros(l.Max),
+		Mean:      time.Duration(l.Mean * float64(time.Microsecond)),
+		P50:       micros(l.P50),
+		P90:       micros(l.P90),
+		P95:       micros(l.P95),
+		P99:       micros(l.P99),
+		histogram: h,
+	}, nil
+}
+
+// micros converts a microsecond value into a duration.
+func micros(us int64) time.Duration {
+	return time.Duration(us) * time.Microsecond
+}
@@ -0,0 +1,677 @@
+package vessel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/symonk/vessel/internal/distribution"
+	"github.com/symonk/vessel/internal/test/mockserver"
+	"github.com/symonk/vessel/internal/version"
+)
+
+func TestRunCollectsResults(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		URL:         server.Server.URL + "/status/201?page=1",
+		Concurrency: 5,
+		Requests:    100,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), result.Requests)
+	assert.Equal(t, int64(0), result.Errors)
+	assert.Equal(t, int64(100), result.StatusCodes[http.StatusCreated])
+	assert.Equal(t, int64(100), server.Seen.Load())
+	assert.Greater(t, result.RPS, 0.0)
+	assert.Greater(t, result.Latency.P99, time.Duration(0))
+	assert.LessOrEqual(t, result.Latency.P50, result.Latency.P99)
+	assert.Equal(t, result.Latency.P99, result.Latency.Percentile(99))
+	assert.Equal(t, int64(100), result.Latency.Histogram().TotalCount())
+	// Endpoints are keyed without their query.
+	assert.Equal(t, []string{"GET " + server.Server.URL + "/status/201"}, slices.Collect(maps.Keys(result.Endpoints)))
+}
+
+func TestRunForDuration(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		URL:      server.Server.URL + "/status/200",
+		Duration: 200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Greater(t, result.Requests, int64(0))
+	assert.GreaterOrEqual(t, result.Elapsed, 200*time.Millisecond)
+}
+
+func TestRunAtFixedRate(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		URL:         server.Server.URL + "/status/200",
+		Concurrency: 5,
+		Requests:    21,
+		Rate:        100,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(21), result.Requests)
+	// The final request is due 200ms after the first.
+	assert.GreaterOrEqual(t, result.Elapsed, 200*time.Millisecond)
+	assert.InDelta(t, 100, result.RPS, 10)
+}
+
+func TestRunAtFixedRateWithBusyWorkers(t *testing.T) {
+	server := mockserver.New(mockserver.WithArrivalsHandler())
+	defer server.Close()
+
+	// Two workers serve a request every 50ms between them, far short of the
+	// rate, the schedule must keep pace regardless.
+	result, err := Run(context.Background(), Options{
+		URL:         server.Server.URL + "/arrivals?delay=100ms",
+		Concurrency: 2,
+		Duration:    300 * time.Millisecond,
+		Rate:        100,
+	})
+	require.NoError(t, err)
+	assert.Greater(t, result.Dropped, int64(0))
+	assert.Greater(t, result.Late, int64(0))
+	assert.InDelta(t, 30, result.Requests+result.Dropped, 3)
+	// Arrivals which waited for a worker are measured from when they were
+	// due, not from when a worker became free.
+	assert.Greater(t, result.Latency.Max, 150*time.Millisecond)
+}
+
+func TestRunWithPoissonArrivals(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		URL:         server.Server.URL + "/status/200",
+		Concurrency: 5,
+		Requests:    50,
+		Rate:        200,
+		Arrival:     "poisson",
+		Seed:        1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), result.Requests)
+	assert.InDelta(t, 200, result.RPS, 100)
+
+	_, err = Run(context.Background(), Options{URL: server.Server.URL, Arrival: "poisson"})
+	assert.ErrorContains(t, err, "require a rate")
+}
+
+func TestRunArrivalsTrackTheirDistribution(t *testing.T) {
+	const (
+		rate     = 200
+		requests = 120
+		seed     = 42
+	)
+	for _, process := range []string{"constant", "poisson", "uniform"} {
+		t.Run(process, func(t *testing.T) {
+			server := mockserver.New(mockserver.WithArrivalsHandler())
+			defer server.Close()
+
+			// Each request takes far longer than the mean gap, so bursts
+			// rely on arrivals not waiting for earlier requests to complete.
+			result, err := Run(context.Background(), Options{
+				URL:         server.Server.URL + "/arrivals?delay=30ms",
+				Concurrency: 50,
+				Requests:    requests,
+				Rate:        rate,
+				Arrival:     process,
+				Seed:        seed,
+			})
+			require.NoError(t, err)
+			require.Equal(t, int64(requests), result.Requests)
+			assert.Zero(t, result.Dropped)
+
+			// The schedule is reproduced from the seed, the first arrival
+			// is sent immediately and each gap sampled thereafter.
+			d, err := distribution.Arrivals(process, rate)
+			require.NoError(t, err)
+			rng := distribution.NewRand(seed, math.MaxUint64)
+			scheduled := make([]time.Duration, requests)
+			for i := 1; i < requests; i++ {
+				scheduled[i] = scheduled[i-1] + d.Sample(rng)
+			}
+
+			// Arrivals are compared by their offset from the schedule, rather
+			// than gap by gap, so that jitter on one arrival does not show
+			// in two gaps.  The offsets are aligned on their median as the
+			// first arrival may itself be late, dialling a new connection.
+			// The schedules of the processes drift apart by tens of
+			// milliseconds, far beyond the tolerance.
+			arrivals := server.Arrivals()
+			require.Len(t, arrivals, requests)
+			slices.SortFunc(arrivals, time.Time.Compare)
+			offsets := make([]time.Duration, requests)
+			for i, arrival := range arrivals {
+				offsets[i] = arrival.Sub(arrivals[0]) - scheduled[i]
+			}
+			median := slices.Sorted(slices.Values(offsets))[requests/2]
+			deviations := make([]time.Duration, requests)
+			for i, offset := range offsets {
+				deviations[i] = (offset - median).Abs()
+			}
+			slices.Sort(deviations)
+			assert.Less(t, deviations[requests/2], 2*time.Millisecond, "median deviation from the schedule")
+			assert.Less(t, deviations[requests*3/4], 5*time.Millisecond, "75th percentile deviation from the schedule")
+		})
+	}
+}
+
+func TestRunWithShape(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		URL:         server.Server.URL + "/status/200",
+		Concurrency: 10,
+		Duration:    300 * time.Millisecond,
+		Shape:       "spike:base=50,peak=10x,at=100ms,for=100ms",
+	})
+	require.NoError(t, err)
+	// 10 arrivals outside of the spike and 50 during it.
+	assert.InDelta(t, 60, result.Requests, 15)
+
+	_, err = Run(context.Background(), Options{URL: server.Server.URL, Rate: 1, Shape: "sine:min=1,max=2,period=1s"})
+	assert.ErrorContains(t, err, "mutually exclusive")
+}
+
+func TestRunWithPacing(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		URL:         server.Server.URL + "/status/200",
+		Concurrency: 2,
+		Requests:    6,
+		Pacing:      50 * time.Millisecond,
+		ThinkTime:   "10ms",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), result.Requests)
+	// Each worker sends three requests, pausing twice in between.
+	assert.GreaterOrEqual(t, result.Elapsed, 100*time.Millisecond)
+	assert.Less(t, result.Latency.Max, 50*time.Millisecond)
+}
+
+func TestRunExcludesWarmup(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		URL:            server.Server.URL + "/status/200",
+		Concurrency:    2,
+		Requests:       30,
+		WarmupRequests: 20,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), result.Requests)
+	assert.Equal(t, int64(30), result.StatusCodes[http.StatusOK])
+	assert.Equal(t, int64(30), result.Latency.Histogram().TotalCount())
+	assert.Equal(t, int64(20), result.WarmupRequests)
+	assert.Equal(t, int64(50), server.Seen.Load())
+}
+
+func TestRunWithSessions(t *testing.T) {
+	tests := map[string]struct {
+		sessions bool
+		userPool bool
+		created  int64
+	}{
+		"shared anonymous client": {created: 30},
+		"session per worker":      {sessions: true, created: 3},
+		"session and pool":        {sessions: true, userPool: true, created: 3},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var created atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := r.Cookie("session"); err != nil {
+					http.SetCookie(w, &http.Cookie{Name: "session", Value: strconv.FormatInt(created.Add(1), 10)})
+				}
+			}))
+			defer server.Close()
+
+			result, err := Run(context.Background(), Options{
+				URL:         server.URL,
+				Concurrency: 3,
+				Requests:    30,
+				Sessions:    tc.sessions,
+				UserPool:    tc.userPool,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, int64(30), result.Requests)
+			assert.Equal(t, tc.created, created.Load())
+		})
+	}
+}
+
+func TestRunCountsTransportErrors(t *testing.T) {
+	server := mockserver.New()
+	url := server.Server.URL
+	server.Close()
+
+	result, err := Run(context.Background(), Options{URL: url, Requests: 10, Concurrency: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), result.Errors)
+	assert.Equal(t, 1.0, result.ErrorRate)
+	assert.Equal(t, int64(10), result.ErrorGroups["Connection"])
+}
+
+func TestRunSendsBody(t *testing.T) {
+	var matched atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b, _ := io.ReadAll(r.Body); r.Method == http.MethodPost && string(b) == "a=1" {
+			matched.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{URL: server.URL, Method: http.MethodPost, Body: "a=1", Requests: 20, Concurrency: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), result.Requests)
+	assert.Equal(t, int64(20), matched.Load())
+}
+
+func TestRunReportsTheBuildVersion(t *testing.T) {
+	defer func(v string) { version.Version = v }(version.Version)
+	version.Version = "v1.2.3"
+	var agents sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents.Store(r.UserAgent(), true)
+	}))
+	defer server.Close()
+
+	_, err := Run(context.Background(), Options{URL: server.URL, UserAgent: "suite", Requests: 2, Concurrency: 1})
+	require.NoError(t, err)
+	_, ok := agents.Load("suite vessel/v1.2.3")
+	assert.True(t, ok)
+}
+
+func TestRunRedactsTheURLPassword(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	sink := &countingSink{}
+	target := strings.Replace(server.Server.URL, "http://", "http://user:secret@", 1) + "/status/200"
+	result, err := Run(context.Background(), Options{URL: target, Requests: 2, Sinks: []Sink{sink}})
+	require.NoError(t, err)
+	require.NotNil(t, sink.finished)
+	b, err := json.Marshal(sink.finished)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+	assert.Contains(t, sink.finished.Endpoint, "user:xxxxx@")
+	for endpoint := range result.Endpoints {
+		assert.NotContains(t, endpoint, "secret")
+	}
+}
+
+func TestRunEndsThinkTimeWithTheRun(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	began := time.Now()
+	result, err := Run(context.Background(), Options{
+		URL:         server.Server.URL + "/status/200",
+		Duration:    200 * time.Millisecond,
+		Concurrency: 2,
+		ThinkTime:   "30s",
+		Pacing:      30 * time.Second,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Requests)
+	assert.Less(t, time.Since(began), 5*time.Second)
+}
+
+func TestRunRejectsBadOptions(t *testing.T) {
+	_, err := Run(context.Background(), Options{URL: "http://localhost", Requests: 1, Duration: time.Second})
+	assert.Error(t, err)
+	_, err = Run(context.Background(), Options{URL: "not a url"})
+	assert.ErrorContains(t, err, "bad endpoint")
+}
+
+type countingSink struct {
+	NopSink
+	results  int
+	finished *Report
+}
+
+func (c *countingSink) OnResult(*Sample) { c.results++ }
+
+func (c *countingSink) OnFinish(r *Report) error {
+	c.finished = r
+	return nil
+}
+
+func TestRunFeedsSinks(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	sinks := []*countingSink{{}, {}}
+	_, err := Run(context.Background(), Options{
+		URL:      server.Server.URL + "/status/200",
+		Requests: 25,
+		Sinks:    []Sink{sinks[0], sinks[1]},
+	})
+	require.NoError(t, err)
+	for _, s := range sinks {
+		assert.Equal(t, 25, s.results)
+		require.NotNil(t, s.finished)
+		assert.Equal(t, int64(25), s.finished.Requests)
+	}
+}
+
+type failingSink struct {
+	NopSink
+}
+
+func (failingSink) OnFinish(*Report) error {
+	return errors.New("export failed")
+}
+
+func TestRunReturnsTheResultWhenASinkFails(t *testing.T) {
+	server := mockserver.New(mockserver.WithStatusCodeTestHandler())
+	defer server.Close()
+
+	counting := &countingSink{}
+	result, err := Run(context.Background(), Options{
+		URL:      server.Server.URL + "/status/200",
+		Requests: 10,
+		Sinks:    []Sink{failingSink{}, counting},
+	})
+	assert.ErrorContains(t, err, "export failed")
+	require.NotNil(t, result)
+	assert.Equal(t, int64(10), result.Requests)
+	assert.Equal(t, map[int]int64{200: 10}, result.StatusCodes)
+	require.NotNil(t, counting.finished)
+}
+
+func TestRunAppliesHooks(t *testing.T) {
+	seen := make(chan string, 20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Get("X-Hook")
+		_, _ = w.Write([]byte("degraded"))
+	}))
+	defer server.Close()
+
+	var iterations sync.Map
+	result, err := Run(context.Background(), Options{
+		URL:         server.URL,
+		Requests:    20,
+		Concurrency: 2,
+		RequestHooks: []RequestHook{RequestHookFunc(func(r *http.Request, info HookInfo) error {
+			iterations.Store(fmt.Sprintf("%d-%d", info.Worker, info.Iteration), true)
+			r.Header.Set("X-Hook", "applied")
+			return nil
+		})},
+		ResponseHooks: []ResponseHook{ResponseHookFunc(func(_ *http.Response, body []byte, _ HookInfo) error {
+			if string(body) == "degraded" {
+				return errors.New("degraded response")
+			}
+			return nil
+		})},
+	})
+	require.NoError(t, err)
+	close(seen)
+	for h := range seen {
+		assert.Equal(t, "applied", h)
+	}
+	count := 0
+	iterations.Range(func(_, _ any) bool { count++; return true })
+	assert.Equal(t, 20, count)
+	assert.Equal(t, int64(20), result.Errors)
+}
+
+func TestRunCountsFailedChecksSeparately(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status": "degraded"}`))
+	}))
+	defer server.Close()
+
+	result, err := Run(context.Background(), Options{
+		URL:      server.URL,
+		Requests: 10,
+		Checks:   []string{"status=200", "jsonpath=$.status==ok"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Errors)
+	assert.Equal(t, int64(10), result.Failed)
+	assert.Equal(t, 1.0, result.FailureRate)
+	assert.Equal(t, map[string]int64{"jsonpath=$.status==ok": 10}, result.CheckFailures)
+}
+
+func TestRunOverWebSocket(t *testing.T) {
+	server := mockserver.New(mockserver.WithWebSocketEchoHandler())
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.Server.URL, "http") + "/ws"
+
+	tests := map[string]struct {
+		opts        Options
+		errors      int64
+		connections int64
+		received    int64
+		closeCodes  map[int]int64
+	}{
+		"replies are correlated": {
+			opts:        Options{Body: `{"a":1}`, WebSocketCorrelate: "id", Checks: []string{"jsonpath=$.a==1"}},
+			connections: 2,
+			received:    40,
+			closeCodes:  map[int]int64{},
+		},
+		"without replies": {
+			opts:        Options{Body: "hello", WebSocketNoReply: true},
+			connections: 2,
+			closeCodes:  map[int]int64{},
+		},
+		"closed by the server": {
+			opts:        Options{Body: "close"},
+			errors:      20,
+			connections: 20,
+			closeCodes:  map[int]int64{1011: 20},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc.opts.URL, tc.opts.Requests, tc.opts.Concurrency = url, 20, 2
+			result, err := Run(context.Background(), tc.opts)
+			require.NoError(t, err)
+			assert.Equal(t, int64(20), result.Requests)
+			assert.Equal(t, tc.errors, result.Errors)
+			assert.Zero(t, result.Failed)
+			require.NotNil(t, result.WebSocket)
+			assert.Equal(t, tc.connections, result.WebSocket.Connections)
+			assert.Equal(t, tc.closeCodes, result.WebSocket.CloseCodes)
+			if tc.received > 0 {
+				assert.Equal(t, tc.received, result.WebSocket.Received)
+			}
+			if tc.errors == 0 {
+				assert.Equal(t, int64(20), result.StatusCodes[http.StatusSwitchingProtocols])
+				assert.Greater(t, result.WebSocket.Handshake.P99, time.Duration(0))
+			}
+		})
+	}
+}
+
+func TestRunOverGRPC(t *testing.T) {
+	server := mockserver.New(mockserver.WithGRPCEchoHandler())
+	defer server.Close()
+	descriptors := filepath.Join(t.TempDir(), "echo.protoset")
+	require.NoError(t, os.WriteFile(descriptors, mockserver.GRPCDescriptorSet(), 0o600))
+
+	tests := map[string]struct {
+		opts   Options
+		codes  map[int]int64
+		failed int64
+	}{
+		"unary through reflection": {
+			opts:  Options{GRPCMethod: "echo.Echo/Say", Body: `{"text": "hi"}`, Checks: []string{"grpc-status=OK"}},
+			codes: map[int]int64{0: 20},
+		},
+		"unary from a descriptor set": {
+			opts:  Options{GRPCMethod: "echo.Echo.Say", GRPCDescriptorSet: descriptors, Body: `{"text": "hi"}`},
+			codes: map[int]int64{0: 20},
+		},
+		"status other than ok": {
+			opts:   Options{GRPCMethod: "echo.Echo/Say", Body: `{"text": "fail"}`, Checks: []string{"grpc-status=OK"}},
+			codes:  map[int]int64{5: 20},
+			failed: 20,
+		},
+		"server streaming": {
+			opts:  Options{GRPCMethod: "echo.Echo/Repeat", Body: `{"text": "hi", "count": 3}`},
+			codes: map[int]int64{0: 20},
+		},
+		"client streaming": {
+			opts:  Options{GRPCMethod: "echo.Echo/Collect", Body: `[{"text": "a"}, {"text": "b"}]`},
+			codes: map[int]int64{0: 20},
+		},
+		"empty message of a dependency": {
+			opts:  Options{GRPCMethod: "echo.Echo/Ping"},
+			codes: map[int]int64{0: 20},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc.opts.URL, tc.opts.Requests, tc.opts.Concurrency = server.Server.URL, 20, 2
+			result, err := Run(context.Background(), tc.opts)
+			require.NoError(t, err)
+			assert.Equal(t, int64(20), result.Requests)
+			assert.Zero(t, result.Errors)
+			assert.Equal(t, tc.codes, result.StatusCodes)
+			assert.Equal(t, tc.failed, result.Failed)
+		})
+	}
+	assert.Zero(t, server.Errors.Load())
+}
+
+func TestRunOverGRPCRejectsBadOptions(t *testing.T) {
+	server := mockserver.New(mockserver.WithGRPCEchoHandler())
+	defer server.Close()
+
+	tests := map[string]struct {
+		opts Options
+		want string
+	}{
+		"malformed method": {opts: Options{GRPCMethod: "Say"}, want: "package.Service/Method"},
+		"unknown service":  {opts: Options{GRPCMethod: "echo.Nope/Say"}, want: "not found"},
+		"unknown method":   {opts: Options{GRPCMethod: "echo.Echo/Shout"}, want: `unknown method "Shout"`},
+		"invalid message":  {opts: Options{GRPCMethod: "echo.Echo/Say", Body: `{"nope": 1}`}, want: `unknown field "nope"`},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc.opts.URL = server.Server.URL
+			_, err := Run(context.Background(), tc.opts)
+			assert.ErrorContains(t, err, tc.want)
+		})
+	}
+}
+
+func TestRunMeasuresStreams(t *testing.T) {
+	server := mockserver.New(mockserver.WithStreamHandler())
+	defer server.Close()
+
+	tests := map[string]struct {
+		path      string
+		opts      Options
+		events    int64
+		timedOut  int64
+		truncated int64
+	}{
+		"server-sent events": {
+			path:   "/sse?events=5&gap=5ms",
+			opts:   Options{Stream: "sse"},
+			events: 20,
+		},
+		"chunks": {
+			path:   "/chunks?events=5&gap=5ms",
+			opts:   Options{Stream: "chunks"},
+			events: 20,
+		},
+		"max events": {
+			path:      "/sse?events=50&gap=5ms",
+			opts:      Options{Stream: "sse", StreamMaxEvents: 3},
+			events:    12,
+			truncated: 4,
+		},
+		"timeout": {
+			path:     "/sse?events=1000&gap=10ms",
+			opts:     Options{Stream: "sse", StreamTimeout: 100 * time.Millisecond},
+			timedOut: 4,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc.opts.URL, tc.opts.Requests, tc.opts.Concurrency = server.Server.URL+tc.path, 4, 2
+			result, err := Run(context.Background(), tc.opts)
+			require.NoError(t, err)
+			assert.Zero(t, result.Errors)
+			require.NotNil(t, result.Stream)
+			s := result.Stream
+			assert.Equal(t, int64(4), s.Streams)
+			assert.Equal(t, tc.timedOut, s.TimedOut)
+			assert.Equal(t, tc.truncated, s.Truncated)
+			if tc.events > 0 {
+				assert.Equal(t, tc.events, s.Events)
+				assert.Equal(t, tc.events-4, s.Gap.Histogram().TotalCount())
+			} else {
+				assert.Greater(t, s.Events, int64(4))
+				assert.Less(t, s.Events, int64(4000))
+				assert.GreaterOrEqual(t, s.Duration.Min, 100*time.Millisecond)
+			}
+			assert.Greater(t, s.FirstEvent.P50, time.Duration(0))
+			assert.GreaterOrEqual(t, s.Gap.P50, 4*time.Millisecond)
+			assert.Equal(t, int64(4), s.Duration.Histogram().TotalCount())
+		})
+	}
+}
+
+func TestRunEndsStreamsWithTheRun(t *testing.T) {
+	server := mockserver.New(mockserver.WithStreamHandler())
+	defer server.Close()
+
+	// Each stream would last ten seconds, far beyond both the request
+	// timeout, which only bounds waiting for a stream to begin, and the
+	// duration, at which the streams still being read are cut short.
+	began := time.Now()
+	result, err := Run(context.Background(), Options{
+		URL:         server.Server.URL + "/sse?events=1000&gap=10ms",
+		Stream:      "sse",
+		Timeout:     50 * time.Millisecond,
+		Duration:    200 * time.Millisecond,
+		Concurrency: 2,
+	})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(began), 2*time.Second)
+	assert.Zero(t, result.Errors)
+	require.NotNil(t, result.Stream)
+	assert.GreaterOrEqual(t, result.Stream.Streams, int64(2))
+	assert.Equal(t, result.Stream.Streams, result.Stream.TimedOut)
+	assert.Greater(t, result.Stream.Events, int64(20))
+}
+
+func TestRunRejectsUnknownStreamMode(t *testing.T) {
+	_, err := Run(context.Background(), Options{URL: "http://localhost", Stream: "lines"})
+	assert.ErrorContains(t, err, "stream must be sse or chunks")
+}